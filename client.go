@@ -27,6 +27,13 @@ type Client struct {
 	lock                sync.RWMutex
 	database            string
 	useUrlDB            bool
+	encoders            *encoderRegistry
+	serverVersion       string
+	ws                  *wsPool
+	validator           func(sql string) error
+	precision           Precision
+	// precisions caches precision of databases by name
+	precisions sync.Map
 }
 
 type brokerStatus struct {
@@ -55,11 +62,28 @@ type tdengineDnode struct {
 
 func newTdengineEndPoint(ep string) *tdengineEndPoint {
 	index := strings.Index(ep, ":")
-	host := ep[:strings.Index(ep, ":")]
+	if index == -1 {
+		return &tdengineEndPoint{ep, ep, ""}
+	}
+	host := ep[:index]
 	port := ep[index+1:]
 	return &tdengineEndPoint{ep, host, port}
 }
 
+// dnodeStatus reads a row of `show dnodes`, TDengine 2.x has columns `end_point` and `role`,
+// 3.x names the end point `endpoint` and has no role. ok is false for arbitrators and rows without an end point.
+func dnodeStatus(node map[string]interface{}) (ep string, ready bool, ok bool) {
+	if role, _ := node["role"].(string); role == "arb" {
+		return "", false, false
+	}
+	ep, _ = node["end_point"].(string)
+	if ep == "" {
+		ep, _ = node["endpoint"].(string)
+	}
+	status, _ := node["status"].(string)
+	return ep, status == "ready", ep != ""
+}
+
 func NewClient(opts ...Option) *Client {
 	const (
 		defaultHealthCheckInterval = 15 * time.Second
@@ -75,6 +99,7 @@ func NewClient(opts ...Option) *Client {
 		healthCheckInterval: defaultHealthCheckInterval,
 		brokerStatus:        make([]*brokerStatus, 0),
 		done:                make(chan struct{}),
+		encoders:            newEncoderRegistry(),
 	}
 	for _, opt := range opts {
 		opt(client)
@@ -86,19 +111,23 @@ func (c *Client) Connect(ctx context.Context) error {
 	for _, broker := range c.brokers {
 
 		ret, err := c.request(ctx, broker, "show dnodes")
-		if err != nil || ret.Code != 0 {
-			fmt.Println("try connect to broker:", broker, "failed", err, ret.Code, ret.Message)
+		if err != nil {
+			fmt.Println("try connect to broker:", broker, "failed", err)
+			continue
+		}
+		if ret.Code != 0 {
+			fmt.Println("try connect to broker:", broker, "failed", ret.Code, ret.Message)
 			continue
 		}
 		for _, node := range ret.Data {
-			if node["role"].(string) == "arb" {
+			ep, ready, ok := dnodeStatus(node)
+			if !ok {
 				continue
 			}
-			endPoint := newTdengineEndPoint(node["end_point"].(string))
 			c.brokerStatus = append(c.brokerStatus, &brokerStatus{
-				ready:    node["status"].(string) == "ready",
+				ready:    ready,
 				count:    0,
-				endPoint: endPoint,
+				endPoint: newTdengineEndPoint(ep),
 			})
 		}
 		go c.check()
@@ -109,6 +138,9 @@ func (c *Client) Connect(ctx context.Context) error {
 
 func (c *Client) Close(ctx context.Context) error {
 	close(c.done)
+	if c.ws != nil {
+		c.ws.close()
+	}
 	return nil
 }
 
@@ -117,13 +149,24 @@ func (c *Client) Query(ctx context.Context, sql string, params ...interface{}) (
 	if !ok {
 		return nil, ErrorNoAvailableBroker
 	}
-	fullSQL, err := interpolate(sql, params)
+	fullSQL, err := interpolate(sql, params, c.encoders)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.validate(fullSQL); err != nil {
+		return nil, err
+	}
 	return c.request(ctx, broker, fullSQL)
 }
 
+// validate checks sql with the validator set by WithValidator
+func (c *Client) validate(sql string) error {
+	if c.validator == nil {
+		return nil
+	}
+	return c.validator(sql)
+}
+
 func (c *Client) NewSelectQueryBuilder() *SelectQueryBuilder {
 	if c.useUrlDB {
 		return &SelectQueryBuilder{
@@ -141,6 +184,9 @@ func (c *Client) NewSelectQueryBuilder() *SelectQueryBuilder {
 }
 
 func (c *Client) request(ctx context.Context, broker string, sql string) (*QueryResult, error) {
+	if c.ws != nil {
+		return c.wsRequest(ctx, broker, sql)
+	}
 	res, err := c.h.
 		R().
 		SetContext(ctx).
@@ -155,9 +201,44 @@ func (c *Client) request(ctx context.Context, broker string, sql string) (*Query
 		return nil, err
 	}
 	qr := NewQueryResult(rawRet, sql, res.Time())
+	qr.Precision = c.restPrecision()
 	return qr, nil
 }
 
+// post sends body to path of an alive broker, it shares auth and timeout with Query.
+// Responses with a non 2xx status are returned as *TDEngineError when they carry a code.
+func (c *Client) post(ctx context.Context, path string, query map[string]string, contentType string, body string) (*resty.Response, error) {
+	broker, ok := c.pickAliveBroker()
+	if !ok {
+		return nil, ErrorNoAvailableBroker
+	}
+	res, err := c.h.
+		R().
+		SetContext(ctx).
+		SetHeader("Content-Type", contentType).
+		SetQueryParams(query).
+		SetBody(body).
+		Post(fmt.Sprintf("http://%s:%d%s", broker, c.port, path))
+	if err != nil {
+		return nil, err
+	}
+	if res.IsSuccess() {
+		return res, nil
+	}
+	ret := &struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Desc    string `json:"desc"`
+	}{}
+	if err := json.Unmarshal(res.Body(), ret); err != nil || ret.Code == 0 {
+		return nil, fmt.Errorf("tdquery: unexpected http status %s: %s", res.Status(), strings.TrimSpace(string(res.Body())))
+	}
+	if ret.Message == "" {
+		ret.Message = ret.Desc
+	}
+	return nil, &TDEngineError{Code: ret.Code, Message: ret.Message}
+}
+
 func (c *Client) pickAliveBroker() (string, bool) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -165,8 +246,9 @@ func (c *Client) pickAliveBroker() (string, bool) {
 	index := -1
 	for i, s := range c.brokerStatus {
 		if s.ready {
-			if s.count <= min {
-				min = s.count
+			// count is increased by concurrent callers holding the read lock
+			if count := atomic.LoadUint64(&s.count); count <= min {
+				min = count
 				index = i
 			}
 		}
@@ -179,6 +261,14 @@ func (c *Client) pickAliveBroker() (string, bool) {
 	return bs.endPoint.host, true
 }
 
+// restPrecision is the precision of numeric timestamps returned by /rest/sqlt
+func (c *Client) restPrecision() Precision {
+	if c.precision == "" {
+		return PrecisionMillisecond
+	}
+	return c.precision
+}
+
 func (c *Client) newReqUrl(broker string) string {
 	if c.useUrlDB {
 		return fmt.Sprintf("http://%s:%d%s/%s", broker, c.port, queryURL, c.database)
@@ -199,8 +289,7 @@ func (c *Client) check() {
 			}
 			c.lock.Lock()
 			for _, node := range r.Data {
-				if node["role"].(string) != "arb" && node["status"].(string) != "ready" {
-					ep := node["end_point"].(string)
+				if ep, ready, ok := dnodeStatus(node); ok && !ready {
 					for i, status := range c.brokerStatus {
 						if status.endPoint.ep == ep {
 							c.brokerStatus[i].ready = false
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/snownd/tdquery"
+)
+
+// schema is the input of the generator, it can be read from a JSON file or from DESCRIBE.
+type schema struct {
+	Database string         `json:"database"`
+	STable   string         `json:"stable"`
+	Columns  []schemaColumn `json:"columns"`
+}
+
+// schemaColumn has the same fields as the rows of DESCRIBE
+type schemaColumn struct {
+	Field  string `json:"field"`
+	Type   string `json:"type"`
+	Length int    `json:"length"`
+	Note   string `json:"note"`
+}
+
+type genField struct {
+	Name    string
+	GoName  string
+	GoType  string
+	Tag     bool
+	Primary bool
+	TdTag   string
+}
+
+type genData struct {
+	Package  string
+	TypeName string
+	STable   string
+	Fields   []genField
+}
+
+var initialisms = map[string]string{
+	"id": "ID", "ip": "IP", "url": "URL", "uid": "UID", "uuid": "UUID", "api": "API", "http": "HTTP", "json": "JSON",
+}
+
+// goName converts snake_case names to CamelCase Go names
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	b := &strings.Builder{}
+	for _, p := range parts {
+		if v, ok := initialisms[strings.ToLower(p)]; ok {
+			b.WriteString(v)
+			continue
+		}
+		runes := []rune(strings.ToLower(p))
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	s := b.String()
+	if s == "" || unicode.IsDigit([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+func goType(t tdquery.ColumnType) (string, error) {
+	switch t {
+	case tdquery.ColumnTypeBool:
+		return "bool", nil
+	case tdquery.ColumnTypeTinyInt:
+		return "int8", nil
+	case tdquery.ColumnTypeSmallInt:
+		return "int16", nil
+	case tdquery.ColumnTypeInt:
+		return "int32", nil
+	case tdquery.ColumnTypeBigInt:
+		return "int64", nil
+	case tdquery.ColumnTypeUTinyInt:
+		return "uint8", nil
+	case tdquery.ColumnTypeUSmallInt:
+		return "uint16", nil
+	case tdquery.ColumnTypeUInt:
+		return "uint32", nil
+	case tdquery.ColumnTypeUBigInt:
+		return "uint64", nil
+	case tdquery.ColumnTypeFloat:
+		return "float32", nil
+	case tdquery.ColumnTypeDouble:
+		return "float64", nil
+	case tdquery.ColumnTypeTimestamp:
+		return "time.Time", nil
+	case tdquery.ColumnTypeBinary, tdquery.ColumnTypeNchar, tdquery.ColumnTypeJSON,
+		tdquery.ColumnTypeVarBinary, tdquery.ColumnTypeGeometry:
+		return "string", nil
+	}
+	return "", fmt.Errorf("unsupported column type %s", t)
+}
+
+// tdTag returns the td struct tag used by tdquery struct mapping, types with a length carry it
+func tdTag(c schemaColumn, t tdquery.ColumnType, primary, tag bool) string {
+	options := []string{c.Field}
+	if primary {
+		options = append(options, "timestamp")
+	}
+	switch t {
+	case tdquery.ColumnTypeBinary:
+		options = append(options, fmt.Sprintf("binary(%d)", c.Length))
+	case tdquery.ColumnTypeNchar:
+		options = append(options, fmt.Sprintf("nchar(%d)", c.Length))
+	case tdquery.ColumnTypeVarBinary:
+		options = append(options, fmt.Sprintf("varbinary(%d)", c.Length))
+	case tdquery.ColumnTypeGeometry:
+		options = append(options, fmt.Sprintf("geometry(%d)", c.Length))
+	case tdquery.ColumnTypeJSON:
+		options = append(options, "json")
+	}
+	if tag {
+		options = append(options, "tag")
+	}
+	return strings.Join(options, ",")
+}
+
+func generate(s *schema, pkg, typeName string) ([]byte, error) {
+	if typeName == "" {
+		typeName = goName(s.STable)
+	}
+	data := genData{Package: pkg, TypeName: typeName, STable: s.STable}
+	// Tbname is the field of the child table name
+	seen := map[string]string{"Tbname": "TBNAME"}
+	for i, c := range s.Columns {
+		t, err := tdquery.ParseColumnType(c.Type)
+		if err != nil {
+			return nil, err
+		}
+		gt, err := goType(t)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", c.Field, err)
+		}
+		isTag := strings.EqualFold(c.Note, "TAG")
+		f := genField{
+			Name:    c.Field,
+			GoName:  goName(c.Field),
+			GoType:  gt,
+			Tag:     isTag,
+			Primary: i == 0,
+			TdTag:   tdTag(c, t, i == 0, isTag),
+		}
+		if other, ok := seen[f.GoName]; ok {
+			return nil, fmt.Errorf("columns %s and %s have the same Go name %s", other, c.Field, f.GoName)
+		}
+		seen[f.GoName] = c.Field
+		data.Fields = append(data.Fields, f)
+	}
+	if len(data.Fields) == 0 || data.Fields[0].GoType != "time.Time" {
+		return nil, fmt.Errorf("super table %s must have a timestamp column first", s.STable)
+	}
+	buf := &bytes.Buffer{}
+	if err := codeTemplate.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+var codeTemplate = template.Must(template.New("code").Parse(`// Code generated by tdquery-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+// {{.TypeName}}STable is the name of the super table
+const {{.TypeName}}STable = "{{.STable}}"
+
+// Columns and tags of {{.TypeName}}STable
+const (
+{{- range .Fields}}
+	{{$.TypeName}}Column{{.GoName}} = "{{.Name}}"
+{{- end}}
+)
+
+// {{.TypeName}}Columns are all columns and tags in DESCRIBE order
+var {{.TypeName}}Columns = []string{
+{{- range .Fields}}
+	{{$.TypeName}}Column{{.GoName}},
+{{- end}}
+}
+
+// {{.TypeName}} is a row of {{.TypeName}}STable, it works with GetResult and InsertStructs.
+type {{.TypeName}} struct {
+	Tbname string ` + "`" + `td:",tbname" mapstructure:"tbname"` + "`" + `
+{{- range .Fields}}
+	{{.GoName}} {{.GoType}} ` + "`" + `td:"{{.TdTag}}" mapstructure:"{{.Name}}"` + "`" + `
+{{- end}}
+}
+
+// {{.TypeName}}QueryBuilder is a SelectQueryBuilder with typed conditions of {{.TypeName}}STable.
+// Typed methods should be called before methods of SelectQueryBuilder, which return *tdquery.SelectQueryBuilder.
+type {{.TypeName}}QueryBuilder struct {
+	*tdquery.SelectQueryBuilder
+}
+
+// {{.TypeName}}Query selects from {{.TypeName}}STable with the database of the client
+func {{.TypeName}}Query(c *tdquery.Client) *{{.TypeName}}QueryBuilder {
+	return &{{.TypeName}}QueryBuilder{
+		SelectQueryBuilder: c.NewSelectQueryBuilder().FromSTable({{.TypeName}}STable).TimeColumn({{.TypeName}}Column{{(index .Fields 0).GoName}}),
+	}
+}
+
+// SelectAllColumns selects all columns and tags
+func (q *{{.TypeName}}QueryBuilder) SelectAllColumns() *{{.TypeName}}QueryBuilder {
+	for _, c := range {{.TypeName}}Columns {
+		q.SelectColumn(c)
+	}
+	return q
+}
+
+// Where adds conditions
+func (q *{{.TypeName}}QueryBuilder) Where(conditions ...*tdquery.Condition) *{{.TypeName}}QueryBuilder {
+	q.SelectQueryBuilder.Where(conditions...)
+	return q
+}
+
+// WithTimeScope selects rows between start and end
+func (q *{{.TypeName}}QueryBuilder) WithTimeScope(start, end time.Time) *{{.TypeName}}QueryBuilder {
+	q.SelectQueryBuilder.WithTimeScope(start, end)
+	return q
+}
+{{range .Fields}}
+// Where{{.GoName}} adds condition {{.Name}} = value
+func (q *{{$.TypeName}}QueryBuilder) Where{{.GoName}}(value {{.GoType}}) *{{$.TypeName}}QueryBuilder {
+	return q.Where(tdquery.Equals({{$.TypeName}}Column{{.GoName}}, value))
+}
+
+// Where{{.GoName}}Op adds condition {{.Name}} with operator, e.g. Where{{.GoName}}Op(">", value)
+func (q *{{$.TypeName}}QueryBuilder) Where{{.GoName}}Op(operator string, value {{.GoType}}) *{{$.TypeName}}QueryBuilder {
+	return q.Where(tdquery.NewCondition({{$.TypeName}}Column{{.GoName}}, operator, value))
+}
+{{end}}
+// All runs the query and decodes rows into []{{.TypeName}}, all columns are selected if nothing is selected.
+func (q *{{.TypeName}}QueryBuilder) All(ctx context.Context) ([]{{.TypeName}}, error) {
+	if _, err := q.Clone().Build(); errors.Is(err, tdquery.ErrEmptySelect) {
+		q.SelectAllColumns()
+	}
+	ret := make([]{{.TypeName}}, 0)
+	if err := q.GetResult(ctx, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+`))
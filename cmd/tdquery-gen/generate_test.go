@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+func TestGoName(t *testing.T) {
+	cases := map[string]string{
+		"ts":          "Ts",
+		"group_id":    "GroupID",
+		"device-name": "DeviceName",
+		"HTTP_url":    "HTTPURL",
+		"1st":         "X1st",
+		"电流":          "电流",
+	}
+	for name, want := range cases {
+		if got := goName(name); got != want {
+			t.Errorf("goName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+var metersSchema = &schema{STable: "meters", Columns: []schemaColumn{
+	{Field: "ts", Type: "TIMESTAMP", Length: 8},
+	{Field: "current", Type: "FLOAT", Length: 4},
+	{Field: "on", Type: "BOOL", Length: 1},
+	{Field: "note", Type: "NCHAR", Length: 16},
+	{Field: "raw", Type: "VARBINARY", Length: 32},
+	{Field: "pos", Type: "GEOMETRY", Length: 64},
+	{Field: "location", Type: "VARCHAR", Length: 64, Note: "TAG"},
+	{Field: "group_id", Type: "INT UNSIGNED", Length: 4, Note: "TAG"},
+	{Field: "extra", Type: "JSON", Length: 4096, Note: "TAG"},
+}}
+
+// goTypes are Go types of generated fields
+var goTypes = map[string]reflect.Type{
+	"time.Time": reflect.TypeOf(time.Time{}),
+	"float32":   reflect.TypeOf(float32(0)),
+	"bool":      reflect.TypeOf(false),
+	"string":    reflect.TypeOf(""),
+	"uint32":    reflect.TypeOf(uint32(0)),
+}
+
+func TestGenerate(t *testing.T) {
+	code, err := generate(metersSchema, "power", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"package power",
+		`const MetersSTable = "meters"`,
+		"Tbname   string    `td:\",tbname\" mapstructure:\"tbname\"`",
+		"Ts       time.Time `td:\"ts,timestamp\" mapstructure:\"ts\"`",
+		"Note     string    `td:\"note,nchar(16)\" mapstructure:\"note\"`",
+		"Raw      string    `td:\"raw,varbinary(32)\" mapstructure:\"raw\"`",
+		"Pos      string    `td:\"pos,geometry(64)\" mapstructure:\"pos\"`",
+		"Location string    `td:\"location,binary(64),tag\" mapstructure:\"location\"`",
+		"GroupID  uint32    `td:\"group_id,tag\" mapstructure:\"group_id\"`",
+		"Extra    string    `td:\"extra,json,tag\" mapstructure:\"extra\"`",
+		"func (q *MetersQueryBuilder) WhereGroupID(value uint32) *MetersQueryBuilder {",
+		"TimeColumn(MetersColumnTs)",
+	} {
+		if !strings.Contains(string(code), line) {
+			t.Errorf("generated code has no %s\n%s", line, code)
+		}
+	}
+}
+
+// TestGenerateSchemaOf checks the generated struct works with struct mapping of tdquery
+func TestGenerateSchemaOf(t *testing.T) {
+	fields := []reflect.StructField{{Name: "Tbname", Type: goTypes["string"], Tag: `td:",tbname"`}}
+	for i, c := range metersSchema.Columns {
+		typ, _ := tdquery.ParseColumnType(c.Type)
+		gt, err := goType(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, reflect.StructField{
+			Name: goName(c.Field),
+			Type: goTypes[gt],
+			Tag:  reflect.StructTag(`td:"` + tdTag(c, typ, i == 0, strings.EqualFold(c.Note, "TAG")) + `"`),
+		})
+	}
+	s, err := tdquery.SchemaOf(reflect.New(reflect.StructOf(fields)).Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []tdquery.Column{
+		tdquery.NewColumn("ts", tdquery.ColumnTypeTimestamp),
+		tdquery.NewColumn("current", tdquery.ColumnTypeFloat),
+		tdquery.NewColumn("on", tdquery.ColumnTypeBool),
+		tdquery.NewSizedColumn("note", tdquery.ColumnTypeNchar, 16),
+		tdquery.NewSizedColumn("raw", tdquery.ColumnTypeVarBinary, 32),
+		tdquery.NewSizedColumn("pos", tdquery.ColumnTypeGeometry, 64),
+	}
+	if !reflect.DeepEqual(s.Columns, want) {
+		t.Errorf("columns %+v", s.Columns)
+	}
+	if len(s.Tags) != 3 || s.Tags[0] != tdquery.NewSizedColumn("location", tdquery.ColumnTypeBinary, 64) || s.Tags[2].Type != tdquery.ColumnTypeJSON {
+		t.Errorf("tags %+v", s.Tags)
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	cases := map[string]*schema{
+		"no timestamp first": {STable: "s", Columns: []schemaColumn{{Field: "v", Type: "INT"}, {Field: "ts", Type: "TIMESTAMP"}}},
+		"unknown type":       {STable: "s", Columns: []schemaColumn{{Field: "ts", Type: "TIMESTAMP"}, {Field: "v", Type: "DECIMAL"}}},
+		"same Go name":       {STable: "s", Columns: []schemaColumn{{Field: "ts", Type: "TIMESTAMP"}, {Field: "a_b", Type: "INT"}, {Field: "a-b", Type: "INT"}}},
+		"tbname column":      {STable: "s", Columns: []schemaColumn{{Field: "ts", Type: "TIMESTAMP"}, {Field: "tbname", Type: "INT"}}},
+		"empty":              {STable: "s"},
+	}
+	for name, s := range cases {
+		if _, err := generate(s, "main", ""); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Command tdquery-gen generates Go structs and typed query helpers from a TDengine super table.
+//
+// It reads the schema with DESCRIBE from a cluster:
+//
+//	tdquery-gen -brokers localhost -db power -stable meters -package power -o meters.go
+//
+// or from a JSON file with the same fields as DESCRIBE:
+//
+//	{"stable": "meters", "columns": [{"field": "ts", "type": "TIMESTAMP", "length": 8, "note": ""}]}
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+func main() {
+	var (
+		brokers  = flag.String("brokers", "localhost", "comma separated brokers")
+		port     = flag.Int("port", 6041, "restful port")
+		user     = flag.String("user", "root", "username")
+		password = flag.String("password", "taosdata", "password")
+		db       = flag.String("db", "", "database of the super table")
+		stable   = flag.String("stable", "", "super table name")
+		file     = flag.String("schema", "", "read schema from a JSON file instead of a cluster")
+		pkg      = flag.String("package", "main", "package name of generated code")
+		typeName = flag.String("type", "", "struct name, default is CamelCase of the super table name")
+		output   = flag.String("o", "", "output file, default is stdout")
+	)
+	flag.Parse()
+
+	s, err := loadSchema(*file, *brokers, *port, *user, *password, *db, *stable)
+	if err != nil {
+		fail(err)
+	}
+	code, err := generate(s, *pkg, *typeName)
+	if err != nil {
+		fail(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := os.WriteFile(*output, code, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "tdquery-gen:", err)
+	os.Exit(1)
+}
+
+func loadSchema(file, brokers string, port int, user, password, db, stable string) (*schema, error) {
+	if file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		s := &schema{}
+		if err := json.Unmarshal(content, s); err != nil {
+			return nil, err
+		}
+		if stable != "" {
+			s.STable = stable
+		}
+		if s.STable == "" {
+			return nil, fmt.Errorf("super table name is required")
+		}
+		return s, nil
+	}
+	if db == "" || stable == "" {
+		return nil, fmt.Errorf("-db and -stable are required without -schema")
+	}
+	client := tdquery.NewClient(
+		tdquery.WithBrokers(strings.Split(brokers, ",")),
+		tdquery.WithPort(port),
+		tdquery.WithBasicAuth(user, password),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer client.Close(ctx)
+	columns, err := client.Describe(ctx, db, stable)
+	if err != nil {
+		return nil, err
+	}
+	s := &schema{Database: db, STable: stable}
+	for _, c := range columns {
+		s.Columns = append(s.Columns, schemaColumn{Field: c.Name, Type: c.Type.String(), Length: c.Length, Note: c.Note})
+	}
+	return s, nil
+}
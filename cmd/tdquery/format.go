@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/snownd/tdquery"
+)
+
+type outputFormat string
+
+const (
+	formatTable    outputFormat = "table"
+	formatCSV      outputFormat = "csv"
+	formatJSON     outputFormat = "json"
+	formatVertical outputFormat = "vertical"
+)
+
+func parseFormat(s string) (outputFormat, error) {
+	switch f := outputFormat(strings.ToLower(s)); f {
+	case formatTable, formatCSV, formatJSON, formatVertical:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %s, should be one of table, csv, json, vertical", s)
+}
+
+// timeLayout shows fractional seconds in precision
+func timeLayout(precision tdquery.Precision) string {
+	switch precision {
+	case tdquery.PrecisionMicrosecond:
+		return "2006-01-02 15:04:05.000000"
+	case tdquery.PrecisionNanosecond:
+		return "2006-01-02 15:04:05.000000000"
+	default:
+		return "2006-01-02 15:04:05.000"
+	}
+}
+
+// timeOf reads a numeric timestamp in precision
+func timeOf(x float64, precision tdquery.Precision) time.Time {
+	return time.Unix(0, int64(x)*int64(precision.Unit()))
+}
+
+// formatValue formats a value for table, csv and vertical output, numeric timestamps are in precision.
+func formatValue(column tdquery.ColumnMeta, v interface{}, precision tdquery.Precision) string {
+	if v == nil {
+		return "NULL"
+	}
+	switch x := v.(type) {
+	case float64:
+		if column.Type == tdquery.ColumnTypeTimestamp {
+			return timeOf(x, precision).Format(timeLayout(precision))
+		}
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case string:
+		return x
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func writeResult(w io.Writer, f outputFormat, r *tdquery.QueryResult) error {
+	switch f {
+	case formatCSV:
+		return writeCSV(w, r)
+	case formatJSON:
+		return writeJSON(w, r)
+	case formatVertical:
+		return writeVertical(w, r)
+	default:
+		return writeTable(w, r)
+	}
+}
+
+func writeTable(w io.Writer, r *tdquery.QueryResult) error {
+	widths := make([]int, len(r.Columns))
+	cells := make([][]string, 0, len(r.Data))
+	for i, c := range r.Columns {
+		widths[i] = utf8.RuneCountInString(c.Name)
+	}
+	for _, row := range r.Data {
+		line := make([]string, len(r.Columns))
+		for i, c := range r.Columns {
+			line[i] = formatValue(c, row[c.Name], r.Precision)
+			if l := utf8.RuneCountInString(line[i]); l > widths[i] {
+				widths[i] = l
+			}
+		}
+		cells = append(cells, line)
+	}
+	separator := &strings.Builder{}
+	separator.WriteRune('+')
+	for _, width := range widths {
+		separator.WriteString(strings.Repeat("-", width+2))
+		separator.WriteRune('+')
+	}
+	separator.WriteRune('\n')
+	writeLine := func(values []string) {
+		b := &strings.Builder{}
+		b.WriteRune('|')
+		for i, v := range values {
+			b.WriteRune(' ')
+			b.WriteString(v)
+			b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(v)+1))
+			b.WriteRune('|')
+		}
+		b.WriteRune('\n')
+		io.WriteString(w, b.String())
+	}
+	names := make([]string, len(r.Columns))
+	for i, c := range r.Columns {
+		names[i] = c.Name
+	}
+	io.WriteString(w, separator.String())
+	writeLine(names)
+	io.WriteString(w, separator.String())
+	for _, line := range cells {
+		writeLine(line)
+	}
+	_, err := io.WriteString(w, separator.String())
+	return err
+}
+
+func writeCSV(w io.Writer, r *tdquery.QueryResult) error {
+	cw := csv.NewWriter(w)
+	names := make([]string, len(r.Columns))
+	for i, c := range r.Columns {
+		names[i] = c.Name
+	}
+	if err := cw.Write(names); err != nil {
+		return err
+	}
+	for _, row := range r.Data {
+		line := make([]string, len(r.Columns))
+		for i, c := range r.Columns {
+			if row[c.Name] == nil {
+				continue
+			}
+			line[i] = formatValue(c, row[c.Name], r.Precision)
+		}
+		if err := cw.Write(line); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON writes a JSON object for each row in column order
+func writeJSON(w io.Writer, r *tdquery.QueryResult) error {
+	for _, row := range r.Data {
+		b := &strings.Builder{}
+		b.WriteRune('{')
+		for i, c := range r.Columns {
+			if i > 0 {
+				b.WriteRune(',')
+			}
+			name, _ := json.Marshal(c.Name)
+			b.Write(name)
+			b.WriteRune(':')
+			var v interface{} = row[c.Name]
+			if x, ok := v.(float64); ok && c.Type == tdquery.ColumnTypeTimestamp {
+				v = timeOf(x, r.Precision).Format(time.RFC3339Nano)
+			}
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			b.Write(value)
+		}
+		b.WriteString("}\n")
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeVertical(w io.Writer, r *tdquery.QueryResult) error {
+	width := 0
+	for _, c := range r.Columns {
+		if l := utf8.RuneCountInString(c.Name); l > width {
+			width = l
+		}
+	}
+	for i, row := range r.Data {
+		fmt.Fprintf(w, "*************************** %d. row ***************************\n", i+1)
+		for _, c := range r.Columns {
+			fmt.Fprintf(w, "%*s: %s\n", width, c.Name, formatValue(c, row[c.Name], r.Precision))
+		}
+	}
+	return nil
+}
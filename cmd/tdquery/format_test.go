@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+var (
+	tsColumn   = tdquery.ColumnMeta{Name: "ts", Type: tdquery.ColumnTypeTimestamp}
+	vColumn    = tdquery.ColumnMeta{Name: "v", Type: tdquery.ColumnTypeDouble}
+	nameColumn = tdquery.ColumnMeta{Name: "name", Type: tdquery.ColumnTypeNchar}
+)
+
+func TestFormatValue(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	ms := float64(ts.UnixNano() / 1e6)
+	us := float64(ts.UnixNano() / 1e3)
+	cases := []struct {
+		column    tdquery.ColumnMeta
+		v         interface{}
+		precision tdquery.Precision
+		want      string
+	}{
+		{tsColumn, ms, tdquery.PrecisionMillisecond, ts.Format("2006-01-02 15:04:05") + ".123"},
+		{tsColumn, ms, "", ts.Format("2006-01-02 15:04:05") + ".123"},
+		{tsColumn, us, tdquery.PrecisionMicrosecond, ts.Format("2006-01-02 15:04:05") + ".123456"},
+		{tsColumn, "2023-11-14T22:13:20.123Z", tdquery.PrecisionMillisecond, "2023-11-14T22:13:20.123Z"},
+		{vColumn, 1.5, tdquery.PrecisionMicrosecond, "1.5"},
+		{vColumn, float64(12345678901), "", "12345678901"},
+		{vColumn, nil, "", "NULL"},
+		{tdquery.ColumnMeta{Type: tdquery.ColumnTypeBool}, true, "", "true"},
+		{nameColumn, "电表", "", "电表"},
+	}
+	for _, tc := range cases {
+		if got := formatValue(tc.column, tc.v, tc.precision); got != tc.want {
+			t.Errorf("formatValue(%s, %v, %q) = %s, want %s", tc.column.Type, tc.v, tc.precision, got, tc.want)
+		}
+	}
+}
+
+func result(precision tdquery.Precision, ts time.Time) *tdquery.QueryResult {
+	stamp := float64(ts.UnixNano() / int64(precision.Unit()))
+	return &tdquery.QueryResult{
+		Columns:   []tdquery.ColumnMeta{tsColumn, vColumn, nameColumn},
+		Precision: precision,
+		Data: []map[string]interface{}{
+			{"ts": stamp, "v": 1.5, "name": "电表"},
+			{"ts": stamp, "v": nil, "name": "a,\"b\""},
+		},
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	ts := time.Date(2023, 11, 14, 22, 13, 20, 123456000, time.Local)
+	cases := []struct {
+		format outputFormat
+		want   string
+	}{
+		{formatTable, "" +
+			"+----------------------------+------+-------+\n" +
+			"| ts                         | v    | name  |\n" +
+			"+----------------------------+------+-------+\n" +
+			"| 2023-11-14 22:13:20.123456 | 1.5  | 电表    |\n" +
+			"| 2023-11-14 22:13:20.123456 | NULL | a,\"b\" |\n" +
+			"+----------------------------+------+-------+\n"},
+		{formatCSV, "ts,v,name\n" +
+			"2023-11-14 22:13:20.123456,1.5,电表\n" +
+			"2023-11-14 22:13:20.123456,,\"a,\"\"b\"\"\"\n"},
+		{formatJSON, "" +
+			`{"ts":"` + ts.Format(time.RFC3339Nano) + `","v":1.5,"name":"电表"}` + "\n" +
+			`{"ts":"` + ts.Format(time.RFC3339Nano) + `","v":null,"name":"a,\"b\""}` + "\n"},
+		{formatVertical, "" +
+			"*************************** 1. row ***************************\n" +
+			"  ts: 2023-11-14 22:13:20.123456\n" +
+			"   v: 1.5\n" +
+			"name: 电表\n" +
+			"*************************** 2. row ***************************\n" +
+			"  ts: 2023-11-14 22:13:20.123456\n" +
+			"   v: NULL\n" +
+			"name: a,\"b\"\n"},
+	}
+	for _, tc := range cases {
+		w := &bytes.Buffer{}
+		if err := writeResult(w, tc.format, result(tdquery.PrecisionMicrosecond, ts)); err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != tc.want {
+			t.Errorf("%s output:\n%s\nwant:\n%s", tc.format, w, tc.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Command tdquery is an interactive shell for TDengine over the REST api.
+//
+//	tdquery -h localhost -u root -p taosdata
+//	tdquery -e "SHOW DATABASES; SELECT SERVER_VERSION()"
+//	tdquery -d power -f queries.sql -format csv
+//
+// Statements end with `;` and can span lines. Shell commands start with `\`, type `\help` for details.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+type config struct {
+	brokers  []string
+	port     int
+	user     string
+	password string
+	database string
+	urlDB    bool
+	timeout  time.Duration
+	// precision of timestamps, it is read from the database when empty
+	precision tdquery.Precision
+}
+
+func (c config) newClient() *tdquery.Client {
+	opts := []tdquery.Option{
+		tdquery.WithBrokers(c.brokers),
+		tdquery.WithPort(c.port),
+		tdquery.WithBasicAuth(c.user, c.password),
+		tdquery.WithQueryTimeout(c.timeout),
+	}
+	if c.database != "" {
+		opts = append(opts, tdquery.WithDatabase(c.database), tdquery.WithUrlDatabase())
+	}
+	return tdquery.NewClient(opts...)
+}
+
+func main() {
+	var (
+		hosts     = flag.String("h", "localhost", "comma separated brokers")
+		port      = flag.Int("P", 6041, "restful port")
+		user      = flag.String("u", "root", "username")
+		password  = flag.String("p", "taosdata", "password")
+		database  = flag.String("d", "", "database, needs -url-db")
+		urlDB     = flag.Bool("url-db", true, "choose database in url, needs TDengine 2.2.0.0 or later. Without it -d and USE are not available")
+		timeout   = flag.Duration("timeout", 30*time.Second, "query timeout")
+		execute   = flag.String("e", "", "execute statements and exit")
+		file      = flag.String("f", "", "execute statements from file and exit")
+		format    = flag.String("format", "table", "output format: table, csv, json or vertical")
+		timing    = flag.Bool("timing", false, "print cost of each statement")
+		precision = flag.String("precision", "", "precision of timestamps: ms, us or ns, default is the precision of the database")
+	)
+	flag.Parse()
+
+	if *database != "" && !*urlDB {
+		fmt.Fprintln(os.Stderr, "tdquery: -d needs -url-db, statements are sent without a database otherwise")
+		os.Exit(2)
+	}
+	switch tdquery.Precision(*precision) {
+	case "", tdquery.PrecisionMillisecond, tdquery.PrecisionMicrosecond, tdquery.PrecisionNanosecond:
+	default:
+		fmt.Fprintln(os.Stderr, "tdquery: -precision should be one of ms, us, ns")
+		os.Exit(2)
+	}
+	f, err := parseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	s := &shell{
+		config: config{
+			brokers:   strings.Split(*hosts, ","),
+			port:      *port,
+			user:      *user,
+			password:  *password,
+			database:  *database,
+			urlDB:     *urlDB,
+			timeout:   *timeout,
+			precision: tdquery.Precision(*precision),
+		},
+		format: f,
+		timing: *timing,
+		out:    os.Stdout,
+		errOut: os.Stderr,
+	}
+	if err := s.connect(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, "tdquery: connect failed:", err)
+		os.Exit(1)
+	}
+	defer s.client.Close(context.Background())
+
+	switch {
+	case *execute != "":
+		os.Exit(s.runScript(*execute))
+	case *file != "":
+		content, err := readFile(*file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(s.runScript(content))
+	default:
+		s.repl(os.Stdin)
+	}
+}
+
+func readFile(name string) (string, error) {
+	if name == "-" {
+		content, err := io.ReadAll(os.Stdin)
+		return string(content), err
+	}
+	content, err := os.ReadFile(name)
+	return string(content), err
+}
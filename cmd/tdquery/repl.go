@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/sqlparse"
+)
+
+const historyFile = ".tdquery_history"
+const maxHistory = 1000
+
+var useRegexp = regexp.MustCompile(`(?i)^USE\s+([A-Za-z0-9_]+)$`)
+
+const helpText = `Statements end with ; and can span lines.
+Commands:
+  \q, quit, exit          quit
+  \timing [on|off]        toggle printing cost of each statement
+  \format <format>        set output format: table, csv, json or vertical
+  \history [n]            print last n statements, default 20
+  \help                   print this help
+  USE <db>                switch database, needs TDengine 2.2.0.0 or later
+`
+
+type shell struct {
+	config config
+	client *tdquery.Client
+	// precision of the current database, REST results do not carry it
+	precision tdquery.Precision
+	format    outputFormat
+	timing    bool
+	out       io.Writer
+	errOut    io.Writer
+	history   []string
+}
+
+func (s *shell) connect(ctx context.Context) error {
+	client := s.config.newClient()
+	if err := client.Connect(ctx); err != nil {
+		return err
+	}
+	precision := s.config.precision
+	if precision == "" && s.config.database != "" {
+		p, err := client.DatabasePrecision(ctx, s.config.database)
+		if err != nil {
+			client.Close(ctx)
+			return err
+		}
+		precision = p
+	}
+	if s.client != nil {
+		s.client.Close(ctx)
+	}
+	s.client = client
+	s.precision = precision
+	return nil
+}
+
+// runScript executes all statements and returns exit code, it stops at the first error.
+func (s *shell) runScript(script string) int {
+	statements, err := sqlparse.Split(script)
+	if err != nil {
+		fmt.Fprintln(s.errOut, "tdquery:", err)
+		return 1
+	}
+	for _, statement := range statements {
+		if !s.execute(statement) {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (s *shell) prompt(continued bool) {
+	if continued {
+		fmt.Fprint(s.out, "      -> ")
+		return
+	}
+	db := s.config.database
+	if db == "" {
+		fmt.Fprint(s.out, "tdquery> ")
+		return
+	}
+	fmt.Fprintf(s.out, "tdquery:%s> ", db)
+}
+
+func (s *shell) repl(in io.Reader) {
+	s.loadHistory()
+	fmt.Fprintln(s.out, `Welcome to tdquery shell, type \help for help.`)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	buffer := ""
+	s.prompt(false)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if buffer == "" && (strings.HasPrefix(trimmed, `\`) || trimmed == "quit" || trimmed == "exit") {
+			if !s.command(trimmed) {
+				return
+			}
+			s.prompt(false)
+			continue
+		}
+		buffer += line + "\n"
+		statements, rest := sqlparse.SplitPrefix(buffer)
+		for _, statement := range statements {
+			s.addHistory(statement)
+			s.execute(statement)
+		}
+		buffer = rest
+		if strings.TrimSpace(buffer) == "" {
+			buffer = ""
+		}
+		s.prompt(buffer != "")
+	}
+	fmt.Fprintln(s.out)
+}
+
+// command runs a shell command, it returns false to quit
+func (s *shell) command(line string) bool {
+	fields := strings.Fields(line)
+	switch strings.TrimPrefix(fields[0], `\`) {
+	case "q", "quit", "exit":
+		return false
+	case "timing":
+		if len(fields) > 1 {
+			s.timing = strings.EqualFold(fields[1], "on")
+		} else {
+			s.timing = !s.timing
+		}
+		fmt.Fprintf(s.out, "Timing is %s.\n", onOff(s.timing))
+	case "format":
+		if len(fields) < 2 {
+			fmt.Fprintf(s.out, "Format is %s.\n", s.format)
+			break
+		}
+		f, err := parseFormat(fields[1])
+		if err != nil {
+			fmt.Fprintln(s.errOut, err)
+			break
+		}
+		s.format = f
+	case "history":
+		n := 20
+		if len(fields) > 1 {
+			if v, err := strconv.Atoi(fields[1]); err == nil {
+				n = v
+			}
+		}
+		start := len(s.history) - n
+		if start < 0 {
+			start = 0
+		}
+		for i := start; i < len(s.history); i++ {
+			fmt.Fprintf(s.out, "%5d  %s\n", i+1, s.history[i])
+		}
+	case "help", "h", "?":
+		fmt.Fprint(s.out, helpText)
+	default:
+		fmt.Fprintf(s.errOut, "unknown command %s, type \\help for help\n", fields[0])
+	}
+	return true
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// execute runs a statement and prints its result, it returns false on error.
+func (s *shell) execute(statement string) bool {
+	if m := useRegexp.FindStringSubmatch(statement); m != nil {
+		return s.use(m[1])
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.timeout)
+	defer cancel()
+	r, err := s.client.Query(ctx, statement)
+	if err != nil {
+		fmt.Fprintln(s.errOut, "tdquery:", err)
+		return false
+	}
+	if r.Code != 0 {
+		fmt.Fprintf(s.errOut, "tdquery: error code %d (0x%x): %s\n", r.Code, r.Code, r.Message)
+		return false
+	}
+	if s.precision != "" {
+		r.Precision = s.precision
+	}
+	if len(r.Columns) == 1 && r.Columns[0].Name == "affected_rows" && len(r.Data) == 1 {
+		fmt.Fprintf(s.out, "Query OK, %v row(s) affected%s\n", formatValue(r.Columns[0], r.Data[0]["affected_rows"], r.Precision), s.cost(r))
+		return true
+	}
+	if err := writeResult(s.out, s.format, r); err != nil {
+		fmt.Fprintln(s.errOut, "tdquery:", err)
+		return false
+	}
+	if s.format == formatTable || s.format == formatVertical {
+		fmt.Fprintf(s.out, "Query OK, %d row(s) in set%s\n", len(r.Data), s.cost(r))
+	} else if s.timing {
+		fmt.Fprintf(s.errOut, "Cost%s\n", s.cost(r))
+	}
+	return true
+}
+
+func (s *shell) cost(r *tdquery.QueryResult) string {
+	if !s.timing {
+		return ""
+	}
+	return fmt.Sprintf(" (%d ms)", r.Cost)
+}
+
+// use switches database by creating a new client, because REST api is stateless
+func (s *shell) use(db string) bool {
+	if !s.config.urlDB {
+		fmt.Fprintln(s.errOut, "tdquery: USE needs -url-db, statements are sent without a database otherwise")
+		return false
+	}
+	previous := s.config.database
+	s.config.database = db
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.timeout)
+	defer cancel()
+	if err := s.connect(ctx); err != nil {
+		s.config.database = previous
+		fmt.Fprintln(s.errOut, "tdquery:", err)
+		return false
+	}
+	fmt.Fprintln(s.out, "Database changed.")
+	return true
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFile)
+}
+
+func (s *shell) loadHistory() {
+	path := historyPath()
+	if path == "" {
+		return
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			s.history = append(s.history, line)
+		}
+	}
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+}
+
+func (s *shell) addHistory(statement string) {
+	line := strings.Join(strings.Fields(statement), " ")
+	s.history = append(s.history, line)
+	path := historyPath()
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/standin"
+)
+
+func TestCommand(t *testing.T) {
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	s := &shell{format: formatTable, out: out, errOut: errOut, history: []string{"SELECT 1;", "SELECT 2;", "SELECT 3;"}}
+	cases := []struct {
+		line   string
+		out    string
+		errOut string
+	}{
+		{`\timing`, "Timing is on.\n", ""},
+		{`\timing off`, "Timing is off.\n", ""},
+		{`\format CSV`, "", ""},
+		{`\format`, "Format is csv.\n", ""},
+		{`\format xml`, "", "unknown format xml, should be one of table, csv, json, vertical\n"},
+		{`\history 2`, "    2  SELECT 2;\n    3  SELECT 3;\n", ""},
+		{`\help`, helpText, ""},
+		{`\x`, "", "unknown command \\x, type \\help for help\n"},
+	}
+	for _, tc := range cases {
+		out.Reset()
+		errOut.Reset()
+		if !s.command(tc.line) {
+			t.Errorf("%s quits", tc.line)
+		}
+		if out.String() != tc.out || errOut.String() != tc.errOut {
+			t.Errorf("%s: out %q, error %q", tc.line, out, errOut)
+		}
+	}
+	for _, line := range []string{`\q`, "quit", "exit"} {
+		if s.command(line) {
+			t.Errorf("%s does not quit", line)
+		}
+	}
+}
+
+// newShell returns a shell of a stand-in with databases power in us and test in ms
+func newShell(t *testing.T, database string, urlDB bool) (*shell, *standin.Server, *bytes.Buffer, *bytes.Buffer) {
+	t.Setenv("HOME", t.TempDir())
+	ts := time.Date(2023, 11, 14, 22, 13, 20, 123456000, time.Local)
+	server := standin.New(t, func(sql string) *standin.Result {
+		// statements of the shell keep their line breaks
+		switch strings.Join(strings.Fields(sql), " ") {
+		case "SELECT SERVER_VERSION()":
+			return &standin.Result{Columns: []tdquery.ColumnMeta{{Name: "server_version()", Type: tdquery.ColumnTypeBinary}}, Rows: [][]interface{}{{"2.6.0.0"}}}
+		case "SHOW DATABASES":
+			return &standin.Result{
+				Columns: []tdquery.ColumnMeta{{Name: "name", Type: tdquery.ColumnTypeBinary}, {Name: "precision", Type: tdquery.ColumnTypeBinary}},
+				Rows:    [][]interface{}{{"power", "us"}, {"test", "ms"}},
+			}
+		case "SELECT ts, v FROM meters":
+			return &standin.Result{
+				Columns: []tdquery.ColumnMeta{tsColumn, vColumn},
+				Rows:    [][]interface{}{{ts.UnixNano() / 1e3, 1.5}},
+			}
+		case "INSERT INTO d1 VALUES (NOW, 1)":
+			return standin.Affected(1)
+		}
+		return standin.Error(0x216, "syntax error near 'x'")
+	})
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	s := &shell{
+		config: config{brokers: []string{u.Hostname()}, port: port, database: database, urlDB: urlDB, timeout: 5 * time.Second},
+		format: formatTable,
+		out:    out,
+		errOut: errOut,
+	}
+	return s, server, out, errOut
+}
+
+func TestRunScript(t *testing.T) {
+	s, server, out, errOut := newShell(t, "power", true)
+	if err := s.connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s.format = formatCSV
+	if code := s.runScript("SELECT ts, v FROM meters; INSERT INTO d1 VALUES (NOW, 1);"); code != 0 {
+		t.Fatalf("exit code %d, %s", code, errOut)
+	}
+	want := "ts,v\n2023-11-14 22:13:20.123456,1.5\nQuery OK, 1 row(s) affected\n"
+	if out.String() != want {
+		t.Errorf("output %q, want %q", out, want)
+	}
+
+	// USE reads the precision of the database
+	out.Reset()
+	if code := s.runScript("USE test; SELECT ts, v FROM meters;"); code != 0 {
+		t.Fatalf("exit code %d, %s", code, errOut)
+	}
+	if !strings.HasPrefix(out.String(), "Database changed.\nts,v\n") || s.precision != tdquery.PrecisionMillisecond || s.config.database != "test" {
+		t.Errorf("output %q, precision %s", out, s.precision)
+	}
+
+	// the first error stops the script
+	server.Reset()
+	errOut.Reset()
+	if code := s.runScript("SELECT x; SELECT ts, v FROM meters;"); code != 1 {
+		t.Errorf("exit code %d", code)
+	}
+	if errOut.String() != "tdquery: error code 534 (0x216): syntax error near 'x'\n" || len(server.SQLs()) != 1 {
+		t.Errorf("error %q, sqls %q", errOut, server.SQLs())
+	}
+
+	errOut.Reset()
+	if code := s.runScript("USE missing"); code != 1 || !strings.Contains(errOut.String(), "unknown database missing") || s.config.database != "test" {
+		t.Errorf("exit code %d, error %q, database %s", code, errOut, s.config.database)
+	}
+}
+
+func TestUseWithoutUrlDB(t *testing.T) {
+	s, _, _, errOut := newShell(t, "", false)
+	s.config.precision = tdquery.PrecisionMicrosecond
+	if err := s.connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.precision != tdquery.PrecisionMicrosecond {
+		t.Errorf("precision %s", s.precision)
+	}
+	if code := s.runScript("USE power"); code != 1 || !strings.Contains(errOut.String(), "USE needs -url-db") {
+		t.Errorf("exit code %d, error %q", code, errOut)
+	}
+}
+
+func TestRepl(t *testing.T) {
+	s, _, out, _ := newShell(t, "power", true)
+	if err := s.connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s.repl(strings.NewReader("\\format vertical\nSELECT ts,\n  v FROM meters;\n\\history\n\\q\n"))
+	want := "Welcome to tdquery shell, type \\help for help.\n" +
+		"tdquery:power> tdquery:power>       -> " +
+		"*************************** 1. row ***************************\n" +
+		"ts: 2023-11-14 22:13:20.123456\n" +
+		" v: 1.5\n" +
+		"Query OK, 1 row(s) in set\n" +
+		"tdquery:power>     1  SELECT ts, v FROM meters\n" +
+		"tdquery:power> "
+	if out.String() != want {
+		t.Errorf("output %q\nwant %q", out, want)
+	}
+}
@@ -0,0 +1,134 @@
+package tdquery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ColumnType is the data type of a column, values are the same as `column_meta` returned by TDengine.
+type ColumnType int
+
+const (
+	_ ColumnType = iota
+	ColumnTypeBool
+	ColumnTypeTinyInt
+	ColumnTypeSmallInt
+	ColumnTypeInt
+	ColumnTypeBigInt
+	ColumnTypeFloat
+	ColumnTypeDouble
+	// ColumnTypeBinary is also VARCHAR in TDengine 3.x
+	ColumnTypeBinary
+	ColumnTypeTimestamp
+	ColumnTypeNchar
+	ColumnTypeUTinyInt
+	ColumnTypeUSmallInt
+	ColumnTypeUInt
+	ColumnTypeUBigInt
+	ColumnTypeJSON
+	// ColumnTypeVarBinary is only available in TDengine 3.x
+	ColumnTypeVarBinary
+	// ColumnTypeGeometry is only available in TDengine 3.x
+	ColumnTypeGeometry ColumnType = 20
+)
+
+var columnTypeNames = map[ColumnType]string{
+	ColumnTypeBool:      "BOOL",
+	ColumnTypeTinyInt:   "TINYINT",
+	ColumnTypeSmallInt:  "SMALLINT",
+	ColumnTypeInt:       "INT",
+	ColumnTypeBigInt:    "BIGINT",
+	ColumnTypeFloat:     "FLOAT",
+	ColumnTypeDouble:    "DOUBLE",
+	ColumnTypeBinary:    "BINARY",
+	ColumnTypeTimestamp: "TIMESTAMP",
+	ColumnTypeNchar:     "NCHAR",
+	ColumnTypeUTinyInt:  "TINYINT UNSIGNED",
+	ColumnTypeUSmallInt: "SMALLINT UNSIGNED",
+	ColumnTypeUInt:      "INT UNSIGNED",
+	ColumnTypeUBigInt:   "BIGINT UNSIGNED",
+	ColumnTypeJSON:      "JSON",
+	ColumnTypeVarBinary: "VARBINARY",
+	ColumnTypeGeometry:  "GEOMETRY",
+}
+
+func (t ColumnType) String() string {
+	if name, ok := columnTypeNames[t]; ok {
+		return name
+	}
+	return "UNKNOWN(" + strconv.Itoa(int(t)) + ")"
+}
+
+// HasLength reports whether the type needs a length, e.g. BINARY(64)
+func (t ColumnType) HasLength() bool {
+	switch t {
+	case ColumnTypeBinary, ColumnTypeNchar, ColumnTypeVarBinary, ColumnTypeGeometry:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseColumnType parses type names returned by DESCRIBE, e.g. `INT`, `VARCHAR`, `INT UNSIGNED`, `NCHAR(64)`
+func ParseColumnType(name string) (ColumnType, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if index := strings.Index(name, "("); index != -1 {
+		name = strings.TrimSpace(name[:index])
+	}
+	switch name {
+	case "VARCHAR":
+		return ColumnTypeBinary, nil
+	case "UTINYINT":
+		return ColumnTypeUTinyInt, nil
+	case "USMALLINT":
+		return ColumnTypeUSmallInt, nil
+	case "UINT":
+		return ColumnTypeUInt, nil
+	case "UBIGINT":
+		return ColumnTypeUBigInt, nil
+	}
+	for t, n := range columnTypeNames {
+		if n == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("%w, unknown column type %s", ErrInvalidColumn, name)
+}
+
+// Column defines a column or a tag, Length is required by BINARY, NCHAR, VARBINARY and GEOMETRY.
+type Column struct {
+	Name   string
+	Type   ColumnType
+	Length int
+}
+
+func NewColumn(name string, t ColumnType) Column {
+	return Column{Name: name, Type: t}
+}
+
+// NewSizedColumn defines a BINARY, NCHAR, VARBINARY or GEOMETRY column with length
+func NewSizedColumn(name string, t ColumnType, length int) Column {
+	return Column{Name: name, Type: t, Length: length}
+}
+
+func (c Column) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("%w, empty column name", ErrInvalidColumn)
+	}
+	if _, ok := columnTypeNames[c.Type]; !ok {
+		return fmt.Errorf("%w, column %s has unknown type %d", ErrInvalidColumn, c.Name, c.Type)
+	}
+	if c.Type.HasLength() && c.Length <= 0 {
+		return fmt.Errorf("%w, column %s of type %s needs length", ErrInvalidColumn, c.Name, c.Type)
+	}
+	return nil
+}
+
+// Definition returns column definition used in DDL: `name NCHAR(64)`
+func (c Column) Definition() string {
+	if c.Type.HasLength() {
+		return c.Name + " " + c.Type.String() + "(" + strconv.Itoa(c.Length) + ")"
+	}
+	return c.Name + " " + c.Type.String()
+}
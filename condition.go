@@ -19,6 +19,7 @@ var operatorMap = map[string]struct{}{
 	"IS NOT NULL": {},
 	"LIKE":        {},
 	"MATCH":       {},
+	"NMATCH":      {},
 	"BETWEEN":     {},
 }
 
@@ -31,24 +32,36 @@ type Condition struct {
 	ColumnName string
 	Operator   string
 	Value      interface{}
+	// timeColumn conditions use the time column of the select builder, see WithTimeScope
+	timeColumn bool
 }
 
 func (c *Condition) String() string {
 	return c.ColumnName + " " + c.Operator + " ? "
 }
 
+// Predicate is a boolean expression which can be used in WHERE and HAVING clauses.
+// *Condition is a Predicate, use And and Or to combine them.
+type Predicate interface {
+	appendPredicate(b *strings.Builder, params *[]interface{}) error
+}
+
 func (c *Condition) appendToBuilder(index int, b *strings.Builder, params *[]interface{}) error {
 	if b == nil {
 		return errors.New("tdquery: condition append to nil builder")
 	}
-	if !c.IsValid() {
-		return ErrInvalidCondition
-	}
 	if index > 0 {
 		b.WriteString(" AND ")
 	} else {
 		b.WriteString(" WHERE ")
 	}
+	return c.appendPredicate(b, params)
+}
+
+func (c *Condition) appendPredicate(b *strings.Builder, params *[]interface{}) error {
+	if !c.IsValid() {
+		return ErrInvalidCondition
+	}
 	b.WriteString(c.ColumnName)
 	b.WriteRune(' ')
 	b.WriteString(c.Operator)
@@ -142,3 +155,78 @@ func IsNotNull(column string) *Condition {
 		Operator:   "IS NOT NULL",
 	}
 }
+
+// Match filters string columns and tags by a POSIX regular expression, it is not anchored
+func Match(column, pattern string) *Condition {
+	return &Condition{
+		ColumnName: column,
+		Operator:   "MATCH",
+		Value:      pattern,
+	}
+}
+
+// NotMatch is the negation of Match
+func NotMatch(column, pattern string) *Condition {
+	return &Condition{
+		ColumnName: column,
+		Operator:   "NMATCH",
+		Value:      pattern,
+	}
+}
+
+type columnComparison struct {
+	left     string
+	operator string
+	right    string
+}
+
+func (c *columnComparison) appendPredicate(b *strings.Builder, params *[]interface{}) error {
+	if !isValidOperator(c.operator) {
+		return ErrInvalidCondition
+	}
+	b.WriteString(c.left)
+	b.WriteRune(' ')
+	b.WriteString(c.operator)
+	b.WriteRune(' ')
+	b.WriteString(c.right)
+	return nil
+}
+
+// EqualsColumn compares two columns: left = right, it is mostly used for JOIN conditions like `a.ts = b.ts`.
+func EqualsColumn(left, right string) Predicate {
+	return &columnComparison{left: left, operator: "=", right: right}
+}
+
+type logicalPredicate struct {
+	operator   string
+	predicates []Predicate
+}
+
+func (p *logicalPredicate) appendPredicate(b *strings.Builder, params *[]interface{}) error {
+	if len(p.predicates) == 0 {
+		return fmt.Errorf("%w empty %s", ErrInvalidCondition, p.operator)
+	}
+	b.WriteRune('(')
+	for i, predicate := range p.predicates {
+		if i > 0 {
+			b.WriteRune(' ')
+			b.WriteString(p.operator)
+			b.WriteRune(' ')
+		}
+		if err := predicate.appendPredicate(b, params); err != nil {
+			return err
+		}
+	}
+	b.WriteRune(')')
+	return nil
+}
+
+// And combines predicates with AND: (p1 AND p2 ...)
+func And(predicates ...Predicate) Predicate {
+	return &logicalPredicate{operator: "AND", predicates: predicates}
+}
+
+// Or combines predicates with OR: (p1 OR p2 ...)
+func Or(predicates ...Predicate) Predicate {
+	return &logicalPredicate{operator: "OR", predicates: predicates}
+}
@@ -0,0 +1,87 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var streamIDRegexp = regexp.MustCompile(`^[0-9]+:[0-9]+$`)
+
+// ContinuousQueryBuilder builds a continuous query of TDengine 2.x, which is `CREATE TABLE ... AS SELECT` with a window.
+// TDengine runs the query periodically and writes results into the table.
+//
+//	client.NewContinuousQuery("avg_current_1m").
+//		AsSelect(client.NewSelectQueryBuilder().
+//			Select(tdquery.Select{ColumnName: "AVG(current)", Alias: "current"}).
+//			FromSTable("meters").
+//			Interval(tdquery.NewInterval("1m")).
+//			Sliding("30s"))
+type ContinuousQueryBuilder struct {
+	c        *Client
+	table    string
+	database string
+	query    *SelectQueryBuilder
+}
+
+func (c *Client) NewContinuousQuery(table string) *ContinuousQueryBuilder {
+	return &ContinuousQueryBuilder{c: c, table: table, database: c.defaultDatabase()}
+}
+
+// UseDatabase sets database of the result table
+func (b *ContinuousQueryBuilder) UseDatabase(db string) *ContinuousQueryBuilder {
+	b.database = db
+	return b
+}
+
+// AsSelect sets the query, it must have an INTERVAL window
+func (b *ContinuousQueryBuilder) AsSelect(query *SelectQueryBuilder) *ContinuousQueryBuilder {
+	b.query = query
+	return b
+}
+
+// BuildWithParams generates sql with `?` placeholders of the query
+func (b *ContinuousQueryBuilder) BuildWithParams() (string, []interface{}, error) {
+	if b.table == "" {
+		return "", nil, fmt.Errorf("%w, empty table name", ErrInvalidDDL)
+	}
+	if b.query == nil {
+		return "", nil, fmt.Errorf("%w, continuous query %s has no query", ErrInvalidDDL, b.table)
+	}
+	if b.query.interval == nil {
+		return "", nil, fmt.Errorf("%w, continuous query %s needs INTERVAL", ErrInvalidDDL, b.table)
+	}
+	sql, params, err := b.query.BuildWithParams()
+	if err != nil {
+		return "", nil, err
+	}
+	builder := &strings.Builder{}
+	builder.WriteString("CREATE TABLE ")
+	builder.WriteString(qualifiedName(b.database, b.table))
+	builder.WriteString(" AS ")
+	builder.WriteString(sql)
+	return builder.String(), params, nil
+}
+
+func (b *ContinuousQueryBuilder) Build() (string, error) {
+	sql, _, err := b.BuildWithParams()
+	return sql, err
+}
+
+func (b *ContinuousQueryBuilder) Exec(ctx context.Context) error {
+	sql, params, err := b.BuildWithParams()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql, params...)
+}
+
+// KillStream stops a continuous query of TDengine 2.x, id is StreamInfo.ID like `3:1`.
+// The result table is kept, drop it by NewDropTable if it is not needed.
+func (c *Client) KillStream(ctx context.Context, id string) error {
+	if !streamIDRegexp.MatchString(id) {
+		return fmt.Errorf("%w, invalid stream id %q", ErrInvalidDDL, id)
+	}
+	return c.Exec(ctx, "KILL STREAM "+id)
+}
@@ -0,0 +1,82 @@
+package tdquery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestContinuousQuery(t *testing.T) {
+	c := NewClient(WithDatabase("power"))
+	avg := func() *SelectQueryBuilder {
+		return c.NewSelectQueryBuilder().Select(Select{ColumnName: "AVG(current)", Alias: "current"}).FromSTable("meters").
+			Where(NewCondition("voltage", ">", 200)).Interval(NewInterval("1m")).Sliding("30s")
+	}
+	cases := []struct {
+		name string
+		b    sqlBuilder
+		sql  string
+	}{
+		{"default database", c.NewContinuousQuery("avg_current").AsSelect(avg()),
+			`CREATE TABLE power.avg_current AS SELECT AVG(current) AS "current" FROM power.meters WHERE voltage > ? INTERVAL(1M) SLIDING(30S)`},
+		{"database", c.NewContinuousQuery("avg_current").UseDatabase("stats").AsSelect(avg().GroupBy("location")),
+			`CREATE TABLE stats.avg_current AS SELECT AVG(current) AS "current" FROM power.meters WHERE voltage > ? INTERVAL(1M) SLIDING(30S) GROUP BY location`},
+	}
+	for _, tc := range cases {
+		sql, err := tc.b.Build()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+	}
+
+	_, params, err := c.NewContinuousQuery("avg_current").AsSelect(avg()).BuildWithParams()
+	if err != nil || !reflect.DeepEqual(params, []interface{}{200}) {
+		t.Errorf("query params = %v, %v", params, err)
+	}
+
+	invalid := []struct {
+		name string
+		b    sqlBuilder
+		err  error
+	}{
+		{"empty table", c.NewContinuousQuery("").AsSelect(avg()), ErrInvalidDDL},
+		{"no query", c.NewContinuousQuery("avg_current"), ErrInvalidDDL},
+		{"no interval", c.NewContinuousQuery("avg_current").AsSelect(c.NewSelectQueryBuilder().SelectColumn("AVG(current)").FromSTable("meters")), ErrInvalidDDL},
+		{"sliding greater than interval", c.NewContinuousQuery("avg_current").AsSelect(avg().Sliding("2m")), ErrInvalidWindow},
+	}
+	for _, tc := range invalid {
+		if sql, err := tc.b.Build(); !errors.Is(err, tc.err) {
+			t.Errorf("%s: %s, %v", tc.name, sql, err)
+		}
+	}
+}
+
+func TestKillStream(t *testing.T) {
+	s := newWSStandIn(t, map[string]*standInResult{
+		"show dnodes":      dnodes3x,
+		"KILL STREAM 3:1":  {IsUpdate: true},
+		"KILL STREAM 12:0": {IsUpdate: true},
+	})
+	c := s.client(t, 1)
+	ctx := context.Background()
+	for _, id := range []string{"3:1", "12:0"} {
+		if err := c.KillStream(ctx, id); err != nil {
+			t.Errorf("KillStream(%q) = %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "3", "3:", ":1", "a:1", "3:1 ", "3:1; DROP DATABASE power"} {
+		if err := c.KillStream(ctx, id); !errors.Is(err, ErrInvalidDDL) {
+			t.Errorf("KillStream(%q) = %v", id, err)
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if want := []string{"show dnodes", "KILL STREAM 3:1", "KILL STREAM 12:0"}; !reflect.DeepEqual(s.sqls, want) {
+		t.Errorf("sqls = %v, want %v", s.sqls, want)
+	}
+}
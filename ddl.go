@@ -0,0 +1,567 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Precision string
+
+const (
+	PrecisionMillisecond Precision = "ms"
+	PrecisionMicrosecond Precision = "us"
+	PrecisionNanosecond  Precision = "ns"
+)
+
+// Unit returns the duration of a timestamp unit, it is millisecond for an empty or unknown precision.
+func (p Precision) Unit() time.Duration {
+	switch p {
+	case PrecisionMicrosecond:
+		return time.Microsecond
+	case PrecisionNanosecond:
+		return time.Nanosecond
+	default:
+		return time.Millisecond
+	}
+}
+
+// CacheModel is the cache model of a database, TDengine 3.x only
+type CacheModel string
+
+const (
+	CacheModelNone      CacheModel = "none"
+	CacheModelLastRow   CacheModel = "last_row"
+	CacheModelLastValue CacheModel = "last_value"
+	CacheModelBoth      CacheModel = "both"
+)
+
+func qualifiedName(database, name string) string {
+	if database == "" {
+		return name
+	}
+	return database + "." + name
+}
+
+func writeColumns(b *strings.Builder, columns []Column) error {
+	b.WriteRune('(')
+	for i, c := range columns {
+		if err := c.validate(); err != nil {
+			return err
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(c.Definition())
+	}
+	b.WriteRune(')')
+	return nil
+}
+
+// Exec runs a statement which returns no rows, error codes from TDengine are returned as *TDEngineError
+func (c *Client) Exec(ctx context.Context, sql string, params ...interface{}) error {
+	r, err := c.Query(ctx, sql, params...)
+	if err != nil {
+		return err
+	}
+	if r.Code != 0 {
+		return &TDEngineError{Code: r.Code, Message: r.Message}
+	}
+	return nil
+}
+
+// defaultDatabase is the database used by new builders, it is empty when database is chosen in url
+func (c *Client) defaultDatabase() string {
+	if c.useUrlDB {
+		return ""
+	}
+	return c.database
+}
+
+// DefaultDatabase returns the database qualifying table names of builders, it is empty with WithUrlDatabase
+func (c *Client) DefaultDatabase() string {
+	return c.defaultDatabase()
+}
+
+type dbOption struct {
+	key   string
+	value string
+}
+
+// CreateDatabaseBuilder builds `CREATE DATABASE`, options are written in the order they are set.
+// Some options only work with one TDengine major version, which is noted on each method.
+type CreateDatabaseBuilder struct {
+	c           *Client
+	name        string
+	ifNotExists bool
+	options     []dbOption
+}
+
+func (c *Client) NewCreateDatabase(name string) *CreateDatabaseBuilder {
+	return &CreateDatabaseBuilder{c: c, name: name}
+}
+
+func (b *CreateDatabaseBuilder) IfNotExists() *CreateDatabaseBuilder {
+	b.ifNotExists = true
+	return b
+}
+
+// Option sets an option not covered by other methods, e.g. Option("COMP", "2")
+func (b *CreateDatabaseBuilder) Option(key, value string) *CreateDatabaseBuilder {
+	key = strings.ToUpper(key)
+	for i, o := range b.options {
+		if o.key == key {
+			b.options[i].value = value
+			return b
+		}
+	}
+	b.options = append(b.options, dbOption{key: key, value: value})
+	return b
+}
+
+// Keep sets days to keep data, 2.x accepts up to 3 values: KEEP 30,60,90
+func (b *CreateDatabaseBuilder) Keep(days ...int) *CreateDatabaseBuilder {
+	values := make([]string, 0, len(days))
+	for _, d := range days {
+		values = append(values, strconv.Itoa(d))
+	}
+	return b.Option("KEEP", strings.Join(values, ","))
+}
+
+// Days sets days of a data file, TDengine 2.x only
+func (b *CreateDatabaseBuilder) Days(days int) *CreateDatabaseBuilder {
+	return b.Option("DAYS", strconv.Itoa(days))
+}
+
+// Duration sets time range of a data file like `10d`, TDengine 3.x only
+func (b *CreateDatabaseBuilder) Duration(duration string) *CreateDatabaseBuilder {
+	return b.Option("DURATION", duration)
+}
+
+func (b *CreateDatabaseBuilder) Precision(p Precision) *CreateDatabaseBuilder {
+	return b.Option("PRECISION", encodeString(string(p)))
+}
+
+func (b *CreateDatabaseBuilder) Replica(n int) *CreateDatabaseBuilder {
+	return b.Option("REPLICA", strconv.Itoa(n))
+}
+
+// CacheModel sets cache model, TDengine 3.x only
+func (b *CreateDatabaseBuilder) CacheModel(m CacheModel) *CreateDatabaseBuilder {
+	return b.Option("CACHEMODEL", encodeString(string(m)))
+}
+
+// VGroups sets number of vgroups, TDengine 3.x only
+func (b *CreateDatabaseBuilder) VGroups(n int) *CreateDatabaseBuilder {
+	return b.Option("VGROUPS", strconv.Itoa(n))
+}
+
+// WALLevel sets WAL level 1 or 2, it is `WAL_LEVEL` in TDengine 3.x, use Option("WAL", level) with 2.x
+func (b *CreateDatabaseBuilder) WALLevel(level int) *CreateDatabaseBuilder {
+	return b.Option("WAL_LEVEL", strconv.Itoa(level))
+}
+
+func (b *CreateDatabaseBuilder) Build() (string, error) {
+	if b.name == "" {
+		return "", fmt.Errorf("%w, empty database name", ErrInvalidDDL)
+	}
+	builder := &strings.Builder{}
+	builder.WriteString("CREATE DATABASE ")
+	if b.ifNotExists {
+		builder.WriteString("IF NOT EXISTS ")
+	}
+	builder.WriteString(b.name)
+	for _, o := range b.options {
+		builder.WriteRune(' ')
+		builder.WriteString(o.key)
+		builder.WriteRune(' ')
+		builder.WriteString(o.value)
+	}
+	return builder.String(), nil
+}
+
+func (b *CreateDatabaseBuilder) Exec(ctx context.Context) error {
+	sql, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql)
+}
+
+// CreateSTableBuilder builds `CREATE STABLE`, the first column must be TIMESTAMP
+type CreateSTableBuilder struct {
+	c           *Client
+	database    string
+	name        string
+	ifNotExists bool
+	columns     []Column
+	tags        []Column
+}
+
+func (c *Client) NewCreateSTable(name string) *CreateSTableBuilder {
+	return &CreateSTableBuilder{c: c, database: c.defaultDatabase(), name: name}
+}
+
+func (b *CreateSTableBuilder) UseDatabase(db string) *CreateSTableBuilder {
+	b.database = db
+	return b
+}
+
+func (b *CreateSTableBuilder) IfNotExists() *CreateSTableBuilder {
+	b.ifNotExists = true
+	return b
+}
+
+func (b *CreateSTableBuilder) Columns(columns ...Column) *CreateSTableBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+func (b *CreateSTableBuilder) Tags(tags ...Column) *CreateSTableBuilder {
+	b.tags = append(b.tags, tags...)
+	return b
+}
+
+func (b *CreateSTableBuilder) Build() (string, error) {
+	if b.name == "" {
+		return "", fmt.Errorf("%w, empty super table name", ErrInvalidDDL)
+	}
+	if len(b.columns) < 2 || b.columns[0].Type != ColumnTypeTimestamp {
+		return "", fmt.Errorf("%w, super table %s needs a TIMESTAMP column first and at least one more column", ErrInvalidDDL, b.name)
+	}
+	if len(b.tags) == 0 {
+		return "", fmt.Errorf("%w, super table %s needs at least one tag", ErrInvalidDDL, b.name)
+	}
+	builder := &strings.Builder{}
+	builder.WriteString("CREATE STABLE ")
+	if b.ifNotExists {
+		builder.WriteString("IF NOT EXISTS ")
+	}
+	builder.WriteString(qualifiedName(b.database, b.name))
+	builder.WriteRune(' ')
+	if err := writeColumns(builder, b.columns); err != nil {
+		return "", err
+	}
+	builder.WriteString(" TAGS ")
+	if err := writeColumns(builder, b.tags); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+func (b *CreateSTableBuilder) Exec(ctx context.Context) error {
+	sql, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql)
+}
+
+// CreateTableBuilder builds `CREATE TABLE` for normal tables with Columns,
+// or child tables with Using and Tags.
+type CreateTableBuilder struct {
+	c           *Client
+	database    string
+	name        string
+	ifNotExists bool
+	columns     []Column
+	sTable      string
+	tagNames    []string
+	tagValues   []interface{}
+}
+
+func (c *Client) NewCreateTable(name string) *CreateTableBuilder {
+	return &CreateTableBuilder{c: c, database: c.defaultDatabase(), name: name}
+}
+
+func (b *CreateTableBuilder) UseDatabase(db string) *CreateTableBuilder {
+	b.database = db
+	return b
+}
+
+func (b *CreateTableBuilder) IfNotExists() *CreateTableBuilder {
+	b.ifNotExists = true
+	return b
+}
+
+// Columns defines columns of a normal table
+func (b *CreateTableBuilder) Columns(columns ...Column) *CreateTableBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Using creates a child table of stable
+func (b *CreateTableBuilder) Using(stable string) *CreateTableBuilder {
+	b.sTable = stable
+	return b
+}
+
+// TagNames sets tag names for Tags, it is needed when only some of the tags are given.
+func (b *CreateTableBuilder) TagNames(names ...string) *CreateTableBuilder {
+	b.tagNames = append(b.tagNames, names...)
+	return b
+}
+
+// Tags sets tag values of a child table, values are encoded as query params.
+func (b *CreateTableBuilder) Tags(values ...interface{}) *CreateTableBuilder {
+	b.tagValues = append(b.tagValues, values...)
+	return b
+}
+
+// BuildWithParams generates sql with `?` placeholders for tag values
+func (b *CreateTableBuilder) BuildWithParams() (string, []interface{}, error) {
+	if b.name == "" {
+		return "", nil, fmt.Errorf("%w, empty table name", ErrInvalidDDL)
+	}
+	builder := &strings.Builder{}
+	builder.WriteString("CREATE TABLE ")
+	if b.ifNotExists {
+		builder.WriteString("IF NOT EXISTS ")
+	}
+	builder.WriteString(qualifiedName(b.database, b.name))
+	builder.WriteRune(' ')
+	if b.sTable == "" {
+		if len(b.tagValues) > 0 {
+			return "", nil, fmt.Errorf("%w, table %s has tags without super table", ErrInvalidDDL, b.name)
+		}
+		if len(b.columns) < 2 || b.columns[0].Type != ColumnTypeTimestamp {
+			return "", nil, fmt.Errorf("%w, table %s needs a TIMESTAMP column first and at least one more column", ErrInvalidDDL, b.name)
+		}
+		if err := writeColumns(builder, b.columns); err != nil {
+			return "", nil, err
+		}
+		return builder.String(), nil, nil
+	}
+	if len(b.columns) > 0 {
+		return "", nil, fmt.Errorf("%w, child table %s can not define columns", ErrInvalidDDL, b.name)
+	}
+	if len(b.tagValues) == 0 {
+		return "", nil, fmt.Errorf("%w, child table %s needs tag values", ErrInvalidDDL, b.name)
+	}
+	if len(b.tagNames) > 0 && len(b.tagNames) != len(b.tagValues) {
+		return "", nil, fmt.Errorf("%w, child table %s has %d tag names but %d tag values", ErrInvalidDDL, b.name, len(b.tagNames), len(b.tagValues))
+	}
+	builder.WriteString("USING ")
+	builder.WriteString(qualifiedName(b.database, b.sTable))
+	if len(b.tagNames) > 0 {
+		builder.WriteString(" (")
+		builder.WriteString(strings.Join(b.tagNames, ", "))
+		builder.WriteRune(')')
+	}
+	builder.WriteString(" TAGS (")
+	for i := range b.tagValues {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteRune('?')
+	}
+	builder.WriteRune(')')
+	return builder.String(), b.tagValues, nil
+}
+
+func (b *CreateTableBuilder) Build() (string, error) {
+	sql, _, err := b.BuildWithParams()
+	return sql, err
+}
+
+func (b *CreateTableBuilder) Exec(ctx context.Context) error {
+	sql, params, err := b.BuildWithParams()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql, params...)
+}
+
+// AlterTableBuilder builds `ALTER TABLE` or `ALTER STABLE`.
+// TDengine allows one change in an ALTER statement, so each method replaces the previous change.
+type AlterTableBuilder struct {
+	c        *Client
+	database string
+	name     string
+	sTable   bool
+	action   string
+	params   []interface{}
+	err      error
+}
+
+func (c *Client) NewAlterSTable(name string) *AlterTableBuilder {
+	return &AlterTableBuilder{c: c, database: c.defaultDatabase(), name: name, sTable: true}
+}
+
+func (c *Client) NewAlterTable(name string) *AlterTableBuilder {
+	return &AlterTableBuilder{c: c, database: c.defaultDatabase(), name: name}
+}
+
+func (b *AlterTableBuilder) UseDatabase(db string) *AlterTableBuilder {
+	b.database = db
+	return b
+}
+
+func (b *AlterTableBuilder) setAction(action string, params ...interface{}) *AlterTableBuilder {
+	b.action = action
+	b.params = params
+	b.err = nil
+	return b
+}
+
+func (b *AlterTableBuilder) setColumnAction(prefix string, column Column) *AlterTableBuilder {
+	if err := column.validate(); err != nil {
+		b.setAction("")
+		b.err = err
+		return b
+	}
+	return b.setAction(prefix + column.Definition())
+}
+
+func (b *AlterTableBuilder) AddColumn(column Column) *AlterTableBuilder {
+	return b.setColumnAction("ADD COLUMN ", column)
+}
+
+func (b *AlterTableBuilder) DropColumn(name string) *AlterTableBuilder {
+	return b.setAction("DROP COLUMN " + name)
+}
+
+// ModifyColumn changes length of a BINARY or NCHAR column, length can only be increased.
+func (b *AlterTableBuilder) ModifyColumn(column Column) *AlterTableBuilder {
+	return b.setColumnAction("MODIFY COLUMN ", column)
+}
+
+// AddTag adds a tag to a super table
+func (b *AlterTableBuilder) AddTag(tag Column) *AlterTableBuilder {
+	return b.setColumnAction("ADD TAG ", tag)
+}
+
+// DropTag drops a tag of a super table
+func (b *AlterTableBuilder) DropTag(name string) *AlterTableBuilder {
+	return b.setAction("DROP TAG " + name)
+}
+
+// ModifyTag changes length of a BINARY or NCHAR tag of a super table
+func (b *AlterTableBuilder) ModifyTag(tag Column) *AlterTableBuilder {
+	return b.setColumnAction("MODIFY TAG ", tag)
+}
+
+// RenameTag renames a tag of a super table, TDengine 3.x only, use ChangeTag with 2.x
+func (b *AlterTableBuilder) RenameTag(oldName, newName string) *AlterTableBuilder {
+	return b.setAction("RENAME TAG " + oldName + " " + newName)
+}
+
+// ChangeTag renames a tag of a super table, TDengine 2.x only, use RenameTag with 3.x
+func (b *AlterTableBuilder) ChangeTag(oldName, newName string) *AlterTableBuilder {
+	return b.setAction("CHANGE TAG " + oldName + " " + newName)
+}
+
+// SetTag changes tag value of a child table
+func (b *AlterTableBuilder) SetTag(name string, value interface{}) *AlterTableBuilder {
+	return b.setAction("SET TAG "+name+" = ?", value)
+}
+
+func (b *AlterTableBuilder) BuildWithParams() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if b.name == "" {
+		return "", nil, fmt.Errorf("%w, empty table name", ErrInvalidDDL)
+	}
+	if b.action == "" {
+		return "", nil, fmt.Errorf("%w, nothing to alter for %s", ErrInvalidDDL, b.name)
+	}
+	isTagAction := strings.HasSuffix(strings.Fields(b.action)[1], "TAG")
+	if b.sTable && strings.HasPrefix(b.action, "SET TAG") {
+		return "", nil, fmt.Errorf("%w, tag value can only be set on child table", ErrInvalidDDL)
+	}
+	if !b.sTable && isTagAction && !strings.HasPrefix(b.action, "SET TAG") {
+		return "", nil, fmt.Errorf("%w, tags can only be changed on super table", ErrInvalidDDL)
+	}
+	builder := &strings.Builder{}
+	if b.sTable {
+		builder.WriteString("ALTER STABLE ")
+	} else {
+		builder.WriteString("ALTER TABLE ")
+	}
+	builder.WriteString(qualifiedName(b.database, b.name))
+	builder.WriteRune(' ')
+	builder.WriteString(b.action)
+	return builder.String(), b.params, nil
+}
+
+func (b *AlterTableBuilder) Build() (string, error) {
+	sql, _, err := b.BuildWithParams()
+	return sql, err
+}
+
+func (b *AlterTableBuilder) Exec(ctx context.Context) error {
+	sql, params, err := b.BuildWithParams()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql, params...)
+}
+
+// DropBuilder builds `DROP DATABASE`, `DROP STABLE`, `DROP TABLE`, `DROP TOPIC` or `DROP STREAM`
+type DropBuilder struct {
+	c        *Client
+	kind     string
+	database string
+	names    []string
+	ifExists bool
+}
+
+func (c *Client) NewDropDatabase(name string) *DropBuilder {
+	return &DropBuilder{c: c, kind: "DATABASE", names: []string{name}}
+}
+
+func (c *Client) NewDropSTable(name string) *DropBuilder {
+	return &DropBuilder{c: c, kind: "STABLE", database: c.defaultDatabase(), names: []string{name}}
+}
+
+// NewDropTable drops tables, dropping many tables in one statement needs TDengine 3.x
+func (c *Client) NewDropTable(names ...string) *DropBuilder {
+	return &DropBuilder{c: c, kind: "TABLE", database: c.defaultDatabase(), names: names}
+}
+
+// UseDatabase sets database of tables, it is ignored when dropping databases, topics and streams
+func (b *DropBuilder) UseDatabase(db string) *DropBuilder {
+	if b.kind == "STABLE" || b.kind == "TABLE" {
+		b.database = db
+	}
+	return b
+}
+
+func (b *DropBuilder) IfExists() *DropBuilder {
+	b.ifExists = true
+	return b
+}
+
+func (b *DropBuilder) Build() (string, error) {
+	if len(b.names) == 0 {
+		return "", fmt.Errorf("%w, nothing to drop", ErrInvalidDDL)
+	}
+	builder := &strings.Builder{}
+	builder.WriteString("DROP ")
+	builder.WriteString(b.kind)
+	builder.WriteRune(' ')
+	for i, name := range b.names {
+		if name == "" {
+			return "", fmt.Errorf("%w, empty %s name", ErrInvalidDDL, strings.ToLower(b.kind))
+		}
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		if b.ifExists {
+			builder.WriteString("IF EXISTS ")
+		}
+		builder.WriteString(qualifiedName(b.database, name))
+	}
+	return builder.String(), nil
+}
+
+func (b *DropBuilder) Exec(ctx context.Context) error {
+	sql, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql)
+}
@@ -0,0 +1,120 @@
+package tdquery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type sqlBuilder interface {
+	Build() (string, error)
+}
+
+func TestDDL(t *testing.T) {
+	c := NewClient(WithDatabase("power"))
+	meters := []Column{NewColumn("ts", ColumnTypeTimestamp), NewColumn("current", ColumnTypeFloat), NewSizedColumn("note", ColumnTypeNchar, 16)}
+	cases := []struct {
+		name string
+		b    sqlBuilder
+		sql  string
+	}{
+		{"database", c.NewCreateDatabase("power").IfNotExists().Keep(365).Precision(PrecisionMicrosecond).Option("comp", "1").Option("KEEP", "30"),
+			"CREATE DATABASE IF NOT EXISTS power KEEP 30 PRECISION 'us' COMP 1"},
+		{"database 2.x", c.NewCreateDatabase("power").Days(10).Keep(30, 60, 90).Replica(1),
+			"CREATE DATABASE power DAYS 10 KEEP 30,60,90 REPLICA 1"},
+		{"database 3.x", c.NewCreateDatabase("power").Duration("10d").VGroups(4).CacheModel(CacheModelLastRow).WALLevel(2),
+			"CREATE DATABASE power DURATION 10d VGROUPS 4 CACHEMODEL 'last_row' WAL_LEVEL 2"},
+		{"super table", c.NewCreateSTable("meters").IfNotExists().Columns(meters...).Tags(NewColumn("group_id", ColumnTypeInt), NewSizedColumn("location", ColumnTypeBinary, 64)),
+			"CREATE STABLE IF NOT EXISTS power.meters (ts TIMESTAMP, current FLOAT, note NCHAR(16)) TAGS (group_id INT, location BINARY(64))"},
+		{"normal table", c.NewCreateTable("d0").UseDatabase("").Columns(meters...),
+			"CREATE TABLE d0 (ts TIMESTAMP, current FLOAT, note NCHAR(16))"},
+		{"child table", c.NewCreateTable("d1").IfNotExists().Using("meters").TagNames("location").Tags("sf"),
+			"CREATE TABLE IF NOT EXISTS power.d1 USING power.meters (location) TAGS (?)"},
+		{"add column", c.NewAlterSTable("meters").AddColumn(NewColumn("phase", ColumnTypeDouble)),
+			"ALTER STABLE power.meters ADD COLUMN phase DOUBLE"},
+		{"last change wins", c.NewAlterTable("d0").DropColumn("phase").ModifyColumn(NewSizedColumn("note", ColumnTypeNchar, 32)),
+			"ALTER TABLE power.d0 MODIFY COLUMN note NCHAR(32)"},
+		{"add tag", c.NewAlterSTable("meters").AddTag(NewSizedColumn("dc", ColumnTypeBinary, 8)),
+			"ALTER STABLE power.meters ADD TAG dc BINARY(8)"},
+		{"drop tag", c.NewAlterSTable("meters").DropTag("dc"), "ALTER STABLE power.meters DROP TAG dc"},
+		{"modify tag", c.NewAlterSTable("meters").ModifyTag(NewSizedColumn("location", ColumnTypeBinary, 128)),
+			"ALTER STABLE power.meters MODIFY TAG location BINARY(128)"},
+		{"rename tag 3.x", c.NewAlterSTable("meters").RenameTag("dc", "zone"), "ALTER STABLE power.meters RENAME TAG dc zone"},
+		{"rename tag 2.x", c.NewAlterSTable("meters").ChangeTag("dc", "zone"), "ALTER STABLE power.meters CHANGE TAG dc zone"},
+		{"set tag", c.NewAlterTable("d1").SetTag("location", "la"), "ALTER TABLE power.d1 SET TAG location = ?"},
+		{"drop database", c.NewDropDatabase("power").IfExists(), "DROP DATABASE IF EXISTS power"},
+		{"drop super table", c.NewDropSTable("meters").UseDatabase("test"), "DROP STABLE test.meters"},
+		{"drop tables", c.NewDropTable("d1", "d2").IfExists(), "DROP TABLE IF EXISTS power.d1, IF EXISTS power.d2"},
+	}
+	for _, tc := range cases {
+		sql, err := tc.b.Build()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+	}
+
+	_, params, err := c.NewCreateTable("d1").Using("meters").Tags(1, "sf").BuildWithParams()
+	if err != nil || !reflect.DeepEqual(params, []interface{}{1, "sf"}) {
+		t.Errorf("tag params = %v, %v", params, err)
+	}
+	_, params, err = c.NewAlterTable("d1").SetTag("location", "la").BuildWithParams()
+	if err != nil || !reflect.DeepEqual(params, []interface{}{"la"}) {
+		t.Errorf("set tag params = %v, %v", params, err)
+	}
+
+	invalid := []struct {
+		name string
+		b    sqlBuilder
+		err  error
+	}{
+		{"empty database", c.NewCreateDatabase(""), ErrInvalidDDL},
+		{"super table without timestamp", c.NewCreateSTable("meters").Columns(meters[1:]...).Tags(NewColumn("g", ColumnTypeInt)), ErrInvalidDDL},
+		{"super table without tags", c.NewCreateSTable("meters").Columns(meters...), ErrInvalidDDL},
+		{"column without length", c.NewCreateSTable("meters").Columns(meters[0], NewColumn("s", ColumnTypeBinary)).Tags(NewColumn("g", ColumnTypeInt)), ErrInvalidColumn},
+		{"normal table with tags", c.NewCreateTable("d0").Columns(meters...).Tags(1), ErrInvalidDDL},
+		{"child table with columns", c.NewCreateTable("d1").Using("meters").Columns(meters...).Tags(1), ErrInvalidDDL},
+		{"child table without tags", c.NewCreateTable("d1").Using("meters"), ErrInvalidDDL},
+		{"tag names mismatch", c.NewCreateTable("d1").Using("meters").TagNames("a", "b").Tags(1), ErrInvalidDDL},
+		{"nothing to alter", c.NewAlterTable("d0"), ErrInvalidDDL},
+		{"invalid column", c.NewAlterTable("d0").AddColumn(NewColumn("", ColumnTypeInt)), ErrInvalidColumn},
+		{"tag of normal table", c.NewAlterTable("d0").AddTag(NewColumn("g", ColumnTypeInt)), ErrInvalidDDL},
+		{"tag value of super table", c.NewAlterSTable("meters").SetTag("g", 1), ErrInvalidDDL},
+		{"nothing to drop", c.NewDropTable(), ErrInvalidDDL},
+		{"empty name to drop", c.NewDropTable("d1", ""), ErrInvalidDDL},
+	}
+	for _, tc := range invalid {
+		if _, err := tc.b.Build(); !errors.Is(err, tc.err) {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+
+	// a valid change clears the error of the previous one
+	if _, err := c.NewAlterTable("d0").AddColumn(NewColumn("", ColumnTypeInt)).DropColumn("x").Build(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDDLExec(t *testing.T) {
+	s := newWSStandIn(t, map[string]*standInResult{
+		"show dnodes": dnodes3x,
+		"CREATE TABLE IF NOT EXISTS power.d1 USING power.meters (location, group_id) TAGS ('it''s', 2)": {IsUpdate: true},
+		"ALTER TABLE power.d1 SET TAG location = 'la'":                                                  {IsUpdate: true},
+	})
+	c := s.client(t, 1)
+	ctx := context.Background()
+	if err := c.NewCreateTable("d1").UseDatabase("power").IfNotExists().Using("meters").TagNames("location", "group_id").Tags("it's", 2).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.NewAlterTable("d1").UseDatabase("power").SetTag("location", "la").Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var tdErr *TDEngineError
+	if err := c.NewDropTable("d1").UseDatabase("power").Exec(ctx); !errors.As(err, &tdErr) || tdErr.Code != 0x2662 {
+		t.Errorf("drop error %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package tdquery
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var typeDuration = reflect.TypeOf(time.Duration(0))
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// timeDecodeHook converts timestamps returned by /rest/sqlt (numbers in unit) or /rest/sqlutc (string)
+// into time.Time, and window durations (numbers in unit) into time.Duration.
+// unit is the precision of the database, see QueryResult.Precision.
+func timeDecodeHook(unit time.Duration) mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		switch to {
+		case typeTime:
+			return decodeTime(data, unit), nil
+		case typeDuration:
+			switch v := data.(type) {
+			case float64:
+				return time.Duration(v) * unit, nil
+			case int64:
+				return time.Duration(v) * unit, nil
+			}
+		}
+		return data, nil
+	}
+}
+
+// decodeTime converts a number in unit or a time string into time.Time, other values are returned as is.
+func decodeTime(data interface{}, unit time.Duration) interface{} {
+	switch v := data.(type) {
+	case float64:
+		return time.Unix(0, int64(v)*int64(unit))
+	case int64:
+		return time.Unix(0, v*int64(unit))
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+				return t
+			}
+		}
+	}
+	return data
+}
+
+var decodeKeys sync.Map
+
+// decodeKeysOf maps lowercase column names of td tags to keys of fields decoded by mapstructure,
+// so structs of SchemaOf and Structs can be decoded too. It is nil without td tags.
+func decodeKeysOf(v interface{}) map[string]string {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	if keys, ok := decodeKeys.Load(t); ok {
+		return keys.(map[string]string)
+	}
+	var keys map[string]string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup(structTag)
+		if !ok || tag == "-" || f.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		column := strings.TrimSpace(parts[0])
+		for _, option := range parts[1:] {
+			if strings.EqualFold(strings.TrimSpace(option), "tbname") {
+				column = ColumnTbname
+			}
+		}
+		if column == "" {
+			continue
+		}
+		key := f.Name
+		if name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]; name != "" {
+			key = name
+		}
+		if keys == nil {
+			keys = make(map[string]string)
+		}
+		keys[strings.ToLower(column)] = key
+	}
+	decodeKeys.Store(t, keys)
+	return keys
+}
+
+// decodeResult decodes rows into v, numeric timestamps and durations are in precision.
+func decodeResult(data []map[string]interface{}, precision Precision, v interface{}) error {
+	if keys := decodeKeysOf(v); keys != nil {
+		rows := make([]map[string]interface{}, 0, len(data))
+		for _, row := range data {
+			renamed := make(map[string]interface{}, len(row))
+			for column, value := range row {
+				if key, ok := keys[strings.ToLower(column)]; ok {
+					column = key
+				}
+				renamed[column] = value
+			}
+			rows = append(rows, renamed)
+		}
+		data = rows
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: timeDecodeHook(precision.Unit()),
+		Result:     v,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(data)
+}
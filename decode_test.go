@@ -0,0 +1,74 @@
+package tdquery
+
+import (
+	"testing"
+	"time"
+)
+
+type decodedSensor struct {
+	Table    string    `td:",tbname"`
+	Ts       time.Time `td:"ts,timestamp"`
+	Value    *float64  `td:"value"`
+	CityCode int32     `td:"city_code,tag"`
+	Location string    `td:"loc,tag" mapstructure:"location"`
+	Note     string    `mapstructure:"remark"`
+}
+
+func TestDecodeResultTdTags(t *testing.T) {
+	data := []map[string]interface{}{
+		{"tbname": "d1", "ts": float64(1700000000000), "value": 1.5, "city_code": float64(7), "LOC": "sf", "remark": "ok"},
+		{"TBNAME": "d2", "ts": float64(1700000001000), "value": nil, "city_code": float64(8), "loc": "la"},
+	}
+	var sensors []decodedSensor
+	if err := decodeResult(data, PrecisionMillisecond, &sensors); err != nil {
+		t.Fatal(err)
+	}
+	if len(sensors) != 2 {
+		t.Fatalf("decoded %+v", sensors)
+	}
+	first, second := sensors[0], sensors[1]
+	if first.Table != "d1" || !first.Ts.Equal(time.Unix(1700000000, 0)) || first.Value == nil || *first.Value != 1.5 ||
+		first.CityCode != 7 || first.Location != "sf" || first.Note != "ok" {
+		t.Errorf("first row %+v", first)
+	}
+	if second.Table != "d2" || !second.Ts.Equal(time.Unix(1700000001, 0)) || second.Value != nil || second.CityCode != 8 || second.Location != "la" {
+		t.Errorf("second row %+v", second)
+	}
+
+	// structs without td tags are decoded by mapstructure as before
+	var meters []tmqMeter
+	if err := decodeResult([]map[string]interface{}{{"ts": float64(1700000000000), "current": 2.5, "tbname": "d1"}}, PrecisionMillisecond, &meters); err != nil {
+		t.Fatal(err)
+	}
+	if len(meters) != 1 || meters[0].Table != "d1" || meters[0].Current == nil || *meters[0].Current != 2.5 {
+		t.Errorf("decoded %+v", meters)
+	}
+}
+
+func TestDecodeResultPrecision(t *testing.T) {
+	type window struct {
+		Start    time.Time     `mapstructure:"_wstart"`
+		Duration time.Duration `mapstructure:"_wduration"`
+	}
+	cases := []struct {
+		precision Precision
+		start     float64
+		duration  float64
+	}{
+		{PrecisionMillisecond, 1700000000123, 60000},
+		{PrecisionMicrosecond, 1700000000123456, 60000000},
+		{PrecisionNanosecond, 1700000000123456789, 60000000000},
+		{"", 1700000000123, 60000},
+	}
+	for _, tc := range cases {
+		var windows []window
+		data := []map[string]interface{}{{"_wstart": tc.start, "_wduration": tc.duration}}
+		if err := decodeResult(data, tc.precision, &windows); err != nil {
+			t.Fatal(err)
+		}
+		want := time.Unix(0, int64(tc.start)*int64(tc.precision.Unit()))
+		if len(windows) != 1 || !windows[0].Start.Equal(want) || windows[0].Duration != time.Minute {
+			t.Errorf("%q: decoded %+v", tc.precision, windows)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package tdquery
+
+import (
+	"reflect"
+	"sync"
+)
+
+// TDValuer is implemented by types which render themselves as TDengine sql literals,
+// the returned string is written into sql as is, so strings must be quoted and escaped.
+type TDValuer interface {
+	TDValue() (string, error)
+}
+
+// EncoderFunc renders a value of a registered type as a TDengine sql literal.
+type EncoderFunc func(value interface{}) (string, error)
+
+type encoderRegistry struct {
+	lock     sync.RWMutex
+	encoders map[reflect.Type]EncoderFunc
+}
+
+func newEncoderRegistry() *encoderRegistry {
+	return &encoderRegistry{encoders: make(map[reflect.Type]EncoderFunc)}
+}
+
+func (r *encoderRegistry) get(t reflect.Type) (EncoderFunc, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	e, ok := r.encoders[t]
+	return e, ok
+}
+
+func (r *encoderRegistry) set(t reflect.Type, encoder EncoderFunc) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.encoders[t] = encoder
+}
+
+// RegisterEncoder registers encoder for the type of sample, it is useful for types from other packages
+// which can not implement TDValuer. Registered encoders take precedence over TDValuer and driver.Valuer.
+func (c *Client) RegisterEncoder(sample interface{}, encoder EncoderFunc) {
+	c.encoders.set(reflect.TypeOf(sample), encoder)
+}
@@ -9,14 +9,34 @@ var ErrEmptySelect = errors.New("tdquery: select columns is empty")
 
 var ErrEmptyFrom = errors.New("tdquery: table and stable are both empty")
 
+var ErrEmptyUnion = errors.New("tdquery: union needs at least 2 select queries")
+
+var ErrUnionColumnMismatch = errors.New("tdquery: union select queries have different column numbers")
+
+var ErrInvalidJoin = errors.New("tdquery: invalid join")
+
+var ErrInvalidColumn = errors.New("tdquery: invalid column")
+
+var ErrInvalidDDL = errors.New("tdquery: invalid ddl")
+
 var ErrInvalidCondition = errors.New("tdquery: invalid condition")
 
+var ErrInvalidWindow = errors.New("tdquery: invalid window")
+
 var ErrorNoAvailableBroker = errors.New("tdquery: no available broker")
 
 var ErrorInvalidQueryArgsNumber = errors.New("tdquery: query param number not match")
 
 var ErrorInvalidQueryArgs = errors.New("tdquery: invalid query args")
 
+var ErrInvalidStatement = errors.New("tdquery: invalid statement")
+
+var ErrInvalidPoint = errors.New("tdquery: invalid point")
+
+var ErrEmptyInsert = errors.New("tdquery: insert has no values")
+
+var ErrUnknownDatabase = errors.New("tdquery: unknown database")
+
 type TDEngineError struct {
 	Code    int
 	Message string
@@ -25,3 +45,19 @@ type TDEngineError struct {
 func (e *TDEngineError) Error() string {
 	return fmt.Sprintf("tdquery: error from TDengine code: %d, message: %s", e.Code, e.Message)
 }
+
+// codes of `Table does not exist` of TDengine 2.x and 3.x
+const (
+	codeTableNotExistV2 = 0x362
+	codeTableNotExistV3 = 0x2603
+)
+
+// IsTableNotExist reports whether err is the `Table does not exist` error of TDengine 2.x or 3.x
+func IsTableNotExist(err error) bool {
+	var tdErr *TDEngineError
+	if !errors.As(err, &tdErr) {
+		return false
+	}
+	code := tdErr.Code & 0xffff
+	return code == codeTableNotExistV2 || code == codeTableNotExistV3
+}
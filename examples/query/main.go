@@ -47,12 +47,22 @@ func main() {
 		panic(err)
 	}
 	defer client.Close(context.Background())
-	r, err := client.Query(context.Background(), "CREATE DATABASE IF NOT EXISTS "+db)
-	handleQueryResult(r, err)
-	fmt.Printf("create database result : %+v\n", r)
-	r, err = client.Query(context.Background(), fmt.Sprintf("CREATE STABLE IF NOT EXISTS %s.%s (ts TIMESTAMP, value DOUBLE) TAGS (city_code INT)", db, stable))
-	handleQueryResult(r, err)
-	fmt.Printf("create stable result : %+v\n", r)
+	err := client.NewCreateDatabase(db).IfNotExists().Exec(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	err = client.NewCreateSTable(stable).
+		UseDatabase(db).
+		IfNotExists().
+		Columns(
+			tdquery.NewColumn("ts", tdquery.ColumnTypeTimestamp),
+			tdquery.NewColumn("value", tdquery.ColumnTypeDouble),
+		).
+		Tags(tdquery.NewColumn("city_code", tdquery.ColumnTypeInt)).
+		Exec(context.Background())
+	if err != nil {
+		panic(err)
+	}
 	runInserts(client)
 
 	ret := make([]Data, 0)
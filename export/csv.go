@@ -0,0 +1,46 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// WriteCSV writes a header of column names and then a record for each row, it returns the number of rows written.
+// Timestamps are formatted as RFC 3339 and NULL is an empty field,
+// numeric timestamps are read in the precision of src, see FromResult.
+func WriteCSV(w io.Writer, src Source) (int64, error) {
+	cw := csv.NewWriter(w)
+	columns := src.Columns()
+	unit := precisionOf(src).Unit()
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.Name
+	}
+	if err := cw.Write(record); err != nil {
+		return 0, err
+	}
+	var n int64
+	for src.Next() {
+		values := src.Values()
+		for i, c := range columns {
+			var v interface{}
+			if i < len(values) {
+				v = values[i]
+			}
+			nv, err := normalize(c, v, unit)
+			if err != nil {
+				return n, err
+			}
+			record[i] = formatValue(nv)
+		}
+		if err := cw.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, err
+	}
+	return n, src.Err()
+}
@@ -0,0 +1,93 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/standin"
+)
+
+func TestWriteCSV(t *testing.T) {
+	ts := time.Unix(1700000000, 123000000).UTC()
+	src := &sliceSource{
+		columns: []tdquery.ColumnMeta{
+			{Name: "ts", Type: tdquery.ColumnTypeTimestamp},
+			{Name: "on", Type: tdquery.ColumnTypeBool},
+			{Name: "b", Type: tdquery.ColumnTypeBigInt},
+			{Name: "ub", Type: tdquery.ColumnTypeUBigInt},
+			{Name: "d", Type: tdquery.ColumnTypeDouble},
+			{Name: "name", Type: tdquery.ColumnTypeNchar},
+		},
+		rows: [][]interface{}{
+			{ts, true, int64(-1), uint64(18446744073709551615), 0.5, "电表, \"1\""},
+			{ts.Add(time.Second), nil, nil, nil, "NaN", nil},
+		},
+	}
+	buf := &bytes.Buffer{}
+	n, err := WriteCSV(buf, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("WriteCSV() = %d rows", n)
+	}
+	want := "ts,on,b,ub,d,name\n" +
+		"2023-11-14T22:13:20.123Z,true,-1,18446744073709551615,0.5,\"电表, \"\"1\"\"\"\n" +
+		"2023-11-14T22:13:21.123Z,,,,NaN,\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteCSV() wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteCSVPrecision(t *testing.T) {
+	columns := []tdquery.ColumnMeta{{Name: "ts", Type: tdquery.ColumnTypeTimestamp}}
+	cases := []struct {
+		precision tdquery.Precision
+		value     int64
+		want      time.Time
+	}{
+		{"", 1700000000123, time.Unix(1700000000, 123000000)},
+		{tdquery.PrecisionMillisecond, 1700000000123, time.Unix(1700000000, 123000000)},
+		{tdquery.PrecisionMicrosecond, 1700000000123456, time.Unix(1700000000, 123456000)},
+		{tdquery.PrecisionNanosecond, 1700000000123456512, time.Unix(1700000000, 123456512)},
+	}
+	for _, c := range cases {
+		r := &tdquery.QueryResult{Columns: columns, Data: []map[string]interface{}{{"ts": float64(c.value)}}, Precision: c.precision}
+		buf := &bytes.Buffer{}
+		if _, err := WriteCSV(buf, FromResult(r)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), "ts\n"+c.want.Format(time.RFC3339Nano)+"\n"; got != want {
+			t.Errorf("%q: WriteCSV() wrote %q, want %q", c.precision, got, want)
+		}
+	}
+}
+
+func TestWriteCSVRows(t *testing.T) {
+	s := standin.New(t, func(sql string) *standin.Result {
+		return &standin.Result{
+			Columns: []tdquery.ColumnMeta{{Name: "ts", Type: tdquery.ColumnTypeTimestamp}, {Name: "v", Type: tdquery.ColumnTypeInt}},
+			Rows:    [][]interface{}{{int64(1700000000123456), 1}},
+		}
+	})
+	c := s.Client(t, tdquery.WithPrecision(tdquery.PrecisionMicrosecond))
+	rows, err := c.QueryRows(context.Background(), "SELECT ts, v FROM power.d1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if p := rows.Precision(); p != tdquery.PrecisionMicrosecond {
+		t.Errorf("Rows.Precision() = %s", p)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := WriteCSV(buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	want := "ts,v\n" + time.Unix(1700000000, 123456000).Format(time.RFC3339Nano) + ",1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteCSV() wrote %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,170 @@
+// Package export writes query results as CSV, JSON Lines or Parquet.
+//
+// Writers read rows from a Source one by one, use *tdquery.Rows to stream large results:
+//
+//	rows, err := client.QueryRows(ctx, "SELECT * FROM power.meters")
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	n, err := export.WriteParquet(file, rows)
+package export
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+// Source is a row iterator, it is implemented by *tdquery.Rows.
+type Source interface {
+	Columns() []tdquery.ColumnMeta
+	Next() bool
+	Values() []interface{}
+	Err() error
+}
+
+// precisionSource is implemented by sources which know the precision of numeric timestamps,
+// e.g. *tdquery.Rows and sources returned by FromResult.
+type precisionSource interface {
+	Precision() tdquery.Precision
+}
+
+// precisionOf returns the precision of numeric timestamps of src, it is empty when src does not tell.
+func precisionOf(src Source) tdquery.Precision {
+	if s, ok := src.(precisionSource); ok {
+		return s.Precision()
+	}
+	return ""
+}
+
+type resultSource struct {
+	r     *tdquery.QueryResult
+	index int
+	row   []interface{}
+}
+
+// FromResult returns a Source over rows of a QueryResult.
+// Numeric timestamps are read in the precision of the QueryResult.
+func FromResult(r *tdquery.QueryResult) Source {
+	return &resultSource{r: r, index: -1}
+}
+
+func (s *resultSource) Columns() []tdquery.ColumnMeta {
+	return s.r.Columns
+}
+
+func (s *resultSource) Next() bool {
+	s.index++
+	if s.index >= len(s.r.Data) {
+		return false
+	}
+	data := s.r.Data[s.index]
+	s.row = make([]interface{}, len(s.r.Columns))
+	for i, c := range s.r.Columns {
+		s.row[i] = data[c.Name]
+	}
+	return true
+}
+
+func (s *resultSource) Precision() tdquery.Precision {
+	return s.r.Precision
+}
+
+func (s *resultSource) Values() []interface{} {
+	return s.row
+}
+
+func (s *resultSource) Err() error {
+	return nil
+}
+
+// normalize converts a value to bool, int64, uint64, float64, string or time.Time by column type.
+// Values from QueryResult are float64 for numbers and timestamps in unit, values from Rows are already typed.
+func normalize(c tdquery.ColumnMeta, v interface{}, unit time.Duration) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch c.Type {
+	case tdquery.ColumnTypeBool:
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case float64:
+			return x != 0, nil
+		}
+	case tdquery.ColumnTypeTinyInt, tdquery.ColumnTypeSmallInt, tdquery.ColumnTypeInt, tdquery.ColumnTypeBigInt:
+		switch x := v.(type) {
+		case int64:
+			return x, nil
+		case uint64:
+			return int64(x), nil
+		case float64:
+			return int64(x), nil
+		}
+	case tdquery.ColumnTypeUTinyInt, tdquery.ColumnTypeUSmallInt, tdquery.ColumnTypeUInt, tdquery.ColumnTypeUBigInt:
+		switch x := v.(type) {
+		case uint64:
+			return x, nil
+		case int64:
+			return uint64(x), nil
+		case float64:
+			return uint64(x), nil
+		}
+	case tdquery.ColumnTypeFloat, tdquery.ColumnTypeDouble:
+		switch x := v.(type) {
+		case float64:
+			return x, nil
+		case float32:
+			return float64(x), nil
+		case string:
+			// NaN and Inf are returned as strings
+			return strconv.ParseFloat(x, 64)
+		}
+	case tdquery.ColumnTypeTimestamp:
+		switch x := v.(type) {
+		case time.Time:
+			return x, nil
+		case float64:
+			return time.Unix(0, int64(x)*int64(unit)), nil
+		case int64:
+			return time.Unix(0, x*int64(unit)), nil
+		}
+	default:
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case []byte:
+			return string(x), nil
+		}
+		return fmt.Sprint(v), nil
+	}
+	return nil, fmt.Errorf("export: unexpected value %v of type %T for column %s %s", v, v, c.Name, c.Type)
+}
+
+// formatValue formats a normalized value for text output, NULL is an empty string.
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case uint64:
+		return strconv.FormatUint(x, 10)
+	case float64:
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			return strconv.FormatFloat(x, 'g', -1, 64)
+		}
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case string:
+		return x
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,69 @@
+package export
+
+import (
+	"io"
+	"math"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// WriteJSONLines writes a JSON object for each row with keys in column order, it returns the number of rows written.
+// Timestamps are formatted as RFC 3339, NaN and Inf are written as strings because JSON can not represent them.
+// Numeric timestamps are read in the precision of src, see FromResult.
+func WriteJSONLines(w io.Writer, src Source) (int64, error) {
+	stream := jsoniter.NewStream(jsoniter.ConfigCompatibleWithStandardLibrary, w, 64*1024)
+	columns := src.Columns()
+	unit := precisionOf(src).Unit()
+	var n int64
+	for src.Next() {
+		values := src.Values()
+		stream.WriteObjectStart()
+		for i, c := range columns {
+			if i > 0 {
+				stream.WriteMore()
+			}
+			stream.WriteObjectField(c.Name)
+			var v interface{}
+			if i < len(values) {
+				v = values[i]
+			}
+			nv, err := normalize(c, v, unit)
+			if err != nil {
+				return n, err
+			}
+			switch x := nv.(type) {
+			case nil:
+				stream.WriteNil()
+			case bool:
+				stream.WriteBool(x)
+			case int64:
+				stream.WriteInt64(x)
+			case uint64:
+				stream.WriteUint64(x)
+			case float64:
+				if math.IsInf(x, 0) || math.IsNaN(x) {
+					stream.WriteString(formatValue(x))
+				} else {
+					stream.WriteFloat64(x)
+				}
+			case time.Time:
+				stream.WriteString(x.Format(time.RFC3339Nano))
+			case string:
+				stream.WriteString(x)
+			}
+		}
+		stream.WriteObjectEnd()
+		stream.WriteRaw("\n")
+		n++
+		if stream.Buffered() > 32*1024 {
+			if err := stream.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		return n, err
+	}
+	return n, src.Err()
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+func TestWriteJSONLines(t *testing.T) {
+	ts := time.Unix(1700000000, 123000000).UTC()
+	src := &sliceSource{
+		columns: []tdquery.ColumnMeta{
+			{Name: "ts", Type: tdquery.ColumnTypeTimestamp},
+			{Name: "on", Type: tdquery.ColumnTypeBool},
+			{Name: "b", Type: tdquery.ColumnTypeBigInt},
+			{Name: "ub", Type: tdquery.ColumnTypeUBigInt},
+			{Name: "d", Type: tdquery.ColumnTypeDouble},
+			{Name: "name", Type: tdquery.ColumnTypeNchar},
+		},
+		rows: [][]interface{}{
+			{ts, true, int64(-1), uint64(18446744073709551615), 0.5, "电表\n\"1\""},
+			{ts.Add(time.Second), nil, nil, nil, "-Inf", nil},
+		},
+	}
+	buf := &bytes.Buffer{}
+	n, err := WriteJSONLines(buf, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("WriteJSONLines() = %d rows", n)
+	}
+	want := `{"ts":"2023-11-14T22:13:20.123Z","on":true,"b":-1,"ub":18446744073709551615,"d":0.5,"name":"电表\n\"1\""}` + "\n" +
+		`{"ts":"2023-11-14T22:13:21.123Z","on":null,"b":null,"ub":null,"d":"-Inf","name":null}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteJSONLines() wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteJSONLinesPrecision(t *testing.T) {
+	columns := []tdquery.ColumnMeta{{Name: "ts", Type: tdquery.ColumnTypeTimestamp}}
+	cases := []struct {
+		precision tdquery.Precision
+		value     int64
+		want      time.Time
+	}{
+		{"", 1700000000123, time.Unix(1700000000, 123000000)},
+		{tdquery.PrecisionMillisecond, 1700000000123, time.Unix(1700000000, 123000000)},
+		{tdquery.PrecisionMicrosecond, 1700000000123456, time.Unix(1700000000, 123456000)},
+		{tdquery.PrecisionNanosecond, 1700000000123456512, time.Unix(1700000000, 123456512)},
+	}
+	for _, c := range cases {
+		r := &tdquery.QueryResult{Columns: columns, Data: []map[string]interface{}{{"ts": float64(c.value)}}, Precision: c.precision}
+		buf := &bytes.Buffer{}
+		if _, err := WriteJSONLines(buf, FromResult(r)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), `{"ts":"`+c.want.Format(time.RFC3339Nano)+`"}`+"\n"; got != want {
+			t.Errorf("%q: WriteJSONLines() wrote %q, want %q", c.precision, got, want)
+		}
+	}
+}
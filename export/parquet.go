@@ -0,0 +1,458 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+const parquetMagic = "PAR1"
+
+// physical types
+const (
+	parquetBoolean   int32 = 0
+	parquetInt32     int32 = 1
+	parquetInt64     int32 = 2
+	parquetFloat     int32 = 4
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+)
+
+// converted types, kept for readers not knowing logical types
+const (
+	convertedNone            int32 = -1
+	convertedUTF8            int32 = 0
+	convertedTimestampMillis int32 = 9
+	convertedTimestampMicros int32 = 10
+	convertedUint8           int32 = 11
+	convertedUint16          int32 = 12
+	convertedUint32          int32 = 13
+	convertedUint64          int32 = 14
+	convertedInt8            int32 = 15
+	convertedInt16           int32 = 16
+	convertedInt32           int32 = 17
+	convertedInt64           int32 = 18
+	convertedJSON            int32 = 19
+)
+
+// logical type union field ids
+const (
+	logicalNone      int16 = 0
+	logicalString    int16 = 1
+	logicalTimestamp int16 = 8
+	logicalInteger   int16 = 10
+	logicalJSON      int16 = 12
+)
+
+// time unit union field ids of timestamp logical type
+const (
+	unitMillis int16 = 1
+	unitMicros int16 = 2
+	unitNanos  int16 = 3
+)
+
+const (
+	encodingPlain int32 = 0
+	encodingRLE   int32 = 3
+
+	repetitionOptional int32 = 1
+	pageTypeData       int32 = 0
+	codecUncompressed  int32 = 0
+)
+
+type parquetType struct {
+	physical  int32
+	converted int32
+	logical   int16
+	bitWidth  int8
+	signed    bool
+	// unit of timestamps
+	unit int16
+}
+
+// parquetTypeOf maps TDengine types to parquet types, BINARY is written as string because it is
+// a single-byte string in TDengine and VARCHAR in 3.x. TIMESTAMP is in the unit of precision.
+func parquetTypeOf(t tdquery.ColumnType, precision tdquery.Precision) parquetType {
+	switch t {
+	case tdquery.ColumnTypeBool:
+		return parquetType{physical: parquetBoolean, converted: convertedNone}
+	case tdquery.ColumnTypeTinyInt:
+		return parquetType{physical: parquetInt32, converted: convertedInt8, logical: logicalInteger, bitWidth: 8, signed: true}
+	case tdquery.ColumnTypeSmallInt:
+		return parquetType{physical: parquetInt32, converted: convertedInt16, logical: logicalInteger, bitWidth: 16, signed: true}
+	case tdquery.ColumnTypeInt:
+		return parquetType{physical: parquetInt32, converted: convertedInt32, logical: logicalInteger, bitWidth: 32, signed: true}
+	case tdquery.ColumnTypeBigInt:
+		return parquetType{physical: parquetInt64, converted: convertedInt64, logical: logicalInteger, bitWidth: 64, signed: true}
+	case tdquery.ColumnTypeUTinyInt:
+		return parquetType{physical: parquetInt32, converted: convertedUint8, logical: logicalInteger, bitWidth: 8, signed: false}
+	case tdquery.ColumnTypeUSmallInt:
+		return parquetType{physical: parquetInt32, converted: convertedUint16, logical: logicalInteger, bitWidth: 16, signed: false}
+	case tdquery.ColumnTypeUInt:
+		return parquetType{physical: parquetInt32, converted: convertedUint32, logical: logicalInteger, bitWidth: 32, signed: false}
+	case tdquery.ColumnTypeUBigInt:
+		return parquetType{physical: parquetInt64, converted: convertedUint64, logical: logicalInteger, bitWidth: 64, signed: false}
+	case tdquery.ColumnTypeFloat:
+		return parquetType{physical: parquetFloat, converted: convertedNone}
+	case tdquery.ColumnTypeDouble:
+		return parquetType{physical: parquetDouble, converted: convertedNone}
+	case tdquery.ColumnTypeTimestamp:
+		switch precision {
+		case tdquery.PrecisionMicrosecond:
+			return parquetType{physical: parquetInt64, converted: convertedTimestampMicros, logical: logicalTimestamp, unit: unitMicros}
+		case tdquery.PrecisionNanosecond:
+			// there is no converted type of nanoseconds
+			return parquetType{physical: parquetInt64, converted: convertedNone, logical: logicalTimestamp, unit: unitNanos}
+		}
+		return parquetType{physical: parquetInt64, converted: convertedTimestampMillis, logical: logicalTimestamp, unit: unitMillis}
+	case tdquery.ColumnTypeJSON:
+		return parquetType{physical: parquetByteArray, converted: convertedJSON, logical: logicalJSON}
+	case tdquery.ColumnTypeVarBinary, tdquery.ColumnTypeGeometry:
+		return parquetType{physical: parquetByteArray, converted: convertedNone}
+	default:
+		return parquetType{physical: parquetByteArray, converted: convertedUTF8, logical: logicalString}
+	}
+}
+
+type parquetColumn struct {
+	meta tdquery.ColumnMeta
+	name string
+	typ  parquetType
+	// unit of numeric timestamps of the source
+	unit    time.Duration
+	defined []bool
+	bools   []bool
+	values  bytes.Buffer
+}
+
+type columnChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+type rowGroup struct {
+	rows    int64
+	size    int64
+	columns []columnChunk
+}
+
+type parquetOptions struct {
+	rowGroupSize int
+	precision    tdquery.Precision
+}
+
+// ParquetOption configures WriteParquet
+type ParquetOption func(o *parquetOptions)
+
+// WithRowGroupSize sets the number of rows buffered in memory for each row group, default is 65536.
+func WithRowGroupSize(rows int) ParquetOption {
+	return func(o *parquetOptions) {
+		if rows > 0 {
+			o.rowGroupSize = rows
+		}
+	}
+}
+
+// WithPrecision sets the unit TIMESTAMP is written in, so microseconds and nanoseconds are kept.
+// Default is the precision of src, e.g. Rows.Precision, or millisecond when src does not tell.
+// Numeric timestamps of a src which does not tell its precision are read in this unit.
+func WithPrecision(precision tdquery.Precision) ParquetOption {
+	return func(o *parquetOptions) {
+		o.precision = precision
+	}
+}
+
+type parquetWriter struct {
+	w         io.Writer
+	offset    int64
+	columns   []*parquetColumn
+	rows      int64
+	groups    []rowGroup
+	groupRows int64
+}
+
+// WriteParquet writes rows as an uncompressed parquet file, it returns the number of rows written.
+// Only one row group is kept in memory. All columns are optional, TIMESTAMP is written as
+// time since epoch in UTC in the unit set by WithPrecision or the precision of src, integers keep their width and signedness,
+// NCHAR and BINARY are strings.
+func WriteParquet(w io.Writer, src Source, opts ...ParquetOption) (int64, error) {
+	o := &parquetOptions{rowGroupSize: 64 * 1024}
+	for _, opt := range opts {
+		opt(o)
+	}
+	// numbers are in the precision of the source, which may not be millisecond
+	input := precisionOf(src)
+	if input == "" {
+		input = o.precision
+	}
+	if o.precision == "" {
+		o.precision = input
+	}
+	pw := &parquetWriter{w: w}
+	names := make(map[string]int)
+	for _, c := range src.Columns() {
+		// parquet needs unique column names, e.g. ts of both tables in a join
+		name := c.Name
+		if n := names[c.Name]; n > 0 {
+			name = c.Name + "_" + strconv.Itoa(n)
+		}
+		names[c.Name]++
+		pw.columns = append(pw.columns, &parquetColumn{meta: c, name: name, typ: parquetTypeOf(c.Type, o.precision), unit: input.Unit()})
+	}
+	if err := pw.write([]byte(parquetMagic)); err != nil {
+		return 0, err
+	}
+	for src.Next() {
+		values := src.Values()
+		for i, c := range pw.columns {
+			var v interface{}
+			if i < len(values) {
+				v = values[i]
+			}
+			if err := c.append(v); err != nil {
+				return pw.rows, err
+			}
+		}
+		pw.rows++
+		pw.groupRows++
+		if pw.groupRows >= int64(o.rowGroupSize) {
+			if err := pw.flush(); err != nil {
+				return pw.rows, err
+			}
+		}
+	}
+	if err := src.Err(); err != nil {
+		return pw.rows, err
+	}
+	if err := pw.flush(); err != nil {
+		return pw.rows, err
+	}
+	return pw.rows, pw.close()
+}
+
+func (pw *parquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+func (c *parquetColumn) append(v interface{}) error {
+	nv, err := normalize(c.meta, v, c.unit)
+	if err != nil {
+		return err
+	}
+	if nv == nil {
+		c.defined = append(c.defined, false)
+		return nil
+	}
+	c.defined = append(c.defined, true)
+	var b [8]byte
+	switch c.typ.physical {
+	case parquetBoolean:
+		c.bools = append(c.bools, nv.(bool))
+	case parquetInt32:
+		var i32 uint32
+		switch x := nv.(type) {
+		case int64:
+			i32 = uint32(int32(x))
+		case uint64:
+			i32 = uint32(x)
+		}
+		binary.LittleEndian.PutUint32(b[:4], i32)
+		c.values.Write(b[:4])
+	case parquetInt64:
+		var i64 uint64
+		switch x := nv.(type) {
+		case int64:
+			i64 = uint64(x)
+		case uint64:
+			i64 = x
+		case time.Time:
+			i64 = uint64(toUnit(x, c.typ.unit))
+		}
+		binary.LittleEndian.PutUint64(b[:], i64)
+		c.values.Write(b[:])
+	case parquetFloat:
+		binary.LittleEndian.PutUint32(b[:4], math.Float32bits(float32(nv.(float64))))
+		c.values.Write(b[:4])
+	case parquetDouble:
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(nv.(float64)))
+		c.values.Write(b[:])
+	default:
+		s := nv.(string)
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(s)))
+		c.values.Write(b[:4])
+		c.values.WriteString(s)
+	}
+	return nil
+}
+
+func unitDuration(unit int16) int64 {
+	switch unit {
+	case unitMicros:
+		return int64(time.Microsecond)
+	case unitNanos:
+		return 1
+	}
+	return int64(time.Millisecond)
+}
+
+func toUnit(t time.Time, unit int16) int64 {
+	return t.UnixNano() / unitDuration(unit)
+}
+
+// page returns a data page holding definition levels and plain encoded values, and resets the column
+func (c *parquetColumn) page() []byte {
+	levels := &bytes.Buffer{}
+	var b [binary.MaxVarintLen64]byte
+	// RLE runs of bit width 1
+	for i := 0; i < len(c.defined); {
+		j := i
+		for j < len(c.defined) && c.defined[j] == c.defined[i] {
+			j++
+		}
+		n := binary.PutUvarint(b[:], uint64(j-i)<<1)
+		levels.Write(b[:n])
+		if c.defined[i] {
+			levels.WriteByte(1)
+		} else {
+			levels.WriteByte(0)
+		}
+		i = j
+	}
+	body := &bytes.Buffer{}
+	binary.Write(body, binary.LittleEndian, uint32(levels.Len()))
+	body.Write(levels.Bytes())
+	if c.typ.physical == parquetBoolean {
+		packed := make([]byte, (len(c.bools)+7)/8)
+		for i, v := range c.bools {
+			if v {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		body.Write(packed)
+	} else {
+		body.Write(c.values.Bytes())
+	}
+
+	t := &thriftWriter{}
+	t.structBegin()
+	t.i32Field(1, pageTypeData)
+	t.i32Field(2, int32(body.Len()))
+	t.i32Field(3, int32(body.Len()))
+	t.structField(5)
+	t.i32Field(1, int32(len(c.defined)))
+	t.i32Field(2, encodingPlain)
+	t.i32Field(3, encodingRLE)
+	t.i32Field(4, encodingRLE)
+	t.structEnd()
+	t.structEnd()
+
+	c.defined = c.defined[:0]
+	c.bools = c.bools[:0]
+	c.values.Reset()
+	return append(t.buf.Bytes(), body.Bytes()...)
+}
+
+// flush writes buffered rows as a row group
+func (pw *parquetWriter) flush() error {
+	if pw.groupRows == 0 {
+		return nil
+	}
+	group := rowGroup{rows: pw.groupRows}
+	for _, c := range pw.columns {
+		numValues := int64(len(c.defined))
+		page := c.page()
+		chunk := columnChunk{offset: pw.offset, size: int64(len(page)), numValues: numValues}
+		if err := pw.write(page); err != nil {
+			return err
+		}
+		group.size += chunk.size
+		group.columns = append(group.columns, chunk)
+	}
+	pw.groups = append(pw.groups, group)
+	pw.groupRows = 0
+	return nil
+}
+
+// close writes file metadata and the footer
+func (pw *parquetWriter) close() error {
+	t := &thriftWriter{}
+	t.structBegin()
+	t.i32Field(1, 1)
+	t.listField(2, thriftStruct, len(pw.columns)+1)
+	t.structBegin()
+	t.stringField(4, "schema")
+	t.i32Field(5, int32(len(pw.columns)))
+	t.structEnd()
+	for _, c := range pw.columns {
+		t.structBegin()
+		t.i32Field(1, c.typ.physical)
+		t.i32Field(3, repetitionOptional)
+		t.stringField(4, c.name)
+		if c.typ.converted != convertedNone {
+			t.i32Field(6, c.typ.converted)
+		}
+		if c.typ.logical != logicalNone {
+			t.structField(10)
+			t.structField(c.typ.logical)
+			switch c.typ.logical {
+			case logicalTimestamp:
+				t.boolField(1, true)
+				t.structField(2)
+				t.structField(c.typ.unit)
+				t.structEnd()
+				t.structEnd()
+			case logicalInteger:
+				t.byteField(1, c.typ.bitWidth)
+				t.boolField(2, c.typ.signed)
+			}
+			t.structEnd()
+			t.structEnd()
+		}
+		t.structEnd()
+	}
+	t.i64Field(3, pw.rows)
+	t.listField(4, thriftStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		t.structBegin()
+		t.listField(1, thriftStruct, len(g.columns))
+		for i, chunk := range g.columns {
+			c := pw.columns[i]
+			t.structBegin()
+			t.i64Field(2, chunk.offset)
+			t.structField(3)
+			t.i32Field(1, c.typ.physical)
+			t.listField(2, thriftI32, 2)
+			t.zigzag(int64(encodingPlain))
+			t.zigzag(int64(encodingRLE))
+			t.listField(3, thriftBinary, 1)
+			t.str(c.name)
+			t.i32Field(4, codecUncompressed)
+			t.i64Field(5, chunk.numValues)
+			t.i64Field(6, chunk.size)
+			t.i64Field(7, chunk.size)
+			t.i64Field(9, chunk.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64Field(2, g.size)
+		t.i64Field(3, g.rows)
+		t.structEnd()
+	}
+	t.stringField(6, "tdquery")
+	t.structEnd()
+
+	footer := t.buf.Bytes()
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	footer = append(footer, size[:]...)
+	footer = append(footer, parquetMagic...)
+	return pw.write(footer)
+}
@@ -0,0 +1,315 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+)
+
+// thriftReader reads thrift compact protocol into maps of field ids for structs and slices for lists
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftByte:
+		return int8(r.byte())
+	case 4, thriftI32, thriftI64:
+		return r.zigzag()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos:]))
+		r.pos += 8
+		return v
+	case thriftBinary:
+		n := int(r.varint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		size, elem := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			if elem == thriftTrue || elem == thriftFalse {
+				list[i] = r.byte() == thriftTrue
+			} else {
+				list[i] = r.value(elem)
+			}
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %d", typ))
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	last := int16(0)
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+type parquetFile struct {
+	rows    int64
+	schema  []map[int16]interface{}
+	groups  int
+	columns [][]interface{}
+}
+
+// readParquet decodes a file written by WriteParquet: plain values and definition levels of RLE runs
+func readParquet(data []byte) (*parquetFile, error) {
+	if !bytes.HasPrefix(data, []byte(parquetMagic)) || !bytes.HasSuffix(data, []byte(parquetMagic)) {
+		return nil, errors.New("no magic")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	r := &thriftReader{b: data[len(data)-8-size : len(data)-8]}
+	meta := r.structure()
+	if r.pos != size {
+		return nil, fmt.Errorf("footer has %d bytes, read %d", size, r.pos)
+	}
+	f := &parquetFile{rows: meta[3].(int64)}
+	for _, s := range meta[2].([]interface{})[1:] {
+		f.schema = append(f.schema, s.(map[int16]interface{}))
+	}
+	f.columns = make([][]interface{}, len(f.schema))
+	groups := meta[4].([]interface{})
+	f.groups = len(groups)
+	for _, g := range groups {
+		for i, chunk := range g.(map[int16]interface{})[1].([]interface{}) {
+			chunkMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			values, err := readPage(data[chunkMeta[9].(int64):], f.schema[i][1].(int64))
+			if err != nil {
+				return nil, err
+			}
+			if int64(len(values)) != chunkMeta[5].(int64) {
+				return nil, fmt.Errorf("column %d has %d values, want %d", i, len(values), chunkMeta[5])
+			}
+			f.columns[i] = append(f.columns[i], values...)
+		}
+	}
+	return f, nil
+}
+
+func readPage(data []byte, physical int64) ([]interface{}, error) {
+	r := &thriftReader{b: data}
+	header := r.structure()
+	body := data[r.pos : r.pos+int(header[3].(int64))]
+	count := int(header[5].(map[int16]interface{})[1].(int64))
+	levelsSize := int(binary.LittleEndian.Uint32(body))
+	levels := &thriftReader{b: body[4 : 4+levelsSize]}
+	defined := make([]bool, 0, count)
+	for levels.pos < len(levels.b) {
+		run := levels.varint()
+		if run&1 != 0 {
+			return nil, errors.New("unexpected bit packed run")
+		}
+		v := levels.byte() == 1
+		for i := uint64(0); i < run>>1; i++ {
+			defined = append(defined, v)
+		}
+	}
+	if len(defined) != count {
+		return nil, fmt.Errorf("%d definition levels, want %d", len(defined), count)
+	}
+	values := body[4+levelsSize:]
+	result := make([]interface{}, count)
+	n := 0
+	for i, d := range defined {
+		if !d {
+			continue
+		}
+		switch int32(physical) {
+		case parquetBoolean:
+			result[i] = values[n/8]&(1<<(n%8)) != 0
+			n++
+		case parquetInt32:
+			result[i] = int32(binary.LittleEndian.Uint32(values))
+			values = values[4:]
+		case parquetInt64:
+			result[i] = int64(binary.LittleEndian.Uint64(values))
+			values = values[8:]
+		case parquetFloat:
+			result[i] = math.Float32frombits(binary.LittleEndian.Uint32(values))
+			values = values[4:]
+		case parquetDouble:
+			result[i] = math.Float64frombits(binary.LittleEndian.Uint64(values))
+			values = values[8:]
+		default:
+			size := int(binary.LittleEndian.Uint32(values))
+			result[i] = string(values[4 : 4+size])
+			values = values[4+size:]
+		}
+	}
+	return result, nil
+}
+
+type sliceSource struct {
+	columns []tdquery.ColumnMeta
+	rows    [][]interface{}
+	index   int
+}
+
+func (s *sliceSource) Columns() []tdquery.ColumnMeta { return s.columns }
+func (s *sliceSource) Next() bool                    { s.index++; return s.index <= len(s.rows) }
+func (s *sliceSource) Values() []interface{}         { return s.rows[s.index-1] }
+func (s *sliceSource) Err() error                    { return nil }
+
+func TestWriteParquet(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	src := &sliceSource{
+		columns: []tdquery.ColumnMeta{
+			{Name: "ts", Type: tdquery.ColumnTypeTimestamp},
+			{Name: "on", Type: tdquery.ColumnTypeBool},
+			{Name: "t", Type: tdquery.ColumnTypeTinyInt},
+			{Name: "u", Type: tdquery.ColumnTypeUInt},
+			{Name: "b", Type: tdquery.ColumnTypeBigInt},
+			{Name: "ub", Type: tdquery.ColumnTypeUBigInt},
+			{Name: "f", Type: tdquery.ColumnTypeFloat},
+			{Name: "d", Type: tdquery.ColumnTypeDouble},
+			{Name: "name", Type: tdquery.ColumnTypeNchar},
+			{Name: "ts", Type: tdquery.ColumnTypeTimestamp},
+		},
+		rows: [][]interface{}{
+			{ts, true, int64(-8), uint64(4000000000), int64(-1) << 40, uint64(math.MaxUint64), 0.5, 1.25, "电表", nil},
+			{ts.Add(time.Second), nil, nil, nil, nil, nil, nil, nil, nil, nil},
+			{ts.Add(2 * time.Second), false, int64(127), uint64(0), int64(1), uint64(1), "NaN", -2.5, "", ts},
+		},
+	}
+	buf := &bytes.Buffer{}
+	n, err := WriteParquet(buf, src, WithRowGroupSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("WriteParquet() = %d rows", n)
+	}
+	f, err := readParquet(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.rows != 3 || f.groups != 2 {
+		t.Fatalf("file has %d rows in %d row groups", f.rows, f.groups)
+	}
+	names := make([]string, 0)
+	for _, s := range f.schema {
+		names = append(names, s[4].(string))
+	}
+	if want := []string{"ts", "on", "t", "u", "b", "ub", "f", "d", "name", "ts_1"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("column names = %v, want %v", names, want)
+	}
+	ms := ts.UnixNano() / int64(time.Millisecond)
+	nan := f.columns[6][2].(float32)
+	if !math.IsNaN(float64(nan)) {
+		t.Errorf("NaN is written as %v", nan)
+	}
+	f.columns[6][2] = nil
+	want := [][]interface{}{
+		{ms, ms + 1000, ms + 2000},
+		{true, nil, false},
+		{int32(-8), nil, int32(127)},
+		{int32(-294967296), nil, int32(0)},
+		{int64(-1) << 40, nil, int64(1)},
+		{int64(-1), nil, int64(1)},
+		{float32(0.5), nil, nil},
+		{1.25, nil, -2.5},
+		{"电表", nil, ""},
+		{nil, nil, ms},
+	}
+	if !reflect.DeepEqual(f.columns, want) {
+		t.Errorf("columns = %v\nwant %v", f.columns, want)
+	}
+}
+
+func TestWriteParquetPrecision(t *testing.T) {
+	// nanoseconds can be represented by float64 numbers of QueryResult
+	ts := time.Unix(1700000000, 123456512)
+	columns := []tdquery.ColumnMeta{{Name: "ts", Type: tdquery.ColumnTypeTimestamp}}
+	cases := []struct {
+		precision tdquery.Precision
+		converted interface{}
+		unit      int16
+		want      int64
+	}{
+		{tdquery.PrecisionMillisecond, int64(convertedTimestampMillis), unitMillis, 1700000000123},
+		{tdquery.PrecisionMicrosecond, int64(convertedTimestampMicros), unitMicros, 1700000000123456},
+		{tdquery.PrecisionNanosecond, nil, unitNanos, 1700000000123456512},
+	}
+	for _, c := range cases {
+		data := []map[string]interface{}{{"ts": float64(c.want)}}
+		// values of Rows are time.Time, values of QueryResult are numbers in the precision of the database
+		sources := []struct {
+			src  Source
+			opts []ParquetOption
+		}{
+			{&sliceSource{columns: columns, rows: [][]interface{}{{ts}}}, []ParquetOption{WithPrecision(c.precision)}},
+			{FromResult(&tdquery.QueryResult{Columns: columns, Data: data}), []ParquetOption{WithPrecision(c.precision)}},
+			// the precision of the source is the default
+			{FromResult(&tdquery.QueryResult{Columns: columns, Data: data, Precision: c.precision}), nil},
+		}
+		for _, s := range sources {
+			buf := &bytes.Buffer{}
+			if _, err := WriteParquet(buf, s.src, s.opts...); err != nil {
+				t.Fatal(err)
+			}
+			f, err := readParquet(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			schema := f.schema[0]
+			if schema[6] != c.converted {
+				t.Errorf("%s: converted type = %v, want %v", c.precision, schema[6], c.converted)
+			}
+			timestamp := schema[10].(map[int16]interface{})[logicalTimestamp].(map[int16]interface{})
+			if _, ok := timestamp[2].(map[int16]interface{})[c.unit]; !ok || timestamp[1] != true {
+				t.Errorf("%s: timestamp logical type = %v", c.precision, timestamp)
+			}
+			if got := f.columns[0][0]; got != c.want {
+				t.Errorf("%s: timestamp = %v, want %v", c.precision, got, c.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// compact protocol types, see https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+const (
+	thriftTrue   byte = 1
+	thriftFalse  byte = 2
+	thriftByte   byte = 3
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftWriter writes the subset of thrift compact protocol needed by parquet metadata
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) structBegin() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.buf.WriteByte(0)
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.structBegin()
+}
+
+func (t *thriftWriter) boolField(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) byteField(id int16, v int8) {
+	t.field(id, thriftByte)
+	t.buf.WriteByte(byte(v))
+}
+
+func (t *thriftWriter) i32Field(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64Field(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) stringField(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.str(v)
+}
+
+func (t *thriftWriter) str(v string) {
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) listField(id int16, elem byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.varint(uint64(size))
+	}
+}
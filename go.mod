@@ -4,8 +4,11 @@ go 1.17
 
 require (
 	github.com/go-resty/resty/v2 v2.7.0
+	github.com/golang/snappy v0.0.4
+	github.com/gorilla/websocket v1.5.0
 	github.com/json-iterator/go v1.1.12
 	github.com/mitchellh/mapstructure v1.5.0
+	google.golang.org/protobuf v1.28.1
 )
 
 require (
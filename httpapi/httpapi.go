@@ -0,0 +1,407 @@
+// Package httpapi serves queries of super tables as JSON documents, so clients query TDengine without writing SQL.
+// Documents are checked against an allow-list of super tables and columns, then built by tdquery.SelectQueryBuilder.
+//
+//	handler := httpapi.NewHandler(client, httpapi.WithTable(httpapi.Table{
+//		Database: "power",
+//		STable:   "meters",
+//		Columns:  []string{"current", "voltage", "location", "groupid"},
+//	}))
+//	http.Handle("/query", handler)
+//
+// A request body looks like:
+//
+//	{
+//		"stable": "meters",
+//		"selects": [{"column": "current", "function": "avg", "alias": "current"}],
+//		"conditions": [{"column": "location", "operator": "=", "value": "California.SanFrancisco"}],
+//		"interval": "1m",
+//		"fill": {"type": "value", "values": [0]},
+//		"groupBy": ["groupid"],
+//		"from": "2022-10-01T00:00:00Z",
+//		"to": "2022-10-02T00:00:00Z",
+//		"limit": 100
+//	}
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/snownd/tdquery"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+const (
+	defaultMaxLimit = 10000
+	maxRequestSize  = 1 << 20
+)
+
+var aliasRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ErrInvalidQuery is returned for malformed documents, it responds 400
+var ErrInvalidQuery = errors.New("httpapi: invalid query")
+
+// ErrNotAllowed is returned for super tables and columns out of the allow-list, it responds 403
+var ErrNotAllowed = errors.New("httpapi: not allowed")
+
+// functions allowed in selects
+var functions = map[string]struct{}{
+	"AVG":      {},
+	"COUNT":    {},
+	"SUM":      {},
+	"MIN":      {},
+	"MAX":      {},
+	"FIRST":    {},
+	"LAST":     {},
+	"LAST_ROW": {},
+	"SPREAD":   {},
+	"STDDEV":   {},
+	"TWA":      {},
+}
+
+// pseudo columns allowed in selects besides columns of the allow-list
+var pseudoColumns = map[string]struct{}{
+	tdquery.ColumnWStart:    {},
+	tdquery.ColumnWEnd:      {},
+	tdquery.ColumnWDuration: {},
+}
+
+// Query is the JSON document of a query
+type Query struct {
+	STable     string      `json:"stable"`
+	Selects    []Select    `json:"selects"`
+	Conditions []Condition `json:"conditions"`
+	// Interval is a period like `1m`, use function selects with it
+	Interval string   `json:"interval"`
+	Fill     *Fill    `json:"fill"`
+	GroupBy  []string `json:"groupBy"`
+	// From and To limit the time column when they are not zero
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+}
+
+// Select is a column, or a function of a column when Function is set, e.g. `avg`
+type Select struct {
+	Column   string `json:"column"`
+	Function string `json:"function"`
+	Alias    string `json:"alias"`
+}
+
+// Condition is a condition on a column, Value is an array of 2 values for BETWEEN, an array for IN and absent for IS NULL.
+type Condition struct {
+	Column   string      `json:"column"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// Fill is the fill of interval queries, Type is one of `value`, `prev`, `null`, `linear` and `next`.
+// Values are used by `value`, one for each select.
+type Fill struct {
+	Type   string    `json:"type"`
+	Values []float64 `json:"values"`
+}
+
+// Result is the JSON response of a query, values are the same as Client.Query
+type Result struct {
+	Columns []tdquery.ColumnMeta     `json:"columns"`
+	Data    []map[string]interface{} `json:"data"`
+	Rows    int                      `json:"rows"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Table is a super table in the allow-list
+type Table struct {
+	// Name is the name used by queries, it defaults to STable
+	Name string
+	// Database defaults to the database of client
+	Database string
+	STable   string
+	// Columns are columns and tags allowed in selects, conditions and group by.
+	// `_c0`, `tbname` and TimeColumn when it is set are always allowed.
+	Columns []string
+	// TimeColumn is the column limited by from and to, default is the first column
+	TimeColumn string
+}
+
+type Option func(h *Handler)
+
+func WithTable(t Table) Option {
+	return func(h *Handler) {
+		if t.Name == "" {
+			t.Name = t.STable
+		}
+		h.tables[t.Name] = t
+	}
+}
+
+// WithMaxLimit sets the max limit of a query, it is also the limit of queries without one. Default is 10000.
+func WithMaxLimit(n int) Option {
+	return func(h *Handler) {
+		h.maxLimit = n
+	}
+}
+
+// Handler is an http.Handler running Query documents posted as JSON
+type Handler struct {
+	client   *tdquery.Client
+	tables   map[string]Table
+	maxLimit int
+}
+
+func NewHandler(client *tdquery.Client, opts ...Option) *Handler {
+	h := &Handler{client: client, tables: make(map[string]Table), maxLimit: defaultMaxLimit}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+	q := &Query{}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(q); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("%v, %v", ErrInvalidQuery, err)})
+		return
+	}
+	b, err := h.Translate(q)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotAllowed) {
+			status = http.StatusForbidden
+		}
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+	res, err := b.GetRaw(r.Context())
+	if err == nil && res.Code != 0 {
+		err = &tdquery.TDEngineError{Code: res.Code, Message: res.Message}
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		var tdErr *tdquery.TDEngineError
+		if errors.As(err, &tdErr) && isQueryError(tdErr) {
+			status = http.StatusBadRequest
+		}
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, Result{Columns: res.Columns, Data: res.Data, Rows: len(res.Data)})
+}
+
+// isQueryError reports whether TDengine refuses the query itself, like fill without a function or MATCH on a number column.
+// They are errors of the SQL parser, 0x02xx of TDengine 2.x and 0x26xx of 3.x, except missing tables of the allow-list.
+func isQueryError(err *tdquery.TDEngineError) bool {
+	if tdquery.IsTableNotExist(err) {
+		return false
+	}
+	code := err.Code & 0xffff
+	return code>>8 == 0x02 || code>>8 == 0x26
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Translate checks q against the allow-list and builds it, errors wrap ErrInvalidQuery or ErrNotAllowed.
+func (h *Handler) Translate(q *Query) (*tdquery.SelectQueryBuilder, error) {
+	t, ok := h.tables[q.STable]
+	if !ok {
+		return nil, fmt.Errorf("%w, super table %q", ErrNotAllowed, q.STable)
+	}
+	b := h.client.NewSelectQueryBuilder().FromSTable(t.STable)
+	if t.Database != "" {
+		b.UseDatabase(t.Database)
+	}
+	if t.TimeColumn != "" {
+		b.TimeColumn(t.TimeColumn)
+	}
+	if len(q.Selects) == 0 {
+		return nil, fmt.Errorf("%w, no selects", ErrInvalidQuery)
+	}
+	for _, s := range q.Selects {
+		sel, err := t.selectOf(s)
+		if err != nil {
+			return nil, err
+		}
+		b.AddSelect(sel)
+	}
+	for _, c := range q.Conditions {
+		cond, err := t.conditionOf(c)
+		if err != nil {
+			return nil, err
+		}
+		b.Where(cond)
+	}
+	if !q.From.IsZero() || !q.To.IsZero() {
+		if q.From.IsZero() || q.To.IsZero() || q.To.Before(q.From) {
+			return nil, fmt.Errorf("%w, from and to should be given together and from should not be after to", ErrInvalidQuery)
+		}
+		b.WithTimeScope(q.From, q.To)
+	}
+	if q.Interval != "" {
+		if !tdquery.IsValidPeriod(strings.ToUpper(q.Interval)) {
+			return nil, fmt.Errorf("%w, interval %q", ErrInvalidQuery, q.Interval)
+		}
+		b.Interval(tdquery.NewInterval(q.Interval))
+	}
+	if q.Fill != nil {
+		if q.Interval == "" {
+			return nil, fmt.Errorf("%w, fill needs interval", ErrInvalidQuery)
+		}
+		fill, err := fillOf(q.Fill)
+		if err != nil {
+			return nil, err
+		}
+		if fill != nil {
+			b.Fill(fill)
+		}
+	}
+	for _, column := range q.GroupBy {
+		name, ok := t.column(column)
+		if !ok {
+			return nil, fmt.Errorf("%w, group by column %q", ErrNotAllowed, column)
+		}
+		b.GroupBy(name)
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return nil, fmt.Errorf("%w, negative limit or offset", ErrInvalidQuery)
+	}
+	if q.Limit > h.maxLimit {
+		return nil, fmt.Errorf("%w, limit exceeds %d", ErrInvalidQuery, h.maxLimit)
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = h.maxLimit
+	}
+	b.Limit(limit)
+	if q.Offset > 0 {
+		b.Offset(q.Offset)
+	}
+	return b, nil
+}
+
+// column returns the allowed name of column, names are case insensitive like TDengine
+func (t *Table) column(column string) (string, bool) {
+	if strings.EqualFold(column, tdquery.ColumnTbname) {
+		return tdquery.ColumnTbname, true
+	}
+	if column == tdquery.ColumnFirst {
+		return column, true
+	}
+	if t.TimeColumn != "" && strings.EqualFold(column, t.TimeColumn) {
+		return t.TimeColumn, true
+	}
+	for _, c := range t.Columns {
+		if strings.EqualFold(column, c) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+func (t *Table) selectOf(s Select) (tdquery.Select, error) {
+	if s.Alias != "" && !aliasRegexp.MatchString(s.Alias) {
+		return tdquery.Select{}, fmt.Errorf("%w, alias %q", ErrInvalidQuery, s.Alias)
+	}
+	column, ok := t.column(s.Column)
+	if !ok && s.Function == "" {
+		if _, ok = pseudoColumns[strings.ToLower(s.Column)]; ok {
+			column = strings.ToLower(s.Column)
+		}
+	}
+	if !ok && s.Column == "*" && strings.EqualFold(s.Function, "COUNT") {
+		column, ok = "*", true
+	}
+	if !ok {
+		return tdquery.Select{}, fmt.Errorf("%w, select column %q", ErrNotAllowed, s.Column)
+	}
+	if s.Function == "" {
+		return tdquery.Select{ColumnName: column, Alias: s.Alias}, nil
+	}
+	function := strings.ToUpper(s.Function)
+	if _, ok := functions[function]; !ok {
+		return tdquery.Select{}, fmt.Errorf("%w, function %q", ErrNotAllowed, s.Function)
+	}
+	return tdquery.Select{ColumnName: function + "(" + column + ")", Alias: s.Alias}, nil
+}
+
+func (t *Table) conditionOf(c Condition) (*tdquery.Condition, error) {
+	column, ok := t.column(c.Column)
+	if !ok {
+		return nil, fmt.Errorf("%w, condition column %q", ErrNotAllowed, c.Column)
+	}
+	cond := tdquery.NewCondition(column, strings.ToUpper(strings.TrimSpace(c.Operator)), c.Value)
+	if !cond.IsValid() {
+		return nil, fmt.Errorf("%w, operator %q", ErrInvalidQuery, c.Operator)
+	}
+	values, isArray := c.Value.([]interface{})
+	switch cond.Operator {
+	case "IS NULL", "IS NOT NULL":
+		if c.Value != nil {
+			return nil, fmt.Errorf("%w, %s has no value", ErrInvalidQuery, cond.Operator)
+		}
+		return cond, nil
+	case "BETWEEN":
+		if !isArray || len(values) != 2 {
+			return nil, fmt.Errorf("%w, BETWEEN needs 2 values", ErrInvalidQuery)
+		}
+	case "IN":
+		if !isArray || len(values) == 0 {
+			return nil, fmt.Errorf("%w, IN needs an array of values", ErrInvalidQuery)
+		}
+	default:
+		values = []interface{}{c.Value}
+	}
+	for _, v := range values {
+		switch v.(type) {
+		case string, float64, bool:
+		default:
+			return nil, fmt.Errorf("%w, value of %s %s should be a string, number or bool", ErrInvalidQuery, column, cond.Operator)
+		}
+	}
+	return cond, nil
+}
+
+func fillOf(f *Fill) (*tdquery.Fill, error) {
+	switch strings.ToLower(f.Type) {
+	case "", "none":
+		return nil, nil
+	case "value":
+		if len(f.Values) == 0 {
+			return nil, fmt.Errorf("%w, fill value needs values", ErrInvalidQuery)
+		}
+		values := make([]string, 0, len(f.Values))
+		for _, v := range f.Values {
+			values = append(values, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		return tdquery.FillValue(strings.Join(values, ", ")), nil
+	case "prev":
+		return tdquery.FillPrev(), nil
+	case "null":
+		return tdquery.FillNull(), nil
+	case "linear":
+		return tdquery.FillLinear(), nil
+	case "next":
+		return tdquery.FillNext(), nil
+	}
+	return nil, fmt.Errorf("%w, fill type %q", ErrInvalidQuery, f.Type)
+}
@@ -0,0 +1,147 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/standin"
+)
+
+func post(h http.Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+	return w
+}
+
+func newHandler(t *testing.T, handle func(sql string) *standin.Result) (*Handler, *standin.Server) {
+	s := standin.New(t, handle)
+	h := NewHandler(s.Client(t), WithMaxLimit(100),
+		WithTable(Table{Database: "power", STable: "meters", Columns: []string{"current", "location"}, TimeColumn: "ts"}),
+		WithTable(Table{Name: "m2", Database: "power", STable: "meters", Columns: []string{"current"}}))
+	return h, s
+}
+
+func TestHandler(t *testing.T) {
+	h, s := newHandler(t, func(sql string) *standin.Result {
+		return &standin.Result{
+			Columns: []tdquery.ColumnMeta{{Name: "c", Type: tdquery.ColumnTypeDouble, Length: 8}, {Name: "tbname", Type: tdquery.ColumnTypeBinary, Length: 192}},
+			Rows:    [][]interface{}{{1.5, "d1"}},
+		}
+	})
+	w := post(h, `{
+		"stable": "meters",
+		"selects": [{"column": "Current", "function": "avg", "alias": "c"}, {"column": "_wstart"}, {"column": "tbname"}],
+		"conditions": [{"column": "location", "operator": "in", "value": ["a", "b"]}, {"column": "TS", "operator": "is not null"}],
+		"interval": "1m",
+		"fill": {"type": "value", "values": [0.5]},
+		"groupBy": ["tbname"],
+		"from": "2022-10-01T00:00:00Z",
+		"to": "2022-10-02T00:00:00Z"
+	}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, %s", w.Code, w.Body)
+	}
+	res := &Result{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Rows != 1 || len(res.Columns) != 2 || !reflect.DeepEqual(res.Data, []map[string]interface{}{{"c": 1.5, "tbname": "d1"}}) {
+		t.Errorf("result %+v", res)
+	}
+	want := []string{
+		"SELECT AVG(current) AS \"c\", _wstart, TBNAME FROM power.meters WHERE location IN ('a','b') AND ts IS NOT NULL" +
+			" AND ts BETWEEN 1664582400000 AND 1664668800000 INTERVAL(1M) FILL(VALUE, 0.5) GROUP BY TBNAME LIMIT 100",
+	}
+	if got := s.SQLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sqls = %q\nwant %q", got, want)
+	}
+}
+
+func TestHandlerRefused(t *testing.T) {
+	h, s := newHandler(t, func(sql string) *standin.Result { return nil })
+	cases := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"unknown super table", `{"stable": "power.meters", "selects": [{"column": "current"}]}`, http.StatusForbidden},
+		{"column out of the allow-list", `{"stable": "meters", "selects": [{"column": "voltage"}]}`, http.StatusForbidden},
+		{"time column without TimeColumn", `{"stable": "m2", "selects": [{"column": "ts"}]}`, http.StatusForbidden},
+		{"condition column", `{"stable": "meters", "selects": [{"column": "_c0"}], "conditions": [{"column": "voltage", "operator": "=", "value": 1}]}`, http.StatusForbidden},
+		{"group by column", `{"stable": "meters", "selects": [{"column": "current"}], "groupBy": ["voltage"]}`, http.StatusForbidden},
+		{"function", `{"stable": "meters", "selects": [{"column": "current", "function": "sleep"}]}`, http.StatusForbidden},
+		{"pseudo column of function", `{"stable": "meters", "selects": [{"column": "_wstart", "function": "max"}]}`, http.StatusForbidden},
+		{"expression as column", `{"stable": "meters", "selects": [{"column": "current) FROM t; --"}]}`, http.StatusForbidden},
+		{"unknown field", `{"stable": "meters", "selects": [{"column": "current"}], "sql": "DROP DATABASE power"}`, http.StatusBadRequest},
+		{"no selects", `{"stable": "meters"}`, http.StatusBadRequest},
+		{"alias", `{"stable": "meters", "selects": [{"column": "current", "alias": "a\" FROM"}]}`, http.StatusBadRequest},
+		{"operator", `{"stable": "meters", "selects": [{"column": "current"}], "conditions": [{"column": "current", "operator": "= 1 OR", "value": 1}]}`, http.StatusBadRequest},
+		{"BETWEEN of 1 value", `{"stable": "meters", "selects": [{"column": "current"}], "conditions": [{"column": "current", "operator": "between", "value": [1]}]}`, http.StatusBadRequest},
+		{"IN of no values", `{"stable": "meters", "selects": [{"column": "current"}], "conditions": [{"column": "location", "operator": "in", "value": []}]}`, http.StatusBadRequest},
+		{"IN of a value", `{"stable": "meters", "selects": [{"column": "current"}], "conditions": [{"column": "location", "operator": "in", "value": "a"}]}`, http.StatusBadRequest},
+		{"IS NULL with value", `{"stable": "meters", "selects": [{"column": "current"}], "conditions": [{"column": "location", "operator": "is null", "value": "a"}]}`, http.StatusBadRequest},
+		{"object value", `{"stable": "meters", "selects": [{"column": "current"}], "conditions": [{"column": "location", "operator": "=", "value": {"a": 1}}]}`, http.StatusBadRequest},
+		{"array value", `{"stable": "meters", "selects": [{"column": "current"}], "conditions": [{"column": "location", "operator": "=", "value": ["a"]}]}`, http.StatusBadRequest},
+		{"value in array", `{"stable": "meters", "selects": [{"column": "current"}], "conditions": [{"column": "current", "operator": "between", "value": [1, null]}]}`, http.StatusBadRequest},
+		{"from without to", `{"stable": "meters", "selects": [{"column": "current"}], "from": "2022-10-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"interval", `{"stable": "meters", "selects": [{"column": "current"}], "interval": "1x"}`, http.StatusBadRequest},
+		{"fill without interval", `{"stable": "meters", "selects": [{"column": "current"}], "fill": {"type": "prev"}}`, http.StatusBadRequest},
+		{"limit over the max", `{"stable": "meters", "selects": [{"column": "current"}], "limit": 101}`, http.StatusBadRequest},
+		{"negative offset", `{"stable": "meters", "selects": [{"column": "current"}], "offset": -1}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if w := post(h, c.body); w.Code != c.status {
+			t.Errorf("%s: status %d, want %d, %s", c.name, w.Code, c.status, w.Body)
+		}
+	}
+	if sqls := s.SQLs(); len(sqls) > 0 {
+		t.Errorf("refused queries are sent: %q", sqls)
+	}
+}
+
+func TestHandlerLimit(t *testing.T) {
+	h, s := newHandler(t, func(sql string) *standin.Result { return nil })
+	for _, body := range []string{
+		`{"stable": "m2", "selects": [{"column": "current"}]}`,
+		`{"stable": "m2", "selects": [{"column": "current"}], "limit": 100, "offset": 5}`,
+		`{"stable": "m2", "selects": [{"column": "current"}], "limit": 10}`,
+	} {
+		if w := post(h, body); w.Code != http.StatusOK {
+			t.Errorf("%s: status %d, %s", body, w.Code, w.Body)
+		}
+	}
+	want := []string{
+		"SELECT current FROM power.meters LIMIT 100",
+		"SELECT current FROM power.meters LIMIT 100 OFFSET 5",
+		"SELECT current FROM power.meters LIMIT 10",
+	}
+	if got := s.SQLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sqls = %q\nwant %q", got, want)
+	}
+}
+
+func TestHandlerTDengineError(t *testing.T) {
+	code := 0
+	h, _ := newHandler(t, func(sql string) *standin.Result { return standin.Error(code, "error") })
+	cases := []struct {
+		code   int
+		status int
+	}{
+		// fill without a function of TDengine 3.x
+		{0x2652, http.StatusBadRequest},
+		// invalid operation of TDengine 2.x
+		{0x0200, http.StatusBadRequest},
+		{0x2603, http.StatusInternalServerError},
+		{0x000B, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		code = c.code
+		if w := post(h, `{"stable": "meters", "selects": [{"column": "current"}]}`); w.Code != c.status {
+			t.Errorf("code %#x: status %d, want %d, %s", c.code, w.Code, c.status, w.Body)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type insertTable struct {
+	name     string
+	sTable   string
+	tagNames []string
+	tags     []interface{}
+	columns  []string
+	rows     [][]interface{}
+}
+
+// InsertBuilder builds multi-table inserts:
+//
+//	INSERT INTO db.t1 USING db.s TAGS (?) (ts, value) VALUES (?, ?) (?, ?) db.t2 USING db.s TAGS (?) ...
+//
+// Into starts a new table, Using, Columns and Values apply to the last table.
+// time.Time values are written as numbers in the precision of the database.
+// TDengine limits the length of a sql, so very large inserts should be split into batches.
+type InsertBuilder struct {
+	c         *Client
+	database  string
+	precision Precision
+	tables    []*insertTable
+	err       error
+}
+
+func (c *Client) NewInsertBuilder() *InsertBuilder {
+	return &InsertBuilder{c: c, database: c.defaultDatabase()}
+}
+
+func (b *InsertBuilder) UseDatabase(db string) *InsertBuilder {
+	b.database = db
+	return b
+}
+
+// Precision sets the precision of the database, time.Time values are written as numbers in it.
+// Exec reads it from the database when it is not set, BuildWithParams uses millisecond.
+func (b *InsertBuilder) Precision(p Precision) *InsertBuilder {
+	b.precision = p
+	return b
+}
+
+func (b *InsertBuilder) current(method string) *insertTable {
+	if len(b.tables) == 0 {
+		if b.err == nil {
+			b.err = fmt.Errorf("%w, %s called before Into", ErrInvalidStatement, method)
+		}
+		return nil
+	}
+	return b.tables[len(b.tables)-1]
+}
+
+// Into starts inserting into table
+func (b *InsertBuilder) Into(table string) *InsertBuilder {
+	b.tables = append(b.tables, &insertTable{name: table})
+	return b
+}
+
+// Using creates the table from stable with tag values if it does not exist
+func (b *InsertBuilder) Using(stable string, tags ...interface{}) *InsertBuilder {
+	if t := b.current("Using"); t != nil {
+		t.sTable = stable
+		t.tags = tags
+	}
+	return b
+}
+
+// TagNames sets tag names for Using, it is needed when only some of the tags are given.
+func (b *InsertBuilder) TagNames(names ...string) *InsertBuilder {
+	if t := b.current("TagNames"); t != nil {
+		t.tagNames = names
+	}
+	return b
+}
+
+// Columns sets inserted columns, all columns are inserted in order when it is not set.
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	if t := b.current("Columns"); t != nil {
+		t.columns = columns
+	}
+	return b
+}
+
+// Values appends a row
+func (b *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
+	if t := b.current("Values"); t != nil {
+		t.rows = append(t.rows, values)
+	}
+	return b
+}
+
+// Rows returns number of rows of all tables
+func (b *InsertBuilder) Rows() int {
+	n := 0
+	for _, t := range b.tables {
+		n += len(t.rows)
+	}
+	return n
+}
+
+func writePlaceholders(b *strings.Builder, n int) {
+	b.WriteRune('(')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteRune('?')
+	}
+	b.WriteRune(')')
+}
+
+// BuildWithParams generates sql with `?` placeholders for tags and values
+func (b *InsertBuilder) BuildWithParams() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if b.Rows() == 0 {
+		return "", nil, ErrEmptyInsert
+	}
+	builder := &strings.Builder{}
+	params := make([]interface{}, 0)
+	unit := b.precision.Unit()
+	builder.WriteString("INSERT INTO")
+	for _, t := range b.tables {
+		if len(t.rows) == 0 {
+			continue
+		}
+		if t.name == "" {
+			return "", nil, fmt.Errorf("%w, empty table name", ErrInvalidStatement)
+		}
+		builder.WriteRune(' ')
+		builder.WriteString(qualifiedName(b.database, t.name))
+		if t.sTable != "" {
+			if len(t.tagNames) > 0 && len(t.tagNames) != len(t.tags) {
+				return "", nil, fmt.Errorf("%w, table %s has %d tag names but %d tag values", ErrInvalidStatement, t.name, len(t.tagNames), len(t.tags))
+			}
+			builder.WriteString(" USING ")
+			builder.WriteString(qualifiedName(b.database, t.sTable))
+			if len(t.tagNames) > 0 {
+				builder.WriteString(" (")
+				builder.WriteString(strings.Join(t.tagNames, ", "))
+				builder.WriteRune(')')
+			}
+			builder.WriteString(" TAGS ")
+			writePlaceholders(builder, len(t.tags))
+			params = appendTimestamps(params, t.tags, unit)
+		}
+		if len(t.columns) > 0 {
+			builder.WriteString(" (")
+			builder.WriteString(strings.Join(t.columns, ", "))
+			builder.WriteRune(')')
+		}
+		builder.WriteString(" VALUES")
+		for _, row := range t.rows {
+			if len(t.columns) > 0 && len(row) != len(t.columns) {
+				return "", nil, fmt.Errorf("%w, table %s has %d columns but a row has %d values", ErrInvalidStatement, t.name, len(t.columns), len(row))
+			}
+			builder.WriteRune(' ')
+			writePlaceholders(builder, len(row))
+			params = appendTimestamps(params, row, unit)
+		}
+	}
+	return builder.String(), params, nil
+}
+
+// appendTimestamps appends values to params, time.Time is converted to a number in unit
+func appendTimestamps(params []interface{}, values []interface{}, unit time.Duration) []interface{} {
+	for _, v := range values {
+		switch x := v.(type) {
+		case time.Time:
+			v = x.UnixNano() / int64(unit)
+		case *time.Time:
+			if x != nil {
+				v = x.UnixNano() / int64(unit)
+			}
+		}
+		params = append(params, v)
+	}
+	return params
+}
+
+// hasTime reports whether a tag or value is a time.Time
+func (b *InsertBuilder) hasTime() bool {
+	isTime := func(v interface{}) bool {
+		switch v.(type) {
+		case time.Time, *time.Time:
+			return true
+		}
+		return false
+	}
+	for _, t := range b.tables {
+		for _, v := range t.tags {
+			if isTime(v) {
+				return true
+			}
+		}
+		for _, row := range t.rows {
+			for _, v := range row {
+				if isTime(v) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (b *InsertBuilder) Build() (string, error) {
+	sql, _, err := b.BuildWithParams()
+	return sql, err
+}
+
+// Exec runs the insert, the precision of the database is read by Client.DatabasePrecision
+// when it is not set and time.Time values are inserted.
+func (b *InsertBuilder) Exec(ctx context.Context) error {
+	if b.precision == "" && b.err == nil && b.hasTime() {
+		db := b.database
+		if db == "" {
+			// the database in url
+			db = b.c.database
+		}
+		if db != "" {
+			p, err := b.c.DatabasePrecision(ctx, db)
+			if err != nil {
+				return err
+			}
+			b.precision = p
+		}
+	}
+	sql, params, err := b.BuildWithParams()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql, params...)
+}
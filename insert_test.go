@@ -0,0 +1,76 @@
+package tdquery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInsertBuilder(t *testing.T) {
+	c := NewClient(WithDatabase("power"))
+	ts := time.Unix(1700000000, 123456789)
+	cases := []struct {
+		name   string
+		b      *InsertBuilder
+		sql    string
+		params []interface{}
+	}{
+		{
+			name:   "table",
+			b:      c.NewInsertBuilder().Into("d0").Values(ts, 1.5).Values("NOW", nil),
+			sql:    "INSERT INTO power.d0 VALUES (?, ?) (?, ?)",
+			params: []interface{}{int64(1700000000123), 1.5, "NOW", nil},
+		},
+		{
+			name: "child tables",
+			b: c.NewInsertBuilder().UseDatabase("test").Into("d1").Using("meters", "sf", 1).Columns("ts", "current").Values(ts, 1.5).
+				Into("d2").Using("meters", "la").TagNames("location").Values(ts, 2.5).
+				Into("d3"),
+			sql:    "INSERT INTO test.d1 USING test.meters TAGS (?, ?) (ts, current) VALUES (?, ?) test.d2 USING test.meters (location) TAGS (?) VALUES (?, ?)",
+			params: []interface{}{"sf", 1, int64(1700000000123), 1.5, "la", int64(1700000000123), 2.5},
+		},
+		{
+			name:   "microsecond",
+			b:      c.NewInsertBuilder().Precision(PrecisionMicrosecond).Into("d0").Values(ts, &ts).Values(int64(1), (*time.Time)(nil)),
+			sql:    "INSERT INTO power.d0 VALUES (?, ?) (?, ?)",
+			params: []interface{}{int64(1700000000123456), int64(1700000000123456), int64(1), (*time.Time)(nil)},
+		},
+		{
+			name:   "nanosecond",
+			b:      c.NewInsertBuilder().Precision(PrecisionNanosecond).Into("d1").Using("meters", ts).Values(ts, 1),
+			sql:    "INSERT INTO power.d1 USING power.meters TAGS (?) VALUES (?, ?)",
+			params: []interface{}{ts.UnixNano(), ts.UnixNano(), 1},
+		},
+	}
+	for _, tc := range cases {
+		sql, params, err := tc.b.BuildWithParams()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+		if !reflect.DeepEqual(params, tc.params) {
+			t.Errorf("%s: params = %v, want %v", tc.name, params, tc.params)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		b    *InsertBuilder
+		err  error
+	}{
+		{"no values", c.NewInsertBuilder().Into("d0"), ErrEmptyInsert},
+		{"values before into", c.NewInsertBuilder().Values(1).Into("d0").Values(2), ErrInvalidStatement},
+		{"empty table name", c.NewInsertBuilder().Into("").Values(1), ErrInvalidStatement},
+		{"tag names mismatch", c.NewInsertBuilder().Into("d1").Using("meters", 1).TagNames("a", "b").Values(1), ErrInvalidStatement},
+		{"columns mismatch", c.NewInsertBuilder().Into("d0").Columns("ts", "v").Values(1), ErrInvalidStatement},
+	}
+	for _, tc := range invalid {
+		if _, _, err := tc.b.BuildWithParams(); !errors.Is(err, tc.err) {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+}
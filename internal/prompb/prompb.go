@@ -0,0 +1,280 @@
+// Package prompb encodes and decodes protobuf messages of Prometheus remote read and write.
+package prompb
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// matcher types of prompb.LabelMatcher
+const (
+	MatchEqual     = 0
+	MatchNotEqual  = 1
+	MatchRegexp    = 2
+	MatchNotRegexp = 3
+)
+
+var ErrMalformed = errors.New("prompb: malformed protobuf")
+
+type LabelMatcher struct {
+	Type  int
+	Name  string
+	Value string
+}
+
+// Query is prompb.Query, hints are ignored
+type Query struct {
+	Start    int64
+	End      int64
+	Matchers []LabelMatcher
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// eachField calls fn with number, type and value of every field of a message.
+// Values of varint and fixed fields are decoded, bytes are returned as they are.
+func eachField(b []byte, fn func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return ErrMalformed
+		}
+		b = b[n:]
+		var v uint64
+		var data []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, n = protowire.ConsumeFixed32(b)
+			v = uint64(v32)
+		case protowire.BytesType:
+			data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return ErrMalformed
+		}
+		b = b[n:]
+		if err := fn(num, typ, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseReadRequest decodes queries of prompb.ReadRequest
+func ParseReadRequest(b []byte) ([]Query, error) {
+	var queries []Query
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, _ uint64, data []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		q, err := parseQuery(data)
+		if err != nil {
+			return err
+		}
+		queries = append(queries, q)
+		return nil
+	})
+	return queries, err
+}
+
+func parseQuery(b []byte) (Query, error) {
+	q := Query{}
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			q.Start = int64(v)
+		case num == 2 && typ == protowire.VarintType:
+			q.End = int64(v)
+		case num == 3 && typ == protowire.BytesType:
+			m, err := parseMatcher(data)
+			if err != nil {
+				return err
+			}
+			q.Matchers = append(q.Matchers, m)
+		}
+		return nil
+	})
+	return q, err
+}
+
+func parseMatcher(b []byte) (LabelMatcher, error) {
+	m := LabelMatcher{}
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			m.Type = int(v)
+		case num == 2 && typ == protowire.BytesType:
+			m.Name = string(data)
+		case num == 3 && typ == protowire.BytesType:
+			m.Value = string(data)
+		}
+		return nil
+	})
+	return m, err
+}
+
+// ParseWriteRequest decodes series of prompb.WriteRequest, metadata, exemplars and histograms are ignored
+func ParseWriteRequest(b []byte) ([]TimeSeries, error) {
+	var series []TimeSeries
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, _ uint64, data []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		ts, err := parseTimeSeries(data)
+		if err != nil {
+			return err
+		}
+		series = append(series, ts)
+		return nil
+	})
+	return series, err
+}
+
+func parseTimeSeries(b []byte) (TimeSeries, error) {
+	ts := TimeSeries{}
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, _ uint64, data []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			l := Label{}
+			err := eachField(data, func(num protowire.Number, typ protowire.Type, _ uint64, data []byte) error {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					l.Name = string(data)
+				case num == 2 && typ == protowire.BytesType:
+					l.Value = string(data)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Labels = append(ts.Labels, l)
+		case num == 2 && typ == protowire.BytesType:
+			s := Sample{}
+			err := eachField(data, func(num protowire.Number, typ protowire.Type, v uint64, _ []byte) error {
+				switch {
+				case num == 1 && typ == protowire.Fixed64Type:
+					s.Value = math.Float64frombits(v)
+				case num == 2 && typ == protowire.VarintType:
+					s.Timestamp = int64(v)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, s)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+// AppendReadResponse encodes prompb.ReadResponse, there is a result for each query
+func AppendReadResponse(b []byte, results [][]TimeSeries) []byte {
+	for _, series := range results {
+		var result []byte
+		for _, ts := range series {
+			result = protowire.AppendTag(result, 1, protowire.BytesType)
+			result = protowire.AppendBytes(result, appendTimeSeries(nil, ts))
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, result)
+	}
+	return b
+}
+
+func appendTimeSeries(b []byte, ts TimeSeries) []byte {
+	for _, l := range ts.Labels {
+		var m []byte
+		m = protowire.AppendTag(m, 1, protowire.BytesType)
+		m = protowire.AppendString(m, l.Name)
+		m = protowire.AppendTag(m, 2, protowire.BytesType)
+		m = protowire.AppendString(m, l.Value)
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, m)
+	}
+	for _, s := range ts.Samples {
+		var m []byte
+		m = protowire.AppendTag(m, 1, protowire.Fixed64Type)
+		m = protowire.AppendFixed64(m, math.Float64bits(s.Value))
+		m = protowire.AppendTag(m, 2, protowire.VarintType)
+		m = protowire.AppendVarint(m, uint64(s.Timestamp))
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, m)
+	}
+	return b
+}
+
+// AppendWriteRequest encodes prompb.WriteRequest
+func AppendWriteRequest(b []byte, series []TimeSeries) []byte {
+	for _, ts := range series {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, appendTimeSeries(nil, ts))
+	}
+	return b
+}
+
+// AppendReadRequest encodes prompb.ReadRequest with the sampled response type
+func AppendReadRequest(b []byte, queries []Query) []byte {
+	for _, q := range queries {
+		var m []byte
+		m = protowire.AppendTag(m, 1, protowire.VarintType)
+		m = protowire.AppendVarint(m, uint64(q.Start))
+		m = protowire.AppendTag(m, 2, protowire.VarintType)
+		m = protowire.AppendVarint(m, uint64(q.End))
+		for _, matcher := range q.Matchers {
+			var mm []byte
+			mm = protowire.AppendTag(mm, 1, protowire.VarintType)
+			mm = protowire.AppendVarint(mm, uint64(matcher.Type))
+			mm = protowire.AppendTag(mm, 2, protowire.BytesType)
+			mm = protowire.AppendString(mm, matcher.Name)
+			mm = protowire.AppendTag(mm, 3, protowire.BytesType)
+			mm = protowire.AppendString(mm, matcher.Value)
+			m = protowire.AppendTag(m, 3, protowire.BytesType)
+			m = protowire.AppendBytes(m, mm)
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, m)
+	}
+	return b
+}
+
+// ParseReadResponse decodes series of each query result of prompb.ReadResponse
+func ParseReadResponse(b []byte) ([][]TimeSeries, error) {
+	var results [][]TimeSeries
+	err := eachField(b, func(num protowire.Number, typ protowire.Type, _ uint64, data []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		series, err := ParseWriteRequest(data)
+		if err != nil {
+			return err
+		}
+		results = append(results, series)
+		return nil
+	})
+	return results, err
+}
@@ -0,0 +1,71 @@
+package prompb
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestWriteRequest(t *testing.T) {
+	series := []TimeSeries{
+		{
+			Labels:  []Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "node"}},
+			Samples: []Sample{{Value: 1, Timestamp: 1700000000000}, {Value: -0.5, Timestamp: -1}},
+		},
+		{Labels: []Label{{Name: "__name__", Value: "电表"}}, Samples: []Sample{{Value: math.Inf(1), Timestamp: 0}}},
+	}
+	got, err := ParseWriteRequest(AppendWriteRequest(nil, series))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, series) {
+		t.Errorf("ParseWriteRequest() = %+v\nwant %+v", got, series)
+	}
+}
+
+func TestReadRequest(t *testing.T) {
+	queries := []Query{
+		{Start: 1700000000000, End: 1700003600000, Matchers: []LabelMatcher{
+			{Type: MatchEqual, Name: "__name__", Value: "up"},
+			{Type: MatchNotRegexp, Name: "job", Value: "n.*"},
+		}},
+		{Start: 0, End: 1, Matchers: []LabelMatcher{{Type: MatchNotEqual, Name: "instance", Value: ""}}},
+	}
+	got, err := ParseReadRequest(AppendReadRequest(nil, queries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, queries) {
+		t.Errorf("ParseReadRequest() = %+v\nwant %+v", got, queries)
+	}
+}
+
+func TestReadResponse(t *testing.T) {
+	results := [][]TimeSeries{
+		{
+			{Labels: []Label{{Name: "__name__", Value: "up"}}, Samples: []Sample{{Value: 1, Timestamp: 1}}},
+			{Labels: []Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "a"}}},
+		},
+		nil,
+	}
+	got, err := ParseReadResponse(AppendReadResponse(nil, results))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, results) {
+		t.Errorf("ParseReadResponse() = %+v\nwant %+v", got, results)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	b := AppendWriteRequest(nil, []TimeSeries{{Labels: []Label{{Name: "a", Value: "b"}}}})
+	for _, data := range [][]byte{b[:len(b)-1], {0xff}} {
+		if _, err := ParseWriteRequest(data); !errors.Is(err, ErrMalformed) {
+			t.Errorf("ParseWriteRequest(%x) = %v", data, err)
+		}
+		if _, err := ParseReadRequest(data); !errors.Is(err, ErrMalformed) {
+			t.Errorf("ParseReadRequest(%x) = %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package standin is a stand-in of the REST api of TDengine for tests of packages built on tdquery.
+// Statements are answered by a function, `show dnodes` is answered by the stand-in itself.
+package standin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/snownd/tdquery"
+)
+
+// TableNotExist is the code of `Table does not exist` of TDengine 2.x
+const TableNotExist = tdquery.QueryErrCodeTableNotExist
+
+// Result is the answer of a statement, Code is set for errors
+type Result struct {
+	Code    int
+	Message string
+	Columns []tdquery.ColumnMeta
+	// Rows are values in order of Columns, timestamps are unix milliseconds like /rest/sqlt
+	Rows [][]interface{}
+}
+
+// Affected is the result of inserts and DDL
+func Affected(n int) *Result {
+	return &Result{
+		Columns: []tdquery.ColumnMeta{{Name: "affected_rows", Type: tdquery.ColumnTypeInt, Length: 4}},
+		Rows:    [][]interface{}{{n}},
+	}
+}
+
+// Error is the result of a failed statement
+func Error(code int, message string) *Result {
+	return &Result{Code: code, Message: message}
+}
+
+// Server records statements and answers them by Handle
+type Server struct {
+	*httptest.Server
+	// Handle answers a statement, nil is an empty result
+	Handle func(sql string) *Result
+	lock   sync.Mutex
+	sqls   []string
+}
+
+// New starts a stand-in which is closed at the end of the test
+func New(t *testing.T, handle func(sql string) *Result) *Server {
+	s := &Server{Handle: handle}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/rest/sql") {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sql := string(body)
+	var res *Result
+	if sql == "show dnodes" {
+		res = &Result{
+			Columns: []tdquery.ColumnMeta{
+				{Name: "id", Type: tdquery.ColumnTypeSmallInt},
+				{Name: "end_point", Type: tdquery.ColumnTypeBinary},
+				{Name: "status", Type: tdquery.ColumnTypeBinary},
+				{Name: "role", Type: tdquery.ColumnTypeBinary},
+			},
+			Rows: [][]interface{}{{1, "127.0.0.1:6030", "ready", "any"}},
+		}
+	} else {
+		s.lock.Lock()
+		s.sqls = append(s.sqls, sql)
+		s.lock.Unlock()
+		res = s.Handle(sql)
+	}
+	if res == nil {
+		res = &Result{}
+	}
+	if res.Code != 0 {
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "error", "code": res.Code, "desc": res.Message})
+		return
+	}
+	meta := make([][]interface{}, 0, len(res.Columns))
+	head := make([]string, 0, len(res.Columns))
+	for _, c := range res.Columns {
+		meta = append(meta, []interface{}{c.Name, c.Type, c.Length})
+		head = append(head, c.Name)
+	}
+	rows := res.Rows
+	if rows == nil {
+		rows = make([][]interface{}, 0)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "succ", "head": head, "column_meta": meta, "data": rows, "rows": len(rows),
+	})
+}
+
+// Client returns a connected client of the stand-in
+func (s *Server) Client(t *testing.T, opts ...tdquery.Option) *tdquery.Client {
+	u, _ := url.Parse(s.URL)
+	port, _ := strconv.Atoi(u.Port())
+	opts = append([]tdquery.Option{tdquery.WithBrokers([]string{u.Hostname()}), tdquery.WithPort(port)}, opts...)
+	c := tdquery.NewClient(opts...)
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close(context.Background()) })
+	return c
+}
+
+// SQLs returns statements received except `show dnodes`
+func (s *Server) SQLs() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.sqls...)
+}
+
+// Reset forgets received statements
+func (s *Server) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sqls = nil
+}
@@ -1,55 +1,191 @@
 package tdquery
 
 import (
+	"database/sql/driver"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
-)
 
-const placeholder = "?"
+	"github.com/snownd/tdquery/sqlparse"
+)
 
 var typeTime = reflect.TypeOf(time.Time{})
 
-// interpolate make prepared statement to right sql "select * from table1 where id=?" value=[1] => "select * from table1 where id=1"
-func interpolate(query string, params []interface{}) (string, error) {
-	count := strings.Count(query, placeholder)
-	if count == 0 {
-		return query, nil
+var (
+	typeTDValuer     = reflect.TypeOf((*TDValuer)(nil)).Elem()
+	typeDriverValuer = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// sqlTemplate is a parsed sql with placeholders, placeholders in quoted literals and comments are ignored.
+// segments always has one more element than params: segments[0] params[0] segments[1] ... segments[n]
+type sqlTemplate struct {
+	sql      string
+	segments []string
+	// params holds names of named params, names are empty for positional params
+	params []string
+	named  bool
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNamePart(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// parseTemplate finds positional `?` placeholders, and named `:name`, `@name` placeholders when named is set,
+// skipping string literals, quoted identifiers and comments.
+func parseTemplate(sql string, named bool) (*sqlTemplate, error) {
+	t := &sqlTemplate{sql: sql}
+	start := 0
+	positional := false
+	for i := 0; i < len(sql); i++ {
+		end, err := sqlparse.Skip(sql, i)
+		if err != nil {
+			return nil, fmt.Errorf("%w, %v: %s", ErrInvalidStatement, err, sql)
+		}
+		if end > i {
+			i = end - 1
+			continue
+		}
+		c := sql[i]
+		switch {
+		case c == '?':
+			positional = true
+			t.segments = append(t.segments, sql[start:i])
+			t.params = append(t.params, "")
+			start = i + 1
+		case named && (c == ':' || c == '@') && i+1 < len(sql) && isNameStart(sql[i+1]):
+			end := i + 1
+			for end < len(sql) && isNamePart(sql[end]) {
+				end++
+			}
+			t.named = true
+			t.segments = append(t.segments, sql[start:i])
+			t.params = append(t.params, sql[i+1:end])
+			start = end
+			i = end - 1
+		}
+	}
+	if positional && t.named {
+		return nil, fmt.Errorf("%w, positional and named params can not be mixed: %s", ErrInvalidStatement, sql)
 	}
-	if count != len(params) {
-		return "", fmt.Errorf("%w with query: %s, params: %+v", ErrorInvalidQueryArgsNumber, query, params)
+	t.segments = append(t.segments, sql[start:])
+	return t, nil
+}
+
+func (t *sqlTemplate) render(encoders *encoderRegistry, lookup func(index int, name string) (interface{}, error)) (string, error) {
+	if len(t.params) == 0 {
+		return t.sql, nil
 	}
 	builder := &strings.Builder{}
-	argPos := 0
-	for {
-		index := strings.Index(query, placeholder)
-		if index == -1 {
-			break
-		}
-		if strings.HasPrefix(query[index:], placeholder) {
-			builder.WriteString(query[:index])
-			err := encodePlaceholder(params[argPos], builder)
-			if err != nil {
-				return "", err
-			}
-			argPos++
-			query = query[index+1:]
-			if argPos == count {
-				builder.WriteString(query)
-			}
+	builder.Grow(len(t.sql))
+	for i, name := range t.params {
+		builder.WriteString(t.segments[i])
+		value, err := lookup(i, name)
+		if err != nil {
+			return "", err
+		}
+		if err := encodePlaceholder(value, builder, encoders); err != nil {
+			return "", err
 		}
 	}
+	builder.WriteString(t.segments[len(t.segments)-1])
 	return builder.String(), nil
+}
+
+func (t *sqlTemplate) renderPositional(params []interface{}, encoders *encoderRegistry) (string, error) {
+	if len(t.params) != len(params) {
+		return "", fmt.Errorf("%w with query: %s, params: %+v", ErrorInvalidQueryArgsNumber, t.sql, params)
+	}
+	return t.render(encoders, func(index int, _ string) (interface{}, error) {
+		return params[index], nil
+	})
+}
 
+func (t *sqlTemplate) renderNamed(args map[string]interface{}, encoders *encoderRegistry) (string, error) {
+	if !t.named && len(t.params) > 0 {
+		return "", fmt.Errorf("%w, positional params need positional args: %s", ErrorInvalidQueryArgs, t.sql)
+	}
+	return t.render(encoders, func(_ int, name string) (interface{}, error) {
+		if v, ok := args[name]; ok {
+			return v, nil
+		}
+		for k, v := range args {
+			if strings.EqualFold(k, name) {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("%w, missing named param %s with query: %s", ErrorInvalidQueryArgsNumber, name, t.sql)
+	})
 }
 
-func encodePlaceholder(value interface{}, builder *strings.Builder) error {
+// interpolate make prepared statement to right sql "select * from table1 where id=?" value=[1] => "select * from table1 where id=1"
+// `:name` and `@name` are not placeholders of it.
+func interpolate(query string, params []interface{}, encoders *encoderRegistry) (string, error) {
+	t, err := parseTemplate(query, false)
+	if err != nil {
+		return "", err
+	}
+	return t.renderPositional(params, encoders)
+}
+
+func encodePlaceholder(value interface{}, builder *strings.Builder, encoders *encoderRegistry) error {
 	if value == nil {
 		builder.WriteString("NULL")
+		return nil
+	}
+	if encoder, ok := encoders.get(reflect.TypeOf(value)); ok {
+		s, err := encoder(value)
+		if err != nil {
+			return err
+		}
+		builder.WriteString(s)
+		return nil
+	}
+	switch x := value.(type) {
+	case TDValuer:
+		if isNilValuer(value, typeTDValuer) {
+			builder.WriteString("NULL")
+			return nil
+		}
+		s, err := x.TDValue()
+		if err != nil {
+			return err
+		}
+		builder.WriteString(s)
+		return nil
+	case driver.Valuer:
+		if isNilValuer(value, typeDriverValuer) {
+			builder.WriteString("NULL")
+			return nil
+		}
+		dv, err := x.Value()
+		if err != nil {
+			return err
+		}
+		if _, ok := dv.(driver.Valuer); ok {
+			return fmt.Errorf("%w with param: %+v, driver.Valuer returns driver.Valuer", ErrorInvalidQueryArgs, value)
+		}
+		return encodePlaceholder(dv, builder, encoders)
+	case time.Time:
+		// the precision of the database is unknown here, InsertBuilder converts time.Time to it before
+		builder.WriteString(strconv.FormatInt(x.UnixNano()/int64(time.Millisecond), 10))
+		return nil
+	case time.Duration:
+		builder.WriteString(encodeDuration(x))
+		return nil
 	}
 	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		// []byte and json.RawMessage are sent as string literals
+		builder.WriteString(encodeString(string(v.Bytes())))
+		return nil
+	}
 	switch v.Kind() {
 	case reflect.String:
 		builder.WriteString(encodeString(v.String()))
@@ -64,46 +200,69 @@ func encodePlaceholder(value interface{}, builder *strings.Builder) error {
 		builder.WriteString(strconv.FormatUint(v.Uint(), 10))
 		return nil
 	case reflect.Float32, reflect.Float64:
-		builder.WriteString(strconv.FormatFloat(v.Float(), 'f', -1, 64))
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%w with param: %v, NaN and Inf are not supported", ErrorInvalidQueryArgs, f)
+		}
+		bitSize := 64
+		if v.Kind() == reflect.Float32 {
+			bitSize = 32
+		}
+		builder.WriteString(strconv.FormatFloat(f, 'f', -1, bitSize))
 		return nil
-	case reflect.Struct:
-		if v.Type() == typeTime {
-			t := value.(time.Time)
-			builder.WriteString(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10))
+	case reflect.Ptr:
+		if v.IsNil() {
+			builder.WriteString("NULL")
 			return nil
 		}
-		return fmt.Errorf("%w with param: %+v", ErrorInvalidQueryArgs, v.Interface())
-	case reflect.Ptr:
-		return encodePlaceholder(v.Elem().Interface(), builder)
+		return encodePlaceholder(v.Elem().Interface(), builder, encoders)
 	case reflect.Slice, reflect.Array:
 		builder.WriteString("(")
-		l := v.Len()
-		if l > 0 {
-			var encoder func(e reflect.Value) string
-			f := v.Index(0)
-			switch f.Kind() {
-			case reflect.String:
-				encoder = func(e reflect.Value) string { return encodeString(e.String()) }
-			case reflect.Bool:
-				encoder = func(e reflect.Value) string { return encodeBool(e.Bool()) }
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				encoder = func(e reflect.Value) string { return strconv.FormatInt(e.Int(), 10) }
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				encoder = func(e reflect.Value) string { return strconv.FormatUint(e.Uint(), 10) }
-			default:
-				return fmt.Errorf("%w with slice/array param: %+v, unsupported kind", ErrorInvalidQueryArgs, v.Interface())
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				builder.WriteByte(',')
 			}
-			for i := 0; i < l; i++ {
-				if i > 0 {
-					builder.WriteByte(',')
-				}
-				builder.WriteString(encoder(v.Index(i)))
+			e := reflect.ValueOf(v.Index(i).Interface())
+			if k := e.Kind(); (k == reflect.Slice && e.Type().Elem().Kind() != reflect.Uint8) || k == reflect.Array {
+				return fmt.Errorf("%w with slice/array param: %+v, nested slice is not supported", ErrorInvalidQueryArgs, v.Interface())
+			}
+			if err := encodePlaceholder(v.Index(i).Interface(), builder, encoders); err != nil {
+				return err
 			}
 		}
 		builder.WriteString(")")
 		return nil
 	}
-	return ErrorInvalidQueryArgs
+	return fmt.Errorf("%w with param: %+v", ErrorInvalidQueryArgs, value)
+}
+
+// isNilValuer reports whether value is a nil pointer whose element type implements valuer,
+// calling the method would panic, so it is NULL like database/sql does.
+func isNilValuer(value interface{}, valuer reflect.Type) bool {
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Ptr && v.IsNil() && v.Type().Elem().Implements(valuer)
+}
+
+// encodeDuration encodes duration with the largest exact TDengine time unit, e.g. 90s => 90s, 1500ms => 1500a
+func encodeDuration(d time.Duration) string {
+	units := []struct {
+		d    time.Duration
+		unit string
+	}{
+		{7 * 24 * time.Hour, "w"},
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+		{time.Millisecond, "a"},
+		{time.Microsecond, "u"},
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return strconv.FormatInt(int64(d/u.d), 10) + u.unit
+		}
+	}
+	return strconv.FormatInt(int64(d), 10) + "b"
 }
 
 func encodeString(s string) string {
@@ -0,0 +1,195 @@
+package tdquery
+
+import (
+	"database/sql/driver"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type valueID int
+
+func (v valueID) Value() (driver.Value, error) {
+	return int64(v) * 10, nil
+}
+
+type pointerID int
+
+func (v *pointerID) Value() (driver.Value, error) {
+	if v == nil {
+		return "none", nil
+	}
+	return int64(*v), nil
+}
+
+type tdPoint struct{ X, Y int }
+
+func (p tdPoint) TDValue() (string, error) {
+	return "'" + strings.Repeat("*", p.X+p.Y) + "'", nil
+}
+
+// nestedValuer returns another driver.Valuer, which is not allowed
+type nestedValuer struct{}
+
+func (nestedValuer) Value() (driver.Value, error) {
+	return valueID(1), nil
+}
+
+// valuerOfSlice returns a value encoded as a list
+type valuerOfSlice struct{}
+
+func (valuerOfSlice) Value() (driver.Value, error) {
+	return []int64{1, 2}, nil
+}
+
+type failingValuer struct{}
+
+func (failingValuer) Value() (driver.Value, error) {
+	return nil, errors.New("failed")
+}
+
+type celsius float64
+
+func TestInterpolate(t *testing.T) {
+	ts := time.Unix(1700000000, 123000000)
+	var nilValue *valueID
+	var nilPointer *pointerID
+	var nilPoint *tdPoint
+	id := pointerID(7)
+	str := "x"
+	encoders := newEncoderRegistry()
+	encoders.set(reflect.TypeOf(celsius(0)), func(v interface{}) (string, error) {
+		return "'" + strconv.FormatFloat(float64(v.(celsius)), 'f', -1, 64) + "C'", nil
+	})
+	cases := []struct {
+		sql    string
+		params []interface{}
+		want   string
+	}{
+		{"SELECT * FROM t", nil, "SELECT * FROM t"},
+		{"SELECT * FROM t WHERE a = ? AND b = ?", []interface{}{1, "it's"}, "SELECT * FROM t WHERE a = 1 AND b = 'it''s'"},
+		{"SELECT * FROM t WHERE a = '?' AND b = ?", []interface{}{true}, "SELECT * FROM t WHERE a = '?' AND b = TRUE"},
+		{"SELECT * FROM t -- ?\nWHERE b = ? /* ? */", []interface{}{false}, "SELECT * FROM t -- ?\nWHERE b = FALSE /* ? */"},
+		{"SELECT ? FROM t", []interface{}{nil}, "SELECT NULL FROM t"},
+		{"SELECT ? FROM t", []interface{}{ts}, "SELECT 1700000000123 FROM t"},
+		{"SELECT ? FROM t", []interface{}{90 * time.Second}, "SELECT 90s FROM t"},
+		{"SELECT ? FROM t", []interface{}{1500 * time.Millisecond}, "SELECT 1500a FROM t"},
+		{"SELECT ? FROM t", []interface{}{float32(0.1)}, "SELECT 0.1 FROM t"},
+		{"SELECT ? FROM t", []interface{}{uint8(255)}, "SELECT 255 FROM t"},
+		{"SELECT ? FROM t", []interface{}{[]byte("raw")}, "SELECT 'raw' FROM t"},
+		{"SELECT ? FROM t", []interface{}{&str}, "SELECT 'x' FROM t"},
+		{"SELECT * FROM t WHERE a IN ?", []interface{}{[]int{1, 2, 3}}, "SELECT * FROM t WHERE a IN (1,2,3)"},
+		{"SELECT * FROM t WHERE a IN ?", []interface{}{[2]string{"a", "b"}}, "SELECT * FROM t WHERE a IN ('a','b')"},
+		{"SELECT * FROM t WHERE a IN ?", []interface{}{[]interface{}{1, "a", nil, ts}}, "SELECT * FROM t WHERE a IN (1,'a',NULL,1700000000123)"},
+		{"SELECT * FROM t WHERE a IN ?", []interface{}{[][]byte{[]byte("a")}}, "SELECT * FROM t WHERE a IN ('a')"},
+		{"SELECT * FROM t WHERE a IN ?", []interface{}{[]valueID{1, 2}}, "SELECT * FROM t WHERE a IN (10,20)"},
+		{"SELECT ? FROM t", []interface{}{valueID(2)}, "SELECT 20 FROM t"},
+		{"SELECT ? FROM t", []interface{}{&id}, "SELECT 7 FROM t"},
+		{"SELECT ? FROM t", []interface{}{valuerOfSlice{}}, "SELECT (1,2) FROM t"},
+		// a nil pointer with a value receiver method is NULL, a pointer receiver method is called
+		{"SELECT ? FROM t", []interface{}{nilValue}, "SELECT NULL FROM t"},
+		{"SELECT ? FROM t", []interface{}{nilPointer}, "SELECT 'none' FROM t"},
+		{"SELECT ? FROM t", []interface{}{nilPoint}, "SELECT NULL FROM t"},
+		{"SELECT ? FROM t", []interface{}{tdPoint{1, 2}}, "SELECT '***' FROM t"},
+		{"SELECT ? FROM t", []interface{}{celsius(21.5)}, "SELECT '21.5C' FROM t"},
+		{"SELECT * FROM t WHERE a IN ?", []interface{}{[]celsius{1, 2.5}}, "SELECT * FROM t WHERE a IN ('1C','2.5C')"},
+		// named placeholders are only parsed for named args
+		{"SELECT * FROM t WHERE a = :a AND b = @b", nil, "SELECT * FROM t WHERE a = :a AND b = @b"},
+		{"SELECT * FROM t WHERE a = :a AND b = ?", []interface{}{1}, "SELECT * FROM t WHERE a = :a AND b = 1"},
+	}
+	for _, c := range cases {
+		got, err := interpolate(c.sql, c.params, encoders)
+		if err != nil {
+			t.Errorf("interpolate(%q, %v): %v", c.sql, c.params, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("interpolate(%q, %v) = %q, want %q", c.sql, c.params, got, c.want)
+		}
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	cases := []struct {
+		sql    string
+		params []interface{}
+		err    error
+	}{
+		{"SELECT ? FROM t", []interface{}{math.NaN()}, ErrorInvalidQueryArgs},
+		{"SELECT ? FROM t", []interface{}{math.Inf(1)}, ErrorInvalidQueryArgs},
+		{"SELECT ? FROM t", []interface{}{float32(math.Inf(-1))}, ErrorInvalidQueryArgs},
+		{"SELECT * FROM t WHERE a IN ?", []interface{}{[]float64{1, math.NaN()}}, ErrorInvalidQueryArgs},
+		{"SELECT * FROM t WHERE a IN ?", []interface{}{[][]int{{1}}}, ErrorInvalidQueryArgs},
+		{"SELECT ? FROM t", []interface{}{nestedValuer{}}, ErrorInvalidQueryArgs},
+		{"SELECT ? FROM t", []interface{}{map[string]int{}}, ErrorInvalidQueryArgs},
+		{"SELECT ?, ? FROM t", []interface{}{1}, ErrorInvalidQueryArgsNumber},
+		{"SELECT 'abc FROM t", nil, ErrInvalidStatement},
+	}
+	for _, c := range cases {
+		_, err := interpolate(c.sql, c.params, nil)
+		if !errors.Is(err, c.err) {
+			t.Errorf("interpolate(%q, %v) error = %v, want %v", c.sql, c.params, err, c.err)
+		}
+	}
+	if _, err := interpolate("SELECT ? FROM t", []interface{}{failingValuer{}}, nil); err == nil || err.Error() != "failed" {
+		t.Errorf("error of driver.Valuer is not returned: %v", err)
+	}
+}
+
+func TestRegisteredEncoderPrecedence(t *testing.T) {
+	encoders := newEncoderRegistry()
+	encoders.set(reflect.TypeOf(valueID(0)), func(v interface{}) (string, error) {
+		return "'registered'", nil
+	})
+	got, err := interpolate("SELECT ? FROM t", []interface{}{valueID(1)}, encoders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "SELECT 'registered' FROM t" {
+		t.Errorf("registered encoder is not used: %s", got)
+	}
+	encoders.set(reflect.TypeOf(tdPoint{}), func(v interface{}) (string, error) {
+		return "", errors.New("encoder failed")
+	})
+	if _, err := interpolate("SELECT ? FROM t", []interface{}{tdPoint{}}, encoders); err == nil || err.Error() != "encoder failed" {
+		t.Errorf("error of encoder is not returned: %v", err)
+	}
+}
+
+func TestStatementNamedParams(t *testing.T) {
+	c := NewClient()
+	positional, err := c.Prepare("SELECT * FROM t WHERE a = ? AND b = :b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := positional.SQL([]interface{}{1}); err != nil || got != "SELECT * FROM t WHERE a = 1 AND b = :b" {
+		t.Errorf("SQL() = %q, %v", got, err)
+	}
+	if _, err := positional.SQL(map[string]interface{}{"b": 1}); !errors.Is(err, ErrInvalidStatement) {
+		t.Errorf("mixed params with named args: %v", err)
+	}
+	if params := positional.Params(); !reflect.DeepEqual(params, []string{""}) {
+		t.Errorf("Params() = %q", params)
+	}
+
+	named, err := c.Prepare("SELECT * FROM t WHERE a = :a AND b = @b AND c = ':c'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params := named.Params(); !reflect.DeepEqual(params, []string{"a", "b"}) {
+		t.Errorf("Params() = %q", params)
+	}
+	if got, err := named.SQL(struct{ A, B int }{1, 2}); err != nil || got != "SELECT * FROM t WHERE a = 1 AND b = 2 AND c = ':c'" {
+		t.Errorf("SQL() = %q, %v", got, err)
+	}
+	if got, err := named.SQL(nil); err != nil || got != "SELECT * FROM t WHERE a = :a AND b = @b AND c = ':c'" {
+		t.Errorf("SQL(nil) = %q, %v", got, err)
+	}
+	if _, err := named.SQL(map[string]interface{}{"a": 1}); !errors.Is(err, ErrorInvalidQueryArgsNumber) {
+		t.Errorf("missing named arg: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package tdquery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TableRef references a table or a super table with an alias, it is used by SelectQueryBuilder.Join.
+type TableRef struct {
+	Name   string
+	Alias  string
+	STable bool
+	// TimeColumn is the timestamp column of the table, it defaults to the time column of the query
+	TimeColumn string
+}
+
+// Table references a normal table or a child table.
+func Table(name string) TableRef {
+	return TableRef{Name: name}
+}
+
+// STable references a super table.
+func STable(name string) TableRef {
+	return TableRef{Name: name, STable: true}
+}
+
+func (t TableRef) As(alias string) TableRef {
+	t.Alias = alias
+	return t
+}
+
+// WithTimeColumn sets the timestamp column of the table
+func (t TableRef) WithTimeColumn(timeColumn string) TableRef {
+	t.TimeColumn = timeColumn
+	return t
+}
+
+type join struct {
+	table TableRef
+	on    Predicate
+}
+
+// Join joins another table on the timestamp column, e.g.
+//
+//	FromSTable("meters").As("a").TimeColumn("ts").
+//		Join(STable("meters").As("b"), And(EqualsColumn("a.ts", "b.ts"), EqualsColumn("a.location", "b.location")))
+//
+// TDengine only allows inner joins with these restrictions which are checked when building:
+//   - all tables must have aliases and must be all super tables or all normal tables
+//   - the on predicate can only combine conditions with AND
+//   - the on predicate must contain equality of the timestamp columns, set by TimeColumn and TableRef.WithTimeColumn
+//   - super tables must also be joined on equality of tags
+func (b *SelectQueryBuilder) Join(table TableRef, on Predicate) *SelectQueryBuilder {
+	b.joins = append(b.joins, join{table: table, on: on})
+	return b
+}
+
+func (b *SelectQueryBuilder) validateJoins() error {
+	if len(b.joins) == 0 {
+		return nil
+	}
+	if b.subQuery != nil || len(b.tables) > 1 {
+		return fmt.Errorf("%w, only one table or super table can be joined", ErrInvalidJoin)
+	}
+	if b.alias == "" {
+		return fmt.Errorf("%w, table in FROM clause must have an alias", ErrInvalidJoin)
+	}
+	isSTable := b.sTable != ""
+	// timestamp columns of aliases
+	timeColumns := map[string]string{b.alias: b.timeColumnName()}
+	for _, j := range b.joins {
+		if _, ok := timeColumns[j.table.Alias]; ok && j.table.Alias != "" {
+			return fmt.Errorf("%w, duplicate alias %s", ErrInvalidJoin, j.table.Alias)
+		}
+		timeColumns[j.table.Alias] = j.table.TimeColumn
+		if j.table.TimeColumn == "" {
+			timeColumns[j.table.Alias] = b.timeColumnName()
+		}
+	}
+	for _, j := range b.joins {
+		if j.table.Name == "" {
+			return fmt.Errorf("%w, empty table name", ErrInvalidJoin)
+		}
+		if j.table.Alias == "" {
+			return fmt.Errorf("%w, table %s must have an alias", ErrInvalidJoin, j.table.Name)
+		}
+		if j.table.STable != isSTable {
+			return fmt.Errorf("%w, can not join super table with normal table", ErrInvalidJoin)
+		}
+		if j.on == nil {
+			return fmt.Errorf("%w, table %s must have a join condition", ErrInvalidJoin, j.table.Name)
+		}
+		equalities, err := joinEqualities(j.on)
+		if err != nil {
+			return err
+		}
+		timeJoined, tagJoined := false, false
+		for _, e := range equalities {
+			leftAlias, leftColumn := splitColumn(e.left)
+			rightAlias, rightColumn := splitColumn(e.right)
+			leftTime, leftKnown := timeColumns[leftAlias]
+			rightTime, rightKnown := timeColumns[rightAlias]
+			if !leftKnown || !rightKnown || leftAlias == "" || rightAlias == "" || leftAlias == rightAlias {
+				continue
+			}
+			if j.table.Alias != leftAlias && j.table.Alias != rightAlias {
+				continue
+			}
+			if leftColumn == leftTime && rightColumn == rightTime {
+				timeJoined = true
+			} else {
+				tagJoined = true
+			}
+		}
+		if !timeJoined {
+			return fmt.Errorf("%w, table %s must be joined on timestamp column %s", ErrInvalidJoin, j.table.Name, timeColumns[j.table.Alias])
+		}
+		if isSTable && !tagJoined {
+			return fmt.Errorf("%w, super table %s must be joined on tags", ErrInvalidJoin, j.table.Name)
+		}
+	}
+	return nil
+}
+
+// joinEqualities flattens the AND predicates of a join condition and returns the column equalities.
+func joinEqualities(p Predicate) ([]*columnComparison, error) {
+	switch v := p.(type) {
+	case *columnComparison:
+		if v.operator == "=" {
+			return []*columnComparison{v}, nil
+		}
+		return nil, nil
+	case *Condition:
+		return nil, nil
+	case *logicalPredicate:
+		if v.operator != "AND" {
+			return nil, fmt.Errorf("%w, join condition can only be combined with AND", ErrInvalidJoin)
+		}
+		ret := make([]*columnComparison, 0, len(v.predicates))
+		for _, sub := range v.predicates {
+			equalities, err := joinEqualities(sub)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, equalities...)
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("%w, unsupported join condition %T", ErrInvalidJoin, p)
+	}
+}
+
+func splitColumn(column string) (alias string, name string) {
+	index := strings.Index(column, ".")
+	if index == -1 {
+		return "", column
+	}
+	return column[:index], column[index+1:]
+}
@@ -0,0 +1,72 @@
+package tdquery
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	c := NewClient()
+	cases := []struct {
+		name  string
+		build func() *SelectQueryBuilder
+		sql   string
+	}{
+		{
+			name: "super tables",
+			build: func() *SelectQueryBuilder {
+				return c.NewSelectQueryBuilder().SelectColumn("a.ts").SelectColumn("b.v").UseDatabase("power").
+					FromSTable("meters").As("a").TimeColumn("ts").
+					Join(STable("meters").As("b"), And(EqualsColumn("a.ts", "b.ts"), EqualsColumn("a.location", "b.location"))).
+					Where(NewCondition("a.v", ">", 1))
+			},
+			sql: "SELECT a.ts, b.v FROM power.meters a, power.meters b WHERE (a.ts = b.ts AND a.location = b.location) AND a.v > ?",
+		},
+		{
+			name: "own time columns",
+			build: func() *SelectQueryBuilder {
+				return c.NewSelectQueryBuilder().SelectAll().FromTables("d1").As("a").TimeColumn("ts").
+					Join(Table("w1").As("b").WithTimeColumn("time"), EqualsColumn("b.time", "a.ts"))
+			},
+			sql: "SELECT * FROM d1 a, w1 b WHERE b.time = a.ts",
+		},
+		{
+			name: "alias follows its table",
+			build: func() *SelectQueryBuilder {
+				return c.NewSelectQueryBuilder().SelectAll().FromTables("d1", "d2").As("a")
+			},
+			sql: "SELECT * FROM d1 a, d2",
+		},
+	}
+	for _, tc := range cases {
+		sql, err := tc.build().Build()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+	}
+
+	invalid := map[string]*SelectQueryBuilder{
+		"no alias": c.NewSelectQueryBuilder().SelectAll().FromTables("d1").
+			Join(Table("d2").As("b"), EqualsColumn("a._c0", "b._c0")),
+		"duplicate alias": c.NewSelectQueryBuilder().SelectAll().FromTables("d1").As("a").
+			Join(Table("d2").As("a"), EqualsColumn("a._c0", "a._c0")),
+		"super table with table": c.NewSelectQueryBuilder().SelectAll().FromSTable("meters").As("a").
+			Join(Table("d2").As("b"), EqualsColumn("a._c0", "b._c0")),
+		"OR": c.NewSelectQueryBuilder().SelectAll().FromTables("d1").As("a").
+			Join(Table("d2").As("b"), Or(EqualsColumn("a._c0", "b._c0"), EqualsColumn("a.v", "b.v"))),
+		// each side is compared with its own time column
+		"time column of the other side": c.NewSelectQueryBuilder().SelectAll().FromTables("d1").As("a").TimeColumn("ts").
+			Join(Table("w1").As("b").WithTimeColumn("time"), EqualsColumn("a.ts", "b.ts")),
+		"no tag equality": c.NewSelectQueryBuilder().SelectAll().FromSTable("meters").As("a").
+			Join(STable("meters").As("b"), EqualsColumn("a._c0", "b._c0")),
+	}
+	for name, b := range invalid {
+		if _, err := b.Build(); !errors.Is(err, ErrInvalidJoin) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const influxWriteURL = "/influxdb/v1/write"
+
+// Point is a point of InfluxDB line protocol, measurement becomes the super table and tags choose the child table.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	// Fields are typed by value: signed integers are written as `1i`, unsigned integers as `1u`,
+	// floats as `1.5`, bool as `true` and strings as `"text"`, strings must not contain newlines
+	Fields map[string]interface{}
+	// Time is the timestamp of point, server time is used when it is zero
+	Time time.Time
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	keyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// WriteLines writes points to db through schemaless InfluxDB line protocol of taosAdapter,
+// db defaults to the database of client and precision defaults to nanosecond.
+func (c *Client) WriteLines(ctx context.Context, db string, precision Precision, points ...Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+	if db == "" {
+		db = c.database
+	}
+	if db == "" {
+		return fmt.Errorf("%w: database is empty", ErrInvalidPoint)
+	}
+	body := &strings.Builder{}
+	for i := range points {
+		if err := points[i].appendLine(body, precision); err != nil {
+			return fmt.Errorf("point %d: %w", i, err)
+		}
+		body.WriteRune('\n')
+	}
+	query := map[string]string{"db": db}
+	switch precision {
+	case "":
+	case PrecisionMicrosecond:
+		// influxdb uses u for microsecond
+		query["precision"] = "u"
+	default:
+		query["precision"] = string(precision)
+	}
+	_, err := c.post(ctx, influxWriteURL, query, "text/plain", body.String())
+	return err
+}
+
+// Line encodes point in line protocol without trailing newline
+func (p Point) Line(precision Precision) (string, error) {
+	b := &strings.Builder{}
+	if err := p.appendLine(b, precision); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func checkLineName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: %s is empty", ErrInvalidPoint, kind)
+	}
+	if strings.ContainsAny(name, "\n\r") {
+		return fmt.Errorf("%w: %s %q contains newline", ErrInvalidPoint, kind, name)
+	}
+	return nil
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// appendLine writes `measurement,tag=v field=v timestamp`, tags and fields are sorted by key
+func (p *Point) appendLine(b *strings.Builder, precision Precision) error {
+	if err := checkLineName("measurement", p.Measurement); err != nil {
+		return err
+	}
+	if len(p.Fields) == 0 {
+		return fmt.Errorf("%w: point %s has no field", ErrInvalidPoint, p.Measurement)
+	}
+	b.WriteString(measurementEscaper.Replace(p.Measurement))
+	for _, k := range sortedKeys(p.Tags) {
+		v := p.Tags[k]
+		if err := checkLineName("tag key", k); err != nil {
+			return err
+		}
+		if err := checkLineName("tag value of "+k, v); err != nil {
+			return err
+		}
+		b.WriteRune(',')
+		b.WriteString(keyEscaper.Replace(k))
+		b.WriteRune('=')
+		b.WriteString(keyEscaper.Replace(v))
+	}
+	for i, k := range sortedKeys(p.Fields) {
+		if err := checkLineName("field key", k); err != nil {
+			return err
+		}
+		if i == 0 {
+			b.WriteRune(' ')
+		} else {
+			b.WriteRune(',')
+		}
+		b.WriteString(keyEscaper.Replace(k))
+		b.WriteRune('=')
+		if err := appendFieldValue(b, k, p.Fields[k]); err != nil {
+			return err
+		}
+	}
+	if !p.Time.IsZero() {
+		b.WriteRune(' ')
+		ts := p.Time.UnixNano()
+		switch precision {
+		case PrecisionMillisecond:
+			ts /= int64(time.Millisecond)
+		case PrecisionMicrosecond:
+			ts /= int64(time.Microsecond)
+		case "", PrecisionNanosecond:
+		default:
+			return fmt.Errorf("%w: unsupported precision %s", ErrInvalidPoint, precision)
+		}
+		b.WriteString(strconv.FormatInt(ts, 10))
+	}
+	return nil
+}
+
+func appendFieldValue(b *strings.Builder, key string, value interface{}) error {
+	if value == nil {
+		return fmt.Errorf("%w: field %s is nil", ErrInvalidPoint, key)
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return fmt.Errorf("%w: field %s is nil", ErrInvalidPoint, key)
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+		b.WriteRune('i')
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+		b.WriteRune('u')
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%w: field %s is %v", ErrInvalidPoint, key, f)
+		}
+		bitSize := 64
+		if v.Kind() == reflect.Float32 {
+			bitSize = 32
+		}
+		b.WriteString(strconv.FormatFloat(f, 'g', -1, bitSize))
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.String:
+		// line protocol can not escape newlines, a string with them would end the line
+		if strings.ContainsAny(v.String(), "\n\r") {
+			return fmt.Errorf("%w: field %s contains newline", ErrInvalidPoint, key)
+		}
+		b.WriteRune('"')
+		b.WriteString(stringEscaper.Replace(v.String()))
+		b.WriteRune('"')
+	default:
+		return fmt.Errorf("%w: field %s has unsupported type %T", ErrInvalidPoint, key, value)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package tdquery
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPointLine(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	var f float32 = 0.1
+	i := 7
+	cases := []struct {
+		name      string
+		point     Point
+		precision Precision
+		want      string
+		err       bool
+	}{
+		{
+			name:  "sorted tags and fields",
+			point: Point{Measurement: "meters", Tags: map[string]string{"location": "sf", "group": "2"}, Fields: map[string]interface{}{"voltage": 220, "current": 10.3}},
+			want:  "meters,group=2,location=sf current=10.3,voltage=220i",
+		},
+		{
+			name:  "escaped measurement",
+			point: Point{Measurement: "my meters,1=x", Fields: map[string]interface{}{"v": 1.0}},
+			want:  `my\ meters\,1=x v=1`,
+		},
+		{
+			name:  "escaped tags and field keys",
+			point: Point{Measurement: "m", Tags: map[string]string{"a b": "c,d=e"}, Fields: map[string]interface{}{"f=1 ,": true}},
+			want:  `m,a\ b=c\,d\=e f\=1\ \,=true`,
+		},
+		{
+			name:  "escaped string",
+			point: Point{Measurement: "m", Fields: map[string]interface{}{"s": `say "hi" \ bye`}},
+			want:  `m s="say \"hi\" \\ bye"`,
+		},
+		{
+			name: "integers",
+			point: Point{Measurement: "m", Fields: map[string]interface{}{
+				"a": int8(-1), "b": int64(math.MinInt64), "c": uint8(1), "d": uint64(math.MaxUint64), "e": &i,
+			}},
+			want: "m a=-1i,b=-9223372036854775808i,c=1u,d=18446744073709551615u,e=7i",
+		},
+		{
+			name:  "floats",
+			point: Point{Measurement: "m", Fields: map[string]interface{}{"a": f, "b": 1e21, "c": -0.5, "d": 3.0}},
+			want:  "m a=0.1,b=1e+21,c=-0.5,d=3",
+		},
+		{
+			name:  "nanosecond by default",
+			point: Point{Measurement: "m", Fields: map[string]interface{}{"v": false}, Time: ts},
+			want:  "m v=false 1700000000123456789",
+		},
+		{
+			name:      "nanosecond",
+			point:     Point{Measurement: "m", Fields: map[string]interface{}{"v": false}, Time: ts},
+			precision: PrecisionNanosecond,
+			want:      "m v=false 1700000000123456789",
+		},
+		{
+			name:      "microsecond",
+			point:     Point{Measurement: "m", Fields: map[string]interface{}{"v": false}, Time: ts},
+			precision: PrecisionMicrosecond,
+			want:      "m v=false 1700000000123456",
+		},
+		{
+			name:      "millisecond",
+			point:     Point{Measurement: "m", Fields: map[string]interface{}{"v": false}, Time: ts},
+			precision: PrecisionMillisecond,
+			want:      "m v=false 1700000000123",
+		},
+		{
+			name:      "unsupported precision",
+			point:     Point{Measurement: "m", Fields: map[string]interface{}{"v": false}, Time: ts},
+			precision: Precision("s"),
+			err:       true,
+		},
+		{name: "empty measurement", point: Point{Fields: map[string]interface{}{"v": 1}}, err: true},
+		{name: "no field", point: Point{Measurement: "m"}, err: true},
+		{name: "newline in tag", point: Point{Measurement: "m", Tags: map[string]string{"t": "a\nb"}, Fields: map[string]interface{}{"v": 1}}, err: true},
+		{name: "empty tag value", point: Point{Measurement: "m", Tags: map[string]string{"t": ""}, Fields: map[string]interface{}{"v": 1}}, err: true},
+		{name: "newline in string field", point: Point{Measurement: "m", Fields: map[string]interface{}{"s": "a\nb"}}, err: true},
+		{name: "carriage return in string field", point: Point{Measurement: "m", Fields: map[string]interface{}{"s": "a\rb"}}, err: true},
+		{name: "nil field", point: Point{Measurement: "m", Fields: map[string]interface{}{"v": nil}}, err: true},
+		{name: "nil pointer field", point: Point{Measurement: "m", Fields: map[string]interface{}{"v": (*int)(nil)}}, err: true},
+		{name: "NaN field", point: Point{Measurement: "m", Fields: map[string]interface{}{"v": math.NaN()}}, err: true},
+		{name: "unsupported field", point: Point{Measurement: "m", Fields: map[string]interface{}{"v": []int{1}}}, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.point.Line(c.precision)
+			if c.err {
+				if !errors.Is(err, ErrInvalidPoint) {
+					t.Errorf("Line() = %q, %v, want ErrInvalidPoint", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("Line() = %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWriteLines(t *testing.T) {
+	var lock sync.Mutex
+	var query, body string
+	s := newWSStandIn(t, map[string]*standInResult{"show dnodes": dnodes3x})
+	s.rest = func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		query = r.URL.Path + "?" + r.URL.RawQuery
+		body = string(b)
+	}
+	c := s.client(t, 1)
+	ctx := context.Background()
+	ts := time.Unix(1700000000, 123456789)
+	points := []Point{
+		{Measurement: "meters", Tags: map[string]string{"location": "sf"}, Fields: map[string]interface{}{"current": 10.3}, Time: ts},
+		{Measurement: "meters", Tags: map[string]string{"location": "la"}, Fields: map[string]interface{}{"current": 11.5}, Time: ts},
+	}
+	if err := c.WriteLines(ctx, "power", PrecisionMicrosecond, points...); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	if want := "/influxdb/v1/write?db=power&precision=u"; query != want {
+		t.Errorf("query = %s, want %s", query, want)
+	}
+	if want := "meters,location=sf current=10.3 1700000000123456\nmeters,location=la current=11.5 1700000000123456\n"; body != want {
+		t.Errorf("body = %q\nwant %q", body, want)
+	}
+	lock.Unlock()
+
+	// a broken point fails the whole write before sending
+	points[1].Fields["note"] = "a\nb"
+	if err := c.WriteLines(ctx, "power", PrecisionMillisecond, points...); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("WriteLines() = %v, want ErrInvalidPoint", err)
+	}
+	if err := c.WriteLines(ctx, "", PrecisionMillisecond, points[0]); !errors.Is(err, ErrInvalidPoint) {
+		t.Errorf("empty database: %v", err)
+	}
+}
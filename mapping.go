@@ -0,0 +1,373 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// struct tag used by struct mapping, e.g.
+//
+//	type Sensor struct {
+//		Table    string    `td:",tbname"`
+//		Ts       time.Time `td:"ts,timestamp"`
+//		Value    float64   `td:"value"`
+//		Name     string    `td:"name,nchar(32)"`
+//		CityCode int32     `td:"city_code,tag"`
+//	}
+//
+// Options:
+//   - timestamp: the primary timestamp column, default is the first time.Time field
+//   - tag: the field is a tag
+//   - tbname: the field is the child table name, it is not a column
+//   - nchar(n), binary(n), varchar(n), varbinary(n), geometry(n): type and length of string fields,
+//     strings have no default length
+//   - json: a JSON tag
+//
+// Fields without td tag are ignored.
+const structTag = "td"
+
+type structField struct {
+	column Column
+	index  []int
+}
+
+// StructSchema is the TDengine schema derived from a struct type
+type StructSchema struct {
+	Columns []Column
+	Tags    []Column
+	columns []structField
+	tags    []structField
+	tbname  []int
+}
+
+var structSchemas sync.Map
+
+// SchemaOf derives schema from a struct, a pointer to struct or a slice of them.
+func SchemaOf(v interface{}) (*StructSchema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w, %T is not a struct", ErrInvalidColumn, v)
+	}
+	if s, ok := structSchemas.Load(t); ok {
+		return s.(*StructSchema), nil
+	}
+	s, err := parseStructSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	structSchemas.Store(t, s)
+	return s, nil
+}
+
+func parseStructSchema(t reflect.Type) (*StructSchema, error) {
+	s := &StructSchema{}
+	primary := -1
+	explicitPrimary := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup(structTag)
+		if !ok || tag == "-" || f.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := strings.TrimSpace(parts[0])
+		isTag, isTimestamp, isTbname := false, false, false
+		column := Column{Name: name}
+		for _, option := range parts[1:] {
+			option = strings.ToLower(strings.TrimSpace(option))
+			switch {
+			case option == "tag":
+				isTag = true
+			case option == "timestamp":
+				isTimestamp = true
+			case option == "tbname":
+				isTbname = true
+			case option == "json":
+				column.Type = ColumnTypeJSON
+			case strings.HasPrefix(option, "nchar("), strings.HasPrefix(option, "binary("), strings.HasPrefix(option, "varchar("),
+				strings.HasPrefix(option, "varbinary("), strings.HasPrefix(option, "geometry("):
+				typeName := option[:strings.Index(option, "(")]
+				length, err := strconv.Atoi(strings.TrimSuffix(option[len(typeName)+1:], ")"))
+				if err != nil {
+					return nil, fmt.Errorf("%w, invalid length of field %s: %s", ErrInvalidColumn, f.Name, option)
+				}
+				column.Type, _ = ParseColumnType(typeName)
+				column.Length = length
+			default:
+				return nil, fmt.Errorf("%w, unknown option %s of field %s", ErrInvalidColumn, option, f.Name)
+			}
+		}
+		if isTbname {
+			if f.Type.Kind() != reflect.String {
+				return nil, fmt.Errorf("%w, tbname field %s must be string", ErrInvalidColumn, f.Name)
+			}
+			s.tbname = f.Index
+			continue
+		}
+		if column.Type == 0 {
+			t, err := columnTypeOf(f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%w, field %s", err, f.Name)
+			}
+			column.Type = t
+		}
+		if err := column.validate(); err != nil {
+			return nil, err
+		}
+		sf := structField{column: column, index: f.Index}
+		if isTag {
+			s.tags = append(s.tags, sf)
+			continue
+		}
+		if isTimestamp {
+			if column.Type != ColumnTypeTimestamp {
+				return nil, fmt.Errorf("%w, timestamp field %s must be time.Time", ErrInvalidColumn, f.Name)
+			}
+			if explicitPrimary {
+				return nil, fmt.Errorf("%w, more than one timestamp field", ErrInvalidColumn)
+			}
+			explicitPrimary = true
+			primary = len(s.columns)
+		} else if primary == -1 && column.Type == ColumnTypeTimestamp {
+			primary = len(s.columns)
+		}
+		s.columns = append(s.columns, sf)
+	}
+	if primary == -1 {
+		return nil, fmt.Errorf("%w, struct %s has no timestamp field", ErrInvalidColumn, t.Name())
+	}
+	// the primary timestamp must be the first column
+	if primary > 0 {
+		ts := s.columns[primary]
+		copy(s.columns[1:primary+1], s.columns[:primary])
+		s.columns[0] = ts
+	}
+	for _, f := range s.columns {
+		s.Columns = append(s.Columns, f.column)
+	}
+	for _, f := range s.tags {
+		s.Tags = append(s.Tags, f.column)
+	}
+	return s, nil
+}
+
+func columnTypeOf(t reflect.Type) (ColumnType, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == typeTime {
+		return ColumnTypeTimestamp, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return ColumnTypeBool, nil
+	case reflect.Int8:
+		return ColumnTypeTinyInt, nil
+	case reflect.Int16:
+		return ColumnTypeSmallInt, nil
+	case reflect.Int32:
+		return ColumnTypeInt, nil
+	case reflect.Int, reflect.Int64:
+		return ColumnTypeBigInt, nil
+	case reflect.Uint8:
+		return ColumnTypeUTinyInt, nil
+	case reflect.Uint16:
+		return ColumnTypeUSmallInt, nil
+	case reflect.Uint32:
+		return ColumnTypeUInt, nil
+	case reflect.Uint, reflect.Uint64:
+		return ColumnTypeUBigInt, nil
+	case reflect.Float32:
+		return ColumnTypeFloat, nil
+	case reflect.Float64:
+		return ColumnTypeDouble, nil
+	case reflect.String:
+		return 0, fmt.Errorf("%w, string needs nchar(n) or binary(n) option", ErrInvalidColumn)
+	}
+	return 0, fmt.Errorf("%w, unsupported type %s", ErrInvalidColumn, t)
+}
+
+// ColumnNames returns column names in insert order
+func (s *StructSchema) ColumnNames() []string {
+	names := make([]string, 0, len(s.Columns))
+	for _, c := range s.Columns {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// NewCreateSTableFromStruct builds `CREATE STABLE` from the td tags of v
+func (c *Client) NewCreateSTableFromStruct(name string, v interface{}) (*CreateSTableBuilder, error) {
+	s, err := SchemaOf(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewCreateSTable(name).Columns(s.Columns...).Tags(s.Tags...), nil
+}
+
+type DriftKind int
+
+const (
+	// DriftMissing means a column of the struct does not exist in TDengine
+	DriftMissing DriftKind = iota + 1
+	// DriftExtra means a column in TDengine is not in the struct
+	DriftExtra
+	// DriftType means the column type is different
+	DriftType
+	// DriftLength means the length of BINARY or NCHAR is different
+	DriftLength
+	// DriftTag means a column in struct is a tag in TDengine, or the reverse
+	DriftTag
+)
+
+func (k DriftKind) String() string {
+	switch k {
+	case DriftMissing:
+		return "missing"
+	case DriftExtra:
+		return "extra"
+	case DriftType:
+		return "type"
+	case DriftLength:
+		return "length"
+	case DriftTag:
+		return "tag"
+	default:
+		return "unknown"
+	}
+}
+
+// SchemaDrift is a difference between a struct and a super table
+type SchemaDrift struct {
+	Kind     DriftKind
+	Name     string
+	Expected ColumnInfo
+	Actual   ColumnInfo
+}
+
+func (d SchemaDrift) String() string {
+	switch d.Kind {
+	case DriftMissing:
+		return fmt.Sprintf("%s: missing %s", d.Name, d.Expected.Definition())
+	case DriftExtra:
+		return fmt.Sprintf("%s: extra %s", d.Name, d.Actual.Definition())
+	case DriftTag:
+		return fmt.Sprintf("%s: expected tag %t, actual tag %t", d.Name, d.Expected.Tag, d.Actual.Tag)
+	default:
+		return fmt.Sprintf("%s: expected %s, actual %s", d.Name, d.Expected.Definition(), d.Actual.Definition())
+	}
+}
+
+// DetectDrift compares td tags of v with `DESCRIBE` of stable, an empty result means no drift.
+func (c *Client) DetectDrift(ctx context.Context, db, stable string, v interface{}) ([]SchemaDrift, error) {
+	s, err := SchemaOf(v)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := c.Describe(ctx, db, stable)
+	if err != nil {
+		return nil, err
+	}
+	expected := make([]ColumnInfo, 0, len(s.Columns)+len(s.Tags))
+	for _, column := range s.Columns {
+		expected = append(expected, ColumnInfo{Column: column})
+	}
+	for _, tag := range s.Tags {
+		expected = append(expected, ColumnInfo{Column: tag, Tag: true, Note: "TAG"})
+	}
+	return diffColumns(expected, actual), nil
+}
+
+func diffColumns(expected, actual []ColumnInfo) []SchemaDrift {
+	ret := make([]SchemaDrift, 0)
+	actualByName := make(map[string]ColumnInfo, len(actual))
+	for _, a := range actual {
+		actualByName[strings.ToLower(a.Name)] = a
+	}
+	seen := make(map[string]struct{}, len(expected))
+	for _, e := range expected {
+		name := strings.ToLower(e.Name)
+		seen[name] = struct{}{}
+		a, ok := actualByName[name]
+		switch {
+		case !ok:
+			ret = append(ret, SchemaDrift{Kind: DriftMissing, Name: e.Name, Expected: e})
+		case e.Tag != a.Tag:
+			ret = append(ret, SchemaDrift{Kind: DriftTag, Name: e.Name, Expected: e, Actual: a})
+		case e.Type != a.Type:
+			ret = append(ret, SchemaDrift{Kind: DriftType, Name: e.Name, Expected: e, Actual: a})
+		case e.Type.HasLength() && e.Length != a.Length:
+			ret = append(ret, SchemaDrift{Kind: DriftLength, Name: e.Name, Expected: e, Actual: a})
+		}
+	}
+	for _, a := range actual {
+		if _, ok := seen[strings.ToLower(a.Name)]; !ok {
+			ret = append(ret, SchemaDrift{Kind: DriftExtra, Name: a.Name, Actual: a})
+		}
+	}
+	return ret
+}
+
+// Structs appends rows from a slice of structs with td tags, rows are grouped into child tables of stable
+// by the tbname field, and child tables are created with the tag fields if they do not exist.
+func (b *InsertBuilder) Structs(stable string, rows interface{}) *InsertBuilder {
+	if b.err != nil {
+		return b
+	}
+	s, err := SchemaOf(rows)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	if s.tbname == nil {
+		b.err = fmt.Errorf("%w, struct has no tbname field", ErrInvalidColumn)
+		return b
+	}
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		b.err = fmt.Errorf("%w, %T is not a slice", ErrInvalidStatement, rows)
+		return b
+	}
+	names := s.ColumnNames()
+	tagNames := make([]string, 0, len(s.Tags))
+	for _, tag := range s.Tags {
+		tagNames = append(tagNames, tag.Name)
+	}
+	tables := make(map[string]*insertTable)
+	for i := 0; i < v.Len(); i++ {
+		row := reflect.Indirect(v.Index(i))
+		if !row.IsValid() {
+			continue
+		}
+		name := row.FieldByIndex(s.tbname).String()
+		t, ok := tables[name]
+		if !ok {
+			tags := make([]interface{}, 0, len(s.tags))
+			for _, f := range s.tags {
+				tags = append(tags, row.FieldByIndex(f.index).Interface())
+			}
+			b.Into(name).Using(stable, tags...).TagNames(tagNames...).Columns(names...)
+			t = b.tables[len(b.tables)-1]
+			tables[name] = t
+		}
+		values := make([]interface{}, 0, len(s.columns))
+		for _, f := range s.columns {
+			values = append(values, row.FieldByIndex(f.index).Interface())
+		}
+		t.rows = append(t.rows, values)
+	}
+	return b
+}
+
+// InsertStructs inserts a slice of structs with td tags into child tables of stable, see InsertBuilder.Structs.
+// time.Time fields are written in the precision of the database, see InsertBuilder.Exec.
+func (c *Client) InsertStructs(ctx context.Context, stable string, rows interface{}) error {
+	return c.NewInsertBuilder().Structs(stable, rows).Exec(ctx)
+}
@@ -0,0 +1,200 @@
+package tdquery_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/standin"
+)
+
+type meter struct {
+	Table    string    `td:",tbname"`
+	Current  float32   `td:"current"`
+	Ts       time.Time `td:"ts,timestamp"`
+	Voltage  *int32    `td:"voltage"`
+	Note     string    `td:"note,nchar(16)"`
+	Location string    `td:"location,tag,binary(64)"`
+	GroupID  int32     `td:"group_id,tag"`
+	Ignored  string
+	private  int `td:"private"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	s, err := tdquery.SchemaOf([]*meter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantColumns := []tdquery.Column{
+		tdquery.NewColumn("ts", tdquery.ColumnTypeTimestamp),
+		tdquery.NewColumn("current", tdquery.ColumnTypeFloat),
+		tdquery.NewColumn("voltage", tdquery.ColumnTypeInt),
+		tdquery.NewSizedColumn("note", tdquery.ColumnTypeNchar, 16),
+	}
+	wantTags := []tdquery.Column{
+		tdquery.NewSizedColumn("location", tdquery.ColumnTypeBinary, 64),
+		tdquery.NewColumn("group_id", tdquery.ColumnTypeInt),
+	}
+	if !reflect.DeepEqual(s.Columns, wantColumns) || !reflect.DeepEqual(s.Tags, wantTags) {
+		t.Errorf("columns %+v, tags %+v", s.Columns, s.Tags)
+	}
+	if names := s.ColumnNames(); !reflect.DeepEqual(names, []string{"ts", "current", "voltage", "note"}) {
+		t.Errorf("column names %v", names)
+	}
+
+	invalid := map[string]interface{}{
+		"not a struct": []int{},
+		"string without length": struct {
+			Ts time.Time `td:"ts"`
+			S  string    `td:"s"`
+		}{},
+		"no timestamp": struct {
+			V int `td:"v"`
+		}{},
+		"two timestamps": struct {
+			A time.Time `td:"a,timestamp"`
+			B time.Time `td:"b,timestamp"`
+		}{},
+		"timestamp option on int": struct {
+			Ts int64 `td:"ts,timestamp"`
+		}{},
+		"unknown option": struct {
+			Ts time.Time `td:"ts,primary"`
+		}{},
+		"invalid length": struct {
+			Ts time.Time `td:"ts"`
+			S  string    `td:"s,binary(x)"`
+		}{},
+		"tbname not string": struct {
+			Ts    time.Time `td:"ts"`
+			Table int       `td:",tbname"`
+		}{},
+		"unsupported type": struct {
+			Ts time.Time   `td:"ts"`
+			M  map[int]int `td:"m"`
+		}{},
+	}
+	for name, v := range invalid {
+		if _, err := tdquery.SchemaOf(v); !errors.Is(err, tdquery.ErrInvalidColumn) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestNewCreateSTableFromStruct(t *testing.T) {
+	c := tdquery.NewClient(tdquery.WithDatabase("power"))
+	b, err := c.NewCreateSTableFromStruct("meters", meter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, err := b.IfNotExists().Build()
+	want := "CREATE STABLE IF NOT EXISTS power.meters (ts TIMESTAMP, current FLOAT, voltage INT, note NCHAR(16))" +
+		" TAGS (location BINARY(64), group_id INT)"
+	if err != nil || sql != want {
+		t.Errorf("sql = %s, %v\nwant %s", sql, err, want)
+	}
+}
+
+func TestDetectDrift(t *testing.T) {
+	describe := &standin.Result{
+		Columns: columns("field", "type", "length", "note"),
+		Rows: [][]interface{}{
+			{"ts", "TIMESTAMP", 8, ""},
+			{"current", "DOUBLE", 8, ""},
+			{"Note", "NCHAR", 8, ""},
+			{"phase", "FLOAT", 4, ""},
+			{"location", "BINARY", 64, "TAG"},
+			{"voltage", "INT", 4, "TAG"},
+		},
+	}
+	s := standin.New(t, func(sql string) *standin.Result {
+		if sql == "DESCRIBE power.meters" {
+			return describe
+		}
+		return standin.Error(standin.TableNotExist, "Table does not exist")
+	})
+	c := s.Client(t)
+	drifts, err := c.DetectDrift(context.Background(), "power", "meters", meter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(drifts))
+	for _, d := range drifts {
+		got = append(got, d.Kind.String()+" "+d.String())
+	}
+	want := []string{
+		"type current: expected current FLOAT, actual current DOUBLE",
+		"tag voltage: expected tag false, actual tag true",
+		"length note: expected note NCHAR(16), actual Note NCHAR(8)",
+		"missing group_id: missing group_id INT",
+		"extra phase: extra phase FLOAT",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("drifts = %q\nwant %q", got, want)
+	}
+
+	if _, err := c.DetectDrift(context.Background(), "power", "missing", meter{}); !tdquery.IsTableNotExist(err) {
+		t.Errorf("drift of missing table %v", err)
+	}
+}
+
+func TestInsertStructs(t *testing.T) {
+	s := standin.New(t, func(sql string) *standin.Result {
+		switch {
+		case sql == "SELECT SERVER_VERSION()":
+			return &standin.Result{Columns: columns("server_version()"), Rows: [][]interface{}{{"2.4.0.7"}}}
+		case sql == "SHOW DATABASES":
+			return &standin.Result{Columns: columns("name", "precision"), Rows: [][]interface{}{{"test", "ms"}, {"power", "us"}}}
+		case strings.HasPrefix(sql, "INSERT INTO "):
+			return standin.Affected(3)
+		}
+		return nil
+	})
+	c := s.Client(t, tdquery.WithDatabase("power"))
+	ts := time.Unix(1700000000, 123456000)
+	voltage := int32(220)
+	rows := []meter{
+		{Table: "d1", Ts: ts, Current: 1.5, Voltage: &voltage, Note: "it's", Location: "sf", GroupID: 1},
+		{Table: "d2", Ts: ts, Current: 2.5, Location: "la", GroupID: 2},
+		{Table: "d1", Ts: ts.Add(time.Microsecond), Current: 3.5, Location: "sf", GroupID: 1},
+	}
+	ctx := context.Background()
+	if err := c.InsertStructs(ctx, "meters", rows); err != nil {
+		t.Fatal(err)
+	}
+	// the precision is cached
+	if err := c.InsertStructs(ctx, "meters", rows[:1]); err != nil {
+		t.Fatal(err)
+	}
+	insert := "INSERT INTO power.d1 USING power.meters (location, group_id) TAGS ('sf', 1) (ts, current, voltage, note)" +
+		" VALUES (1700000000123456, 1.5, 220, 'it''s') (1700000000123457, 3.5, NULL, '')" +
+		" power.d2 USING power.meters (location, group_id) TAGS ('la', 2) (ts, current, voltage, note) VALUES (1700000000123456, 2.5, NULL, '')"
+	want := []string{
+		"SELECT SERVER_VERSION()",
+		"SHOW DATABASES",
+		insert,
+		"INSERT INTO power.d1 USING power.meters (location, group_id) TAGS ('sf', 1) (ts, current, voltage, note) VALUES (1700000000123456, 1.5, 220, 'it''s')",
+	}
+	if got := s.SQLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sqls = %q\nwant %q", got, want)
+	}
+
+	if err := c.NewInsertBuilder().UseDatabase("missing").Into("d1").Values(ts, 1).Exec(ctx); !errors.Is(err, tdquery.ErrUnknownDatabase) {
+		t.Errorf("insert into missing database %v", err)
+	}
+	invalid := map[string]interface{}{
+		"not a slice": meter{},
+		"no tbname": []struct {
+			Ts time.Time `td:"ts"`
+		}{},
+	}
+	for name, v := range invalid {
+		if err := c.InsertStructs(ctx, "meters", v); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+// Package migrate applies versioned schema migrations to TDengine through tdquery.Client.
+//
+// Applied versions are recorded in a history table of the target database,
+// and a lock table keeps two migrators from running at the same time.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/sqlparse"
+)
+
+const (
+	defaultTable   = "tdquery_migrations"
+	defaultLockTTL = 5 * time.Minute
+)
+
+var ErrLocked = errors.New("migrate: migrations are locked by another migrator")
+
+var ErrUnknownVersion = errors.New("migrate: unknown version")
+
+var ErrNoDownScript = errors.New("migrate: migration has no down script")
+
+// MigrationError reports the migration and statement which failed,
+// use errors.As to get *tdquery.TDEngineError for the TDengine error code.
+type MigrationError struct {
+	Version int64
+	Name    string
+	// Statement is the index of the failed statement in the script, it is -1 when the script can not be split or history is not recorded
+	Statement int
+	SQL       string
+	Err       error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migrate: version %d (%s) statement %d failed: %v, sql: %s", e.Version, e.Name, e.Statement, e.Err, e.SQL)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// Status is the state of a migration
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Option func(m *Migrator)
+
+// WithTable changes the history table, the lock table is named with a `_lock` suffix
+func WithTable(table string) Option {
+	return func(m *Migrator) {
+		m.table = table
+	}
+}
+
+// WithDryRun prints statements to w instead of executing them, no lock is taken.
+func WithDryRun(w io.Writer) Option {
+	return func(m *Migrator) {
+		m.dryRun = true
+		m.out = w
+	}
+}
+
+// WithLockTTL sets how long a lock is valid, so a crashed migrator does not block others forever.
+func WithLockTTL(ttl time.Duration) Option {
+	return func(m *Migrator) {
+		m.lockTTL = ttl
+	}
+}
+
+// WithOwner sets the lock owner, default is hostname and pid.
+func WithOwner(owner string) Option {
+	return func(m *Migrator) {
+		m.owner = owner
+	}
+}
+
+type Migrator struct {
+	c          *tdquery.Client
+	database   string
+	table      string
+	migrations []Migration
+	dryRun     bool
+	out        io.Writer
+	lockTTL    time.Duration
+	owner      string
+	lastTs     time.Time
+	// unit is the precision of the database, it is loaded before tables are read or written
+	unit time.Duration
+}
+
+func New(c *tdquery.Client, database string, migrations []Migration, opts ...Option) *Migrator {
+	hostname, _ := os.Hostname()
+	m := &Migrator{
+		c:          c,
+		database:   database,
+		table:      defaultTable,
+		migrations: append([]Migration(nil), migrations...),
+		out:        os.Stdout,
+		lockTTL:    defaultLockTTL,
+		owner:      hostname + ":" + strconv.Itoa(os.Getpid()),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	sort.Slice(m.migrations, func(i, j int) bool { return m.migrations[i].Version < m.migrations[j].Version })
+	return m
+}
+
+func (m *Migrator) historyTable() string {
+	return m.database + "." + m.table
+}
+
+func (m *Migrator) lockTable() string {
+	return m.database + "." + m.table + "_lock"
+}
+
+// loadPrecision reads the precision of the database, timestamps are written and read as numbers in it.
+func (m *Migrator) loadPrecision(ctx context.Context) error {
+	if m.unit != 0 {
+		return nil
+	}
+	databases, err := m.c.Databases(ctx)
+	if err != nil {
+		return err
+	}
+	for _, db := range databases {
+		if !strings.EqualFold(db.Name, m.database) {
+			continue
+		}
+		switch tdquery.Precision(db.Precision) {
+		case tdquery.PrecisionMicrosecond:
+			m.unit = time.Microsecond
+		case tdquery.PrecisionNanosecond:
+			m.unit = time.Nanosecond
+		default:
+			m.unit = time.Millisecond
+		}
+		return nil
+	}
+	return fmt.Errorf("migrate: database %s does not exist", m.database)
+}
+
+// nextTs returns increasing timestamps, because rows with the same timestamp overwrite each other.
+func (m *Migrator) nextTs() time.Time {
+	now := time.Now().Truncate(m.unit)
+	if !now.After(m.lastTs) {
+		now = m.lastTs.Add(m.unit)
+	}
+	m.lastTs = now
+	return now
+}
+
+// stamp is the value of t in the precision of the database
+func (m *Migrator) stamp(t time.Time) int64 {
+	return t.UnixNano() / int64(m.unit)
+}
+
+// timeOf reads a timestamp in the precision of the database
+func (m *Migrator) timeOf(v interface{}) time.Time {
+	return time.Unix(0, toInt64(v)*int64(m.unit))
+}
+
+func (m *Migrator) query(ctx context.Context, sql string, params ...interface{}) ([]map[string]interface{}, error) {
+	r, err := m.c.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	if r.Code != 0 {
+		return nil, &tdquery.TDEngineError{Code: r.Code, Message: r.Message}
+	}
+	return r.Data, nil
+}
+
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	err := m.c.NewCreateTable(m.table).
+		UseDatabase(m.database).
+		IfNotExists().
+		Columns(
+			tdquery.NewColumn("ts", tdquery.ColumnTypeTimestamp),
+			tdquery.NewColumn("version", tdquery.ColumnTypeBigInt),
+			tdquery.NewSizedColumn("name", tdquery.ColumnTypeNchar, 128),
+			tdquery.NewColumn("applied", tdquery.ColumnTypeBool),
+		).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	return m.c.NewCreateTable(m.table+"_lock").
+		UseDatabase(m.database).
+		IfNotExists().
+		Columns(
+			tdquery.NewColumn("ts", tdquery.ColumnTypeTimestamp),
+			tdquery.NewSizedColumn("owner", tdquery.ColumnTypeNchar, 128),
+			tdquery.NewColumn("expires", tdquery.ColumnTypeTimestamp),
+		).
+		Exec(ctx)
+}
+
+// applied returns applied versions with the time they were applied,
+// the latest record of a version decides whether it is applied or rolled back.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if err := m.loadPrecision(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := m.query(ctx, "SELECT ts, version, applied FROM "+m.historyTable())
+	if err != nil {
+		if tdquery.IsTableNotExist(err) {
+			return map[int64]time.Time{}, nil
+		}
+		return nil, err
+	}
+	type record struct {
+		ts      time.Time
+		applied bool
+	}
+	latest := make(map[int64]record)
+	for _, row := range rows {
+		ts := m.timeOf(row["ts"])
+		version := toInt64(row["version"])
+		if r, ok := latest[version]; ok && r.ts.After(ts) {
+			continue
+		}
+		applied, _ := row["applied"].(bool)
+		latest[version] = record{ts: ts, applied: applied}
+	}
+	ret := make(map[int64]time.Time)
+	for version, r := range latest {
+		if r.applied {
+			ret[version] = r.ts
+		}
+	}
+	return ret, nil
+}
+
+// Status returns all known migrations with their state
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		at, ok := applied[migration.Version]
+		ret = append(ret, Status{Migration: migration, Applied: ok, AppliedAt: at})
+	}
+	return ret, nil
+}
+
+// Up applies all pending migrations in version order
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.UpTo(ctx, -1)
+}
+
+// UpTo applies pending migrations with version less than or equal to version, -1 means all.
+func (m *Migrator) UpTo(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if version >= 0 && migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, migration, migration.Up, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the latest applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: version %d (%s)", ErrNoDownScript, migration.Version, migration.Name)
+			}
+			return m.run(ctx, migration, migration.Down, false)
+		}
+		return nil
+	})
+}
+
+// DownTo rolls back applied migrations with version greater than version
+func (m *Migrator) DownTo(ctx context.Context, version int64) error {
+	if version >= 0 && !m.known(version) {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version <= version {
+				break
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: version %d (%s)", ErrNoDownScript, migration.Version, migration.Name)
+			}
+			if err := m.run(ctx, migration, migration.Down, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Migrator) run(ctx context.Context, migration Migration, script string, up bool) error {
+	direction := "up"
+	if !up {
+		direction = "down"
+	}
+	if m.dryRun {
+		fmt.Fprintf(m.out, "-- %d_%s.%s.sql\n", migration.Version, migration.Name, direction)
+	}
+	statements, err := sqlparse.Split(script)
+	if err != nil {
+		return &MigrationError{Version: migration.Version, Name: migration.Name, Statement: -1, SQL: direction + " script", Err: err}
+	}
+	for i, statement := range statements {
+		if m.dryRun {
+			fmt.Fprintf(m.out, "%s;\n", statement)
+			continue
+		}
+		if err := m.c.Exec(ctx, statement); err != nil {
+			return &MigrationError{Version: migration.Version, Name: migration.Name, Statement: i, SQL: statement, Err: err}
+		}
+	}
+	if m.dryRun {
+		return nil
+	}
+	err = m.c.Exec(ctx, "INSERT INTO "+m.historyTable()+" VALUES (?, ?, ?, ?)", m.stamp(m.nextTs()), migration.Version, migration.Name, up)
+	if err != nil {
+		return &MigrationError{Version: migration.Version, Name: migration.Name, Statement: -1, SQL: "record history", Err: err}
+	}
+	return nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, f func() error) error {
+	if m.dryRun {
+		return f()
+	}
+	if err := m.loadPrecision(ctx); err != nil {
+		return err
+	}
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.unlock(context.Background())
+	return f()
+}
+
+// lock is best effort because TDengine has no transactions: a lock row is written first,
+// then the live lock with the earliest timestamp wins and the others back off.
+func (m *Migrator) lock(ctx context.Context) error {
+	now := m.nextTs()
+	err := m.c.Exec(ctx, "INSERT INTO "+m.lockTable()+" VALUES (?, ?, ?)", m.stamp(now), m.owner, m.stamp(now.Add(m.lockTTL)))
+	if err != nil {
+		return err
+	}
+	rows, err := m.query(ctx, "SELECT ts, owner, expires FROM "+m.lockTable()+" WHERE ts > ?", m.stamp(now.Add(-m.lockTTL)))
+	if err != nil {
+		return err
+	}
+	type lockRow struct {
+		ts      time.Time
+		expires time.Time
+	}
+	latest := make(map[string]lockRow)
+	for _, row := range rows {
+		owner, _ := row["owner"].(string)
+		r := lockRow{ts: m.timeOf(row["ts"]), expires: m.timeOf(row["expires"])}
+		if l, ok := latest[owner]; ok && l.ts.After(r.ts) {
+			continue
+		}
+		latest[owner] = r
+	}
+	winner := ""
+	var winnerTs time.Time
+	for owner, r := range latest {
+		if !r.expires.After(now) {
+			continue
+		}
+		if winner == "" || r.ts.Before(winnerTs) || (r.ts.Equal(winnerTs) && owner < winner) {
+			winner = owner
+			winnerTs = r.ts
+		}
+	}
+	if winner != m.owner {
+		m.unlock(ctx)
+		return fmt.Errorf("%w: %s", ErrLocked, winner)
+	}
+	return nil
+}
+
+// unlock writes a lock row which has already expired
+func (m *Migrator) unlock(ctx context.Context) {
+	now := m.nextTs()
+	_ = m.c.Exec(ctx, "INSERT INTO "+m.lockTable()+" VALUES (?, ?, ?)", m.stamp(now), m.owner, m.stamp(now))
+}
+
+func toInt64(v interface{}) int64 {
+	switch x := v.(type) {
+	case float64:
+		return int64(x)
+	case int64:
+		return x
+	case string:
+		i, _ := strconv.ParseInt(x, 10, 64)
+		return i
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/standin"
+)
+
+var (
+	insertPattern = regexp.MustCompile(`^INSERT INTO power\.(\w+) VALUES \((\d+), (.*)\)$`)
+	sincePattern  = regexp.MustCompile(`WHERE ts > (\d+)$`)
+)
+
+// database is a stand-in of a database of microsecond precision, rows of the history and lock tables are kept
+type database struct {
+	lock     sync.Mutex
+	tables   map[string][][]interface{}
+	executed []string
+}
+
+func (d *database) handle(sql string) *standin.Result {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	text := func(names ...string) []tdquery.ColumnMeta {
+		columns := make([]tdquery.ColumnMeta, 0, len(names))
+		for _, name := range names {
+			columns = append(columns, tdquery.ColumnMeta{Name: name, Type: tdquery.ColumnTypeBinary, Length: 64})
+		}
+		return columns
+	}
+	switch {
+	case sql == "SELECT SERVER_VERSION()":
+		return &standin.Result{Columns: text("server_version()"), Rows: [][]interface{}{{"2.6.0.0"}}}
+	case sql == "SHOW DATABASES":
+		return &standin.Result{Columns: text("name", "precision"), Rows: [][]interface{}{{"log", "ms"}, {"power", "us"}}}
+	case strings.HasPrefix(sql, "CREATE TABLE IF NOT EXISTS power.tdquery_migrations"):
+		name := strings.Fields(sql)[5][len("power."):]
+		if d.tables[name] == nil {
+			d.tables[name] = make([][]interface{}, 0)
+		}
+	case strings.HasPrefix(sql, "INSERT INTO "):
+		m := insertPattern.FindStringSubmatch(sql)
+		ts, _ := strconv.ParseInt(m[2], 10, 64)
+		row := []interface{}{ts}
+		for _, v := range strings.Split(m[3], ", ") {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				row = append(row, n)
+			} else {
+				row = append(row, strings.EqualFold(v, "true"))
+			}
+		}
+		d.tables[m[1]] = append(d.tables[m[1]], row)
+		return standin.Affected(1)
+	case strings.HasPrefix(sql, "SELECT ts, owner, expires FROM power.tdquery_migrations_lock"):
+		since, _ := strconv.ParseInt(sincePattern.FindStringSubmatch(sql)[1], 10, 64)
+		res := &standin.Result{Columns: []tdquery.ColumnMeta{
+			{Name: "ts", Type: tdquery.ColumnTypeTimestamp, Length: 8},
+			{Name: "owner", Type: tdquery.ColumnTypeNchar, Length: 128},
+			{Name: "expires", Type: tdquery.ColumnTypeTimestamp, Length: 8},
+		}}
+		for _, row := range d.tables["tdquery_migrations_lock"] {
+			if row[0].(int64) > since {
+				res.Rows = append(res.Rows, []interface{}{row[0], "m1", row[2]})
+			}
+		}
+		return res
+	case sql == "SELECT ts, version, applied FROM power.tdquery_migrations":
+		rows, ok := d.tables["tdquery_migrations"]
+		if !ok {
+			return standin.Error(standin.TableNotExist, "Table does not exist")
+		}
+		res := &standin.Result{Columns: []tdquery.ColumnMeta{
+			{Name: "ts", Type: tdquery.ColumnTypeTimestamp, Length: 8},
+			{Name: "version", Type: tdquery.ColumnTypeBigInt, Length: 8},
+			{Name: "applied", Type: tdquery.ColumnTypeBool, Length: 1},
+		}}
+		for _, row := range rows {
+			// /rest/sqlt returns bools as numbers
+			applied := 0
+			if row[3] == true {
+				applied = 1
+			}
+			res.Rows = append(res.Rows, []interface{}{row[0], row[1], applied})
+		}
+		return res
+	default:
+		d.executed = append(d.executed, sql)
+	}
+	return nil
+}
+
+func TestMigratorPrecision(t *testing.T) {
+	d := &database{tables: make(map[string][][]interface{})}
+	s := standin.New(t, d.handle)
+	m := New(s.Client(t), "power", []Migration{
+		{Version: 1, Name: "create meters", Up: "CREATE STABLE power.meters (ts TIMESTAMP, v DOUBLE) TAGS (id INT)"},
+	}, WithOwner("m1"))
+	start := time.Now()
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.executed) != 1 || !strings.HasPrefix(d.executed[0], "CREATE STABLE power.meters") {
+		t.Errorf("executed %q", d.executed)
+	}
+	for table, rows := range d.tables {
+		for _, row := range rows {
+			if us := row[0].(int64); us < start.UnixNano()/int64(time.Microsecond) || us > time.Now().UnixNano()/int64(time.Microsecond) {
+				t.Errorf("%s: timestamp %d is not in microseconds", table, us)
+			}
+		}
+	}
+	status, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 1 || !status[0].Applied || status[0].AppliedAt.Before(start.Truncate(time.Microsecond)) || status[0].AppliedAt.After(time.Now()) {
+		t.Errorf("status %+v", status)
+	}
+}
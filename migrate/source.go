@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var fileRegexp = regexp.MustCompile(`^([0-9]+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change, Down is optional.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// LoadFS loads migrations from dir of fsys, which works with embed.FS.
+// Files are named like `0001_create_sensors.up.sql` and `0001_create_sensors.down.sql`,
+// other files are ignored.
+func LoadFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		matches := fileRegexp.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: invalid version of %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		} else if m.Name != matches[2] {
+			return nil, fmt.Errorf("migrate: version %d has different names %s and %s", version, m.Name, matches[2])
+		}
+		if matches[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	ret := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d (%s) has no up script", m.Version, m.Name)
+		}
+		ret = append(ret, *m)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Version < ret[j].Version })
+	return ret, nil
+}
+
+// LoadDir loads migrations from a directory, see LoadFS
+func LoadDir(dir string) ([]Migration, error) {
+	return LoadFS(os.DirFS(dir), ".")
+}
@@ -0,0 +1,257 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openTSDBJSONURL   = "/opentsdb/v1/put/json/"
+	openTSDBTelnetURL = "/opentsdb/v1/put/telnet/"
+)
+
+// OpenTSDBPoint is a data point of OpenTSDB, metric becomes the super table and tags choose the child table.
+type OpenTSDBPoint struct {
+	Metric string
+	// Time is written in milliseconds, server time is used when it is zero
+	Time time.Time
+	// Value is typed by Go type: int8 to int64, uint8 to uint64, float32 and float64 keep their width,
+	// int and uint are written as 64 bits, bool and string are also accepted
+	Value interface{}
+	Tags  map[string]string
+}
+
+// PointError is a point rejected before sending, Index is its index in the batch
+type PointError struct {
+	Index int
+	Err   error
+}
+
+func (e PointError) Error() string {
+	return fmt.Sprintf("point %d: %v", e.Index, e.Err)
+}
+
+func (e PointError) Unwrap() error {
+	return e.Err
+}
+
+type openTSDBValue struct {
+	Value interface{} `json:"value"`
+	Type  string      `json:"type"`
+}
+
+type openTSDBJSONPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     interface{}       `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// WriteOpenTSDBJSON writes points through the OpenTSDB JSON endpoint of taosAdapter, db defaults to the database of client.
+// Invalid points are skipped and returned as rejected, the rest are written in one request,
+// so a nil error means they are written even if some points are rejected.
+func (c *Client) WriteOpenTSDBJSON(ctx context.Context, db string, points ...OpenTSDBPoint) (rejected []PointError, err error) {
+	if db == "" {
+		db = c.database
+	}
+	if db == "" {
+		return nil, fmt.Errorf("%w: database is empty", ErrInvalidPoint)
+	}
+	valid := make([]openTSDBJSONPoint, 0, len(points))
+	for i := range points {
+		p, err := points[i].jsonPoint()
+		if err != nil {
+			rejected = append(rejected, PointError{Index: i, Err: err})
+			continue
+		}
+		valid = append(valid, p)
+	}
+	if len(valid) > 0 {
+		body, err := json.MarshalToString(valid)
+		if err != nil {
+			return rejected, err
+		}
+		if _, err := c.post(ctx, openTSDBJSONURL+db, nil, "application/json", body); err != nil {
+			return rejected, err
+		}
+	}
+	return rejected, nil
+}
+
+// WriteOpenTSDBTelnet writes points through the OpenTSDB telnet endpoint of taosAdapter, db defaults to the database of client.
+// Invalid points are skipped and returned as rejected like WriteOpenTSDBJSON.
+func (c *Client) WriteOpenTSDBTelnet(ctx context.Context, db string, points ...OpenTSDBPoint) (rejected []PointError, err error) {
+	if db == "" {
+		db = c.database
+	}
+	if db == "" {
+		return nil, fmt.Errorf("%w: database is empty", ErrInvalidPoint)
+	}
+	body := &strings.Builder{}
+	for i := range points {
+		line, err := points[i].TelnetLine()
+		if err != nil {
+			rejected = append(rejected, PointError{Index: i, Err: err})
+			continue
+		}
+		body.WriteString(line)
+		body.WriteRune('\n')
+	}
+	if body.Len() > 0 {
+		if _, err := c.post(ctx, openTSDBTelnetURL+db, nil, "text/plain", body.String()); err != nil {
+			return rejected, err
+		}
+	}
+	return rejected, nil
+}
+
+func (p *OpenTSDBPoint) validate() error {
+	if err := checkTelnetName("metric", p.Metric); err != nil {
+		return err
+	}
+	if len(p.Tags) == 0 {
+		return fmt.Errorf("%w: metric %s has no tag", ErrInvalidPoint, p.Metric)
+	}
+	for k, v := range p.Tags {
+		if err := checkTelnetName("tag key", k); err != nil {
+			return err
+		}
+		if strings.ContainsRune(k, '=') {
+			return fmt.Errorf("%w: tag key %q contains =", ErrInvalidPoint, k)
+		}
+		if err := checkTelnetName("tag value of "+k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTelnetName checks names and tag values, which can not be escaped in telnet format
+func checkTelnetName(kind, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: %s is empty", ErrInvalidPoint, kind)
+	}
+	if strings.ContainsAny(name, " \t\r\n") {
+		return fmt.Errorf("%w: %s %q contains whitespace", ErrInvalidPoint, kind, name)
+	}
+	return nil
+}
+
+func (p *OpenTSDBPoint) timestamp() int64 {
+	if p.Time.IsZero() {
+		return 0
+	}
+	return p.Time.UnixNano() / int64(time.Millisecond)
+}
+
+// openTSDBType returns value and its type name of TDengine schemaless, e.g. i32 or f64
+func openTSDBType(value interface{}) (reflect.Value, string, error) {
+	if value == nil {
+		return reflect.Value{}, "", fmt.Errorf("%w: value is nil", ErrInvalidPoint)
+	}
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, "", fmt.Errorf("%w: value is nil", ErrInvalidPoint)
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int8:
+		return v, "i8", nil
+	case reflect.Int16:
+		return v, "i16", nil
+	case reflect.Int32:
+		return v, "i32", nil
+	case reflect.Int, reflect.Int64:
+		return v, "i64", nil
+	case reflect.Uint8:
+		return v, "u8", nil
+	case reflect.Uint16:
+		return v, "u16", nil
+	case reflect.Uint32:
+		return v, "u32", nil
+	case reflect.Uint, reflect.Uint64:
+		return v, "u64", nil
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return v, "", fmt.Errorf("%w: value is %v", ErrInvalidPoint, f)
+		}
+		if v.Kind() == reflect.Float32 {
+			return v, "f32", nil
+		}
+		return v, "f64", nil
+	case reflect.Bool:
+		return v, "bool", nil
+	case reflect.String:
+		return v, "binary", nil
+	}
+	return v, "", fmt.Errorf("%w: unsupported value type %T", ErrInvalidPoint, value)
+}
+
+func (p *OpenTSDBPoint) jsonPoint() (openTSDBJSONPoint, error) {
+	ret := openTSDBJSONPoint{Metric: p.Metric, Timestamp: p.timestamp(), Tags: p.Tags}
+	if err := p.validate(); err != nil {
+		return ret, err
+	}
+	v, t, err := openTSDBType(p.Value)
+	if err != nil {
+		return ret, err
+	}
+	switch t {
+	case "f64", "bool", "binary":
+		// default types of JSON values
+		ret.Value = v.Interface()
+	default:
+		ret.Value = openTSDBValue{Value: v.Interface(), Type: t}
+	}
+	return ret, nil
+}
+
+// TelnetLine encodes point as `<metric> <timestamp> <value> <tagk>=<tagv> ...` with tags sorted by key.
+func (p OpenTSDBPoint) TelnetLine() (string, error) {
+	if err := p.validate(); err != nil {
+		return "", err
+	}
+	v, t, err := openTSDBType(p.Value)
+	if err != nil {
+		return "", err
+	}
+	b := &strings.Builder{}
+	b.WriteString(p.Metric)
+	b.WriteRune(' ')
+	b.WriteString(strconv.FormatInt(p.timestamp(), 10))
+	b.WriteRune(' ')
+	switch t {
+	case "binary":
+		b.WriteRune('"')
+		b.WriteString(stringEscaper.Replace(v.String()))
+		b.WriteRune('"')
+	case "bool":
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case "f32":
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 32))
+		b.WriteString(t)
+	case "f64":
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+		b.WriteString(t)
+	case "u8", "u16", "u32", "u64":
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+		b.WriteString(t)
+	default:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+		b.WriteString(t)
+	}
+	for _, k := range sortedKeys(p.Tags) {
+		b.WriteRune(' ')
+		b.WriteString(k)
+		b.WriteRune('=')
+		b.WriteString(p.Tags[k])
+	}
+	return b.String(), nil
+}
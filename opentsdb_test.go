@@ -0,0 +1,80 @@
+package tdquery
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteOpenTSDB(t *testing.T) {
+	var lock sync.Mutex
+	bodies := make(map[string]string)
+	fail := false
+	s := newWSStandIn(t, map[string]*standInResult{"show dnodes": dnodes3x})
+	s.rest = func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"code": 1, "desc": "write failed"}`))
+			return
+		}
+		bodies[r.URL.Path] = string(body)
+	}
+	c := s.client(t, 1)
+	ctx := context.Background()
+	ts := time.Unix(1700000000, 0)
+	points := []OpenTSDBPoint{
+		{Metric: "sys.cpu", Time: ts, Value: int32(18), Tags: map[string]string{"host": "web01"}},
+		{Metric: "sys.cpu", Time: ts, Value: 1.5},
+		{Metric: "sys mem", Time: ts, Value: 1.5, Tags: map[string]string{"host": "web01"}},
+		{Metric: "sys.load", Time: ts, Value: 0.5, Tags: map[string]string{"host": "web02"}},
+	}
+
+	rejected, err := c.WriteOpenTSDBJSON(ctx, "power", points...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 2 || rejected[0].Index != 1 || rejected[1].Index != 2 || !errors.Is(rejected[0], ErrInvalidPoint) {
+		t.Errorf("rejected %v", rejected)
+	}
+	want := `[{"metric":"sys.cpu","timestamp":1700000000000,"value":{"value":18,"type":"i32"},"tags":{"host":"web01"}},` +
+		`{"metric":"sys.load","timestamp":1700000000000,"value":0.5,"tags":{"host":"web02"}}]`
+	if got := bodies["/opentsdb/v1/put/json/power"]; got != want {
+		t.Errorf("json body = %s\nwant %s", got, want)
+	}
+
+	rejected, err = c.WriteOpenTSDBTelnet(ctx, "power", points...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 2 || rejected[0].Index != 1 || rejected[1].Index != 2 {
+		t.Errorf("rejected %v", rejected)
+	}
+	want = "sys.cpu 1700000000000 18i32 host=web01\nsys.load 1700000000000 0.5f64 host=web02\n"
+	if got := bodies["/opentsdb/v1/put/telnet/power"]; got != want {
+		t.Errorf("telnet body = %q\nwant %q", got, want)
+	}
+
+	// rejected points are returned with errors of the request
+	lock.Lock()
+	fail = true
+	lock.Unlock()
+	var tdErr *TDEngineError
+	rejected, err = c.WriteOpenTSDBTelnet(ctx, "power", points...)
+	if !errors.As(err, &tdErr) || tdErr.Code != 1 || len(rejected) != 2 {
+		t.Errorf("failed write: %v, rejected %v", err, rejected)
+	}
+	if rejected, err := c.WriteOpenTSDBJSON(ctx, "power", points[1]); err != nil || len(rejected) != 1 {
+		t.Errorf("only invalid points: %v, rejected %v", err, rejected)
+	}
+	if _, err := c.WriteOpenTSDBJSON(ctx, "", points...); !errors.Is(err, ErrInvalidPoint) || !strings.Contains(err.Error(), "database") {
+		t.Errorf("empty database: %v", err)
+	}
+}
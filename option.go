@@ -65,3 +65,40 @@ func WithUrlDatabase() Option {
 		c.useUrlDB = true
 	}
 }
+
+// WithPrecision sets the precision of databases queried through REST, default is millisecond.
+// /rest/sqlt returns timestamps as numbers in the precision of the database without telling it,
+// so they are read in this unit. Results of WebSocket carry their own precision.
+func WithPrecision(precision Precision) Option {
+	return func(c *Client) {
+		c.precision = precision
+	}
+}
+
+// WithEncoder registers encoder for the type of sample, see Client.RegisterEncoder
+func WithEncoder(sample interface{}, encoder EncoderFunc) Option {
+	return func(c *Client) {
+		c.RegisterEncoder(sample, encoder)
+	}
+}
+
+// WithWebSocket sends queries through WebSocket api `/rest/ws` of taosAdapter, which needs TDengine 3.x.
+// Queries share at most conns connections for each broker, and results are fetched in blocks.
+func WithWebSocket(conns int) Option {
+	return func(c *Client) {
+		if conns <= 0 {
+			conns = 1
+		}
+		c.ws = newWSPool(c, conns)
+	}
+}
+
+// WithValidator checks sql of Query, QueryRows and Statement.Query after params are interpolated,
+// sql failing the check returns the error without a round-trip to TDengine.
+//
+//	tdquery.NewClient(tdquery.WithBrokers(brokers), tdquery.WithValidator(sqlparse.Validate))
+func WithValidator(validate func(sql string) error) Option {
+	return func(c *Client) {
+		c.validator = validate
+	}
+}
@@ -0,0 +1,339 @@
+// Package promread serves Prometheus remote_read from TDengine, so Prometheus and Grafana can query
+// metrics stored in super tables.
+//
+//	handler := promread.NewHandler(client, promread.WithTable("node_load1", promread.Table{STable: "load", ValueColumn: "v1"}))
+//	http.Handle("/api/v1/read", handler)
+//
+// Each super table is a metric, its tags are labels, and a child table is a series.
+package promread
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/prompb"
+)
+
+const (
+	metricLabel        = "__name__"
+	defaultTimeColumn  = "ts"
+	defaultValueColumn = "value"
+	maxRequestSize     = 32 << 20
+)
+
+var errBadQuery = errors.New("promread: bad query")
+
+// Table is the super table storing a metric
+type Table struct {
+	// Database defaults to the database of client
+	Database string
+	STable   string
+	// TimeColumn defaults to `ts`
+	TimeColumn string
+	// ValueColumn defaults to `value`, numbers and bools are converted to float64
+	ValueColumn string
+	// Tags maps label names to tag names, other labels use tags of the same name
+	Tags map[string]string
+	// MetricTag is the tag of metric names when the super table stores several metrics, like tables written by promwrite
+	MetricTag string
+}
+
+// Mapper returns the table of metric, ok is false when the metric is not stored in TDengine
+type Mapper func(metric string) (t Table, ok bool)
+
+type Option func(h *Handler)
+
+// WithTable maps metric to table, it takes precedence over WithMapper
+func WithTable(metric string, t Table) Option {
+	return func(h *Handler) {
+		h.tables[metric] = t
+	}
+}
+
+// WithMapper sets the mapping of metrics not set by WithTable, the default maps a metric to the super table of the same name.
+func WithMapper(m Mapper) Option {
+	return func(h *Handler) {
+		h.mapper = m
+	}
+}
+
+// Handler is an http.Handler of Prometheus remote_read, only the sampled response type is supported.
+// The metric of each query must be selected by an equality matcher on `__name__`.
+type Handler struct {
+	client *tdquery.Client
+	tables map[string]Table
+	mapper Mapper
+}
+
+func NewHandler(client *tdquery.Client, opts ...Option) *Handler {
+	h := &Handler{
+		client: client,
+		tables: make(map[string]Table),
+		mapper: func(metric string) (Table, bool) {
+			return Table{STable: metric}, true
+		},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	compressed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	queries, err := prompb.ParseReadRequest(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results := make([][]prompb.TimeSeries, len(queries))
+	for i, q := range queries {
+		results[i], err = h.query(r.Context(), q)
+		if errors.Is(err, errBadQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	w.Write(snappy.Encode(nil, prompb.AppendReadResponse(nil, results)))
+}
+
+func (h *Handler) table(metric string) (Table, bool) {
+	t, ok := h.tables[metric]
+	if !ok {
+		t, ok = h.mapper(metric)
+	}
+	if t.Database == "" {
+		t.Database = h.client.DefaultDatabase()
+	}
+	if t.TimeColumn == "" {
+		t.TimeColumn = defaultTimeColumn
+	}
+	if t.ValueColumn == "" {
+		t.ValueColumn = defaultValueColumn
+	}
+	return t, ok
+}
+
+// query returns series of q, unknown metrics and tables have no series
+func (h *Handler) query(ctx context.Context, q prompb.Query) ([]prompb.TimeSeries, error) {
+	metric := ""
+	for _, m := range q.Matchers {
+		if m.Name == metricLabel && m.Type == prompb.MatchEqual {
+			metric = m.Value
+		}
+	}
+	if metric == "" {
+		return nil, fmt.Errorf("%w, an equality matcher on %s is needed", errBadQuery, metricLabel)
+	}
+	t, ok := h.table(metric)
+	if !ok {
+		return nil, nil
+	}
+	columns, err := h.client.Describe(ctx, t.Database, t.STable)
+	if err != nil {
+		if tdquery.IsTableNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// label names of tags, in the order of selects
+	labels := make([]string, 0, len(columns))
+	tagOf := make(map[string]string, len(columns))
+	labelOf := make(map[string]string, len(t.Tags))
+	for label, tag := range t.Tags {
+		labelOf[tag] = label
+	}
+	selects := []tdquery.Select{{ColumnName: t.TimeColumn}, {ColumnName: t.ValueColumn}}
+	for _, c := range columns {
+		if !c.Tag || c.Name == t.MetricTag {
+			continue
+		}
+		label, ok := labelOf[c.Name]
+		if !ok {
+			label = c.Name
+		}
+		labels = append(labels, label)
+		tagOf[label] = c.Name
+		selects = append(selects, tdquery.Select{ColumnName: c.Name})
+	}
+	b := h.client.NewSelectQueryBuilder().Select(selects...).UseDatabase(t.Database).FromSTable(t.STable).
+		TimeColumn(t.TimeColumn).
+		WithTimeScope(time.Unix(0, q.Start*int64(time.Millisecond)), time.Unix(0, q.End*int64(time.Millisecond)))
+	if t.MetricTag != "" {
+		b.Where(tdquery.Equals(t.MetricTag, metric))
+	}
+	for _, m := range q.Matchers {
+		if m.Name == metricLabel {
+			continue
+		}
+		tag, ok := tagOf[m.Name]
+		if !ok {
+			// a missing label has the empty value
+			matched, err := matchEmpty(m)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				return nil, nil
+			}
+			continue
+		}
+		c, err := condition(tag, m)
+		if err != nil {
+			return nil, err
+		}
+		b.WherePredicate(c)
+	}
+	sql, params, err := b.BuildWithParams()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := h.client.QueryRows(ctx, sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	series := make(map[string]*prompb.TimeSeries)
+	keys := make([]string, 0)
+	key := &strings.Builder{}
+	for rows.Next() {
+		values := rows.Values()
+		ts, ok := values[0].(time.Time)
+		if !ok {
+			continue
+		}
+		v, ok := floatValue(values[1])
+		if !ok {
+			continue
+		}
+		key.Reset()
+		for _, tag := range values[2:] {
+			if tag != nil {
+				fmt.Fprint(key, tag)
+			}
+			key.WriteByte(0xff)
+		}
+		s, ok := series[key.String()]
+		if !ok {
+			s = &prompb.TimeSeries{Labels: []prompb.Label{{Name: metricLabel, Value: metric}}}
+			for i, tag := range values[2:] {
+				if tag == nil {
+					continue
+				}
+				if value := fmt.Sprint(tag); value != "" {
+					s.Labels = append(s.Labels, prompb.Label{Name: labels[i], Value: value})
+				}
+			}
+			sort.Slice(s.Labels, func(i, j int) bool { return s.Labels[i].Name < s.Labels[j].Name })
+			series[key.String()] = s
+			keys = append(keys, key.String())
+		}
+		s.Samples = append(s.Samples, prompb.Sample{Value: v, Timestamp: ts.UnixNano() / int64(time.Millisecond)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	ret := make([]prompb.TimeSeries, 0, len(keys))
+	for _, k := range keys {
+		s := series[k]
+		sort.SliceStable(s.Samples, func(i, j int) bool { return s.Samples[i].Timestamp < s.Samples[j].Timestamp })
+		ret = append(ret, *s)
+	}
+	return ret, nil
+}
+
+// condition translates a matcher to a condition on tag. Regular expressions of Prometheus are anchored,
+// empty values match NULL tags, so `label=""` is `tag IS NULL`, and matchers which match the empty value
+// like `label!="a"` also match NULL tags: `(tag != 'a' OR tag IS NULL)`.
+// The RE2 pattern is passed to the POSIX regular expressions of TDengine unchanged, so RE2 only syntax
+// like `\d` or `(?i)` is not supported by TDengine.
+func condition(tag string, m prompb.LabelMatcher) (tdquery.Predicate, error) {
+	empty, err := matchEmpty(m)
+	if err != nil {
+		return nil, err
+	}
+	var c *tdquery.Condition
+	switch m.Type {
+	case prompb.MatchEqual:
+		if m.Value == "" {
+			return tdquery.IsNull(tag), nil
+		}
+		c = tdquery.Equals(tag, m.Value)
+	case prompb.MatchNotEqual:
+		if m.Value == "" {
+			return tdquery.IsNotNull(tag), nil
+		}
+		c = tdquery.NotEquals(tag, m.Value)
+	case prompb.MatchRegexp:
+		c = tdquery.Match(tag, "^("+m.Value+")$")
+	case prompb.MatchNotRegexp:
+		c = tdquery.NotMatch(tag, "^("+m.Value+")$")
+	}
+	if empty {
+		return tdquery.Or(c, tdquery.IsNull(tag)), nil
+	}
+	return c, nil
+}
+
+// matchEmpty reports whether m matches the empty value of a missing label
+func matchEmpty(m prompb.LabelMatcher) (bool, error) {
+	switch m.Type {
+	case prompb.MatchEqual:
+		return m.Value == "", nil
+	case prompb.MatchNotEqual:
+		return m.Value != "", nil
+	case prompb.MatchRegexp, prompb.MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + m.Value + ")$")
+		if err != nil {
+			return false, fmt.Errorf("%w, %v", errBadQuery, err)
+		}
+		return re.MatchString("") == (m.Type == prompb.MatchRegexp), nil
+	}
+	return false, fmt.Errorf("%w, unknown matcher type %d", errBadQuery, m.Type)
+}
+
+func floatValue(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int64:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,225 @@
+package promread
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/prompb"
+	"github.com/snownd/tdquery/internal/standin"
+)
+
+func TestCondition(t *testing.T) {
+	cases := []struct {
+		matcher prompb.LabelMatcher
+		sql     string
+		params  []interface{}
+	}{
+		{prompb.LabelMatcher{Type: prompb.MatchEqual, Value: "a"}, "t = ?", []interface{}{"a"}},
+		{prompb.LabelMatcher{Type: prompb.MatchEqual}, "t IS NULL", []interface{}{}},
+		// series without the label match != and !~ in Prometheus
+		{prompb.LabelMatcher{Type: prompb.MatchNotEqual, Value: "a"}, "(t != ? OR t IS NULL)", []interface{}{"a"}},
+		{prompb.LabelMatcher{Type: prompb.MatchNotEqual}, "t IS NOT NULL", []interface{}{}},
+		{prompb.LabelMatcher{Type: prompb.MatchRegexp, Value: "a|b"}, "t MATCH ?", []interface{}{"^(a|b)$"}},
+		{prompb.LabelMatcher{Type: prompb.MatchRegexp, Value: "a|"}, "(t MATCH ? OR t IS NULL)", []interface{}{"^(a|)$"}},
+		{prompb.LabelMatcher{Type: prompb.MatchRegexp, Value: ".*"}, "(t MATCH ? OR t IS NULL)", []interface{}{"^(.*)$"}},
+		{prompb.LabelMatcher{Type: prompb.MatchNotRegexp, Value: "a.*"}, "(t NMATCH ? OR t IS NULL)", []interface{}{"^(a.*)$"}},
+		{prompb.LabelMatcher{Type: prompb.MatchNotRegexp, Value: "a*"}, "t NMATCH ?", []interface{}{"^(a*)$"}},
+	}
+	c := tdquery.NewClient()
+	for _, tc := range cases {
+		p, err := condition("t", tc.matcher)
+		if err != nil {
+			t.Errorf("condition(%+v): %v", tc.matcher, err)
+			continue
+		}
+		sql, params, err := c.NewSelectQueryBuilder().SelectColumn("v").FromTables("d").WherePredicate(p).BuildWithParams()
+		if err != nil {
+			t.Errorf("condition(%+v): %v", tc.matcher, err)
+			continue
+		}
+		if want := "SELECT v FROM d WHERE " + tc.sql; sql != want || !reflect.DeepEqual(params, tc.params) {
+			t.Errorf("condition(%+v) = %s %v, want %s %v", tc.matcher, sql, params, want, tc.params)
+		}
+	}
+	for _, m := range []prompb.LabelMatcher{{Type: prompb.MatchRegexp, Value: "("}, {Type: prompb.MatchNotRegexp, Value: "["}, {Type: 9}} {
+		if _, err := condition("t", m); !errors.Is(err, errBadQuery) {
+			t.Errorf("condition(%+v) = %v", m, err)
+		}
+	}
+}
+
+func TestMatchEmpty(t *testing.T) {
+	cases := []struct {
+		matcher prompb.LabelMatcher
+		want    bool
+	}{
+		{prompb.LabelMatcher{Type: prompb.MatchEqual}, true},
+		{prompb.LabelMatcher{Type: prompb.MatchEqual, Value: "a"}, false},
+		{prompb.LabelMatcher{Type: prompb.MatchNotEqual}, false},
+		{prompb.LabelMatcher{Type: prompb.MatchNotEqual, Value: "a"}, true},
+		{prompb.LabelMatcher{Type: prompb.MatchRegexp, Value: "a*"}, true},
+		{prompb.LabelMatcher{Type: prompb.MatchRegexp, Value: "a|b"}, false},
+		// the expression is anchored as a whole
+		{prompb.LabelMatcher{Type: prompb.MatchRegexp, Value: "a|"}, true},
+		{prompb.LabelMatcher{Type: prompb.MatchNotRegexp, Value: "a+"}, true},
+		{prompb.LabelMatcher{Type: prompb.MatchNotRegexp, Value: ".*"}, false},
+	}
+	for _, c := range cases {
+		got, err := matchEmpty(c.matcher)
+		if err != nil {
+			t.Errorf("matchEmpty(%+v): %v", c.matcher, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("matchEmpty(%+v) = %v, want %v", c.matcher, got, c.want)
+		}
+	}
+	for _, m := range []prompb.LabelMatcher{{Type: prompb.MatchRegexp, Value: "("}, {Type: 9}} {
+		if _, err := matchEmpty(m); !errors.Is(err, errBadQuery) {
+			t.Errorf("matchEmpty(%+v) = %v", m, err)
+		}
+	}
+}
+
+// loadTable answers DESCRIBE and SELECT of the super table `load`, other tables do not exist
+func loadTable(sql string) *standin.Result {
+	switch {
+	case sql == "DESCRIBE power.load":
+		return &standin.Result{
+			Columns: []tdquery.ColumnMeta{
+				{Name: "field", Type: tdquery.ColumnTypeBinary, Length: 64},
+				{Name: "type", Type: tdquery.ColumnTypeBinary, Length: 16},
+				{Name: "length", Type: tdquery.ColumnTypeInt, Length: 4},
+				{Name: "note", Type: tdquery.ColumnTypeBinary, Length: 16},
+			},
+			Rows: [][]interface{}{
+				{"ts", "TIMESTAMP", 8, ""},
+				{"v1", "DOUBLE", 8, ""},
+				{"host", "BINARY", 32, "TAG"},
+				{"dc", "NCHAR", 32, "TAG"},
+			},
+		}
+	case strings.HasPrefix(sql, "SELECT ts, v1, host, dc FROM power.load "):
+		return &standin.Result{
+			Columns: []tdquery.ColumnMeta{
+				{Name: "ts", Type: tdquery.ColumnTypeTimestamp, Length: 8},
+				{Name: "v1", Type: tdquery.ColumnTypeDouble, Length: 8},
+				{Name: "host", Type: tdquery.ColumnTypeBinary, Length: 32},
+				{Name: "dc", Type: tdquery.ColumnTypeNchar, Length: 32},
+			},
+			Rows: [][]interface{}{
+				{1700000002000, 0.5, "b", nil},
+				{1700000001000, 1.5, "a", "east"},
+				{1700000000000, 1.25, "a", "east"},
+				{1700000003000, nil, "b", nil},
+			},
+		}
+	}
+	return standin.Error(standin.TableNotExist, "Table does not exist")
+}
+
+func read(t *testing.T, h http.Handler, queries ...prompb.Query) (*httptest.ResponseRecorder, [][]prompb.TimeSeries) {
+	body := snappy.Encode(nil, prompb.AppendReadRequest(nil, queries))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/read", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		return w, nil
+	}
+	data, err := snappy.Decode(nil, w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := prompb.ParseReadResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, results
+}
+
+func TestHandler(t *testing.T) {
+	s := standin.New(t, loadTable)
+	h := NewHandler(s.Client(t, tdquery.WithDatabase("power")),
+		WithTable("node_load1", Table{STable: "load", ValueColumn: "v1", Tags: map[string]string{"instance": "host"}}))
+	metric := prompb.LabelMatcher{Type: prompb.MatchEqual, Name: metricLabel, Value: "node_load1"}
+
+	w, results := read(t, h,
+		prompb.Query{Start: 1700000000000, End: 1700003600000, Matchers: []prompb.LabelMatcher{
+			metric,
+			{Type: prompb.MatchRegexp, Name: "instance", Value: "a|b"},
+			{Type: prompb.MatchEqual, Name: "job", Value: ""},
+			// series without dc match too
+			{Type: prompb.MatchNotEqual, Name: "dc", Value: "west"},
+		}},
+		// a missing label does not match a value
+		prompb.Query{Start: 0, End: 1, Matchers: []prompb.LabelMatcher{metric, {Type: prompb.MatchEqual, Name: "job", Value: "node"}}},
+		// a metric of no table
+		prompb.Query{Start: 0, End: 1, Matchers: []prompb.LabelMatcher{{Type: prompb.MatchEqual, Name: metricLabel, Value: "up"}}},
+	)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "snappy" {
+		t.Errorf("Content-Encoding = %s", encoding)
+	}
+	want := [][]prompb.TimeSeries{
+		{
+			{
+				Labels:  []prompb.Label{{Name: metricLabel, Value: "node_load1"}, {Name: "dc", Value: "east"}, {Name: "instance", Value: "a"}},
+				Samples: []prompb.Sample{{Value: 1.25, Timestamp: 1700000000000}, {Value: 1.5, Timestamp: 1700000001000}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: metricLabel, Value: "node_load1"}, {Name: "instance", Value: "b"}},
+				Samples: []prompb.Sample{{Value: 0.5, Timestamp: 1700000002000}},
+			},
+		},
+		nil,
+		nil,
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %+v\nwant %+v", results, want)
+	}
+	sqls := s.SQLs()
+	wantSQLs := []string{
+		"DESCRIBE power.load",
+		"SELECT ts, v1, host, dc FROM power.load WHERE ts BETWEEN 1700000000000 AND 1700003600000 AND host MATCH '^(a|b)$' AND (dc != 'west' OR dc IS NULL)",
+		"DESCRIBE power.load",
+		"DESCRIBE power.up",
+	}
+	if !reflect.DeepEqual(sqls, wantSQLs) {
+		t.Errorf("sqls = %q\nwant %q", sqls, wantSQLs)
+	}
+}
+
+func TestHandlerBadRequest(t *testing.T) {
+	s := standin.New(t, loadTable)
+	h := NewHandler(s.Client(t, tdquery.WithDatabase("power")), WithTable("node_load1", Table{STable: "load", ValueColumn: "v1"}))
+	queries := [][]prompb.LabelMatcher{
+		{{Type: prompb.MatchRegexp, Name: metricLabel, Value: "node_.*"}},
+		{{Type: prompb.MatchEqual, Name: metricLabel, Value: "node_load1"}, {Type: prompb.MatchRegexp, Name: "host", Value: "("}},
+	}
+	for _, matchers := range queries {
+		if w, _ := read(t, h, prompb.Query{Matchers: matchers}); w.Code != http.StatusBadRequest {
+			t.Errorf("%+v: status %d", matchers, w.Code)
+		}
+	}
+	for _, body := range []io.Reader{strings.NewReader("not snappy"), bytes.NewReader(snappy.Encode(nil, []byte{0xff}))} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/read", body))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("malformed body: status %d", w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/read", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d", w.Code)
+	}
+}
@@ -0,0 +1,454 @@
+// Package promwrite receives Prometheus remote_write into a super table of TDengine.
+//
+//	handler := promwrite.NewHandler(client, "metrics", promwrite.WithDatabase("prometheus"))
+//	http.Handle("/api/v1/write", handler)
+//
+// The super table has columns `ts` and `value`, the metric name is a tag and other labels are tags of their lowercase names.
+// It is created on the first write, and tags are added when new labels arrive.
+// Each series is a child table named `t_` with md5 of its labels, so long label sets have short names.
+//
+// TDengine limits the number and the total length of tags, labels which can not be tags are handled by LimitPolicy.
+// Series without a metric name, NaN and infinite samples, like Prometheus staleness markers, are never written.
+package promwrite
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/snappy"
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/prompb"
+)
+
+const (
+	metricLabel      = "__name__"
+	defaultMetricTag = "metric"
+	defaultTagLength = 128
+	defaultBatchSize = 1000
+	maxRequestSize   = 32 << 20
+	tablePrefix      = "t_"
+)
+
+// limits of TDengine
+const (
+	maxTags          = 128
+	maxTagsLength    = 16384
+	maxTagNameLength = 64
+)
+
+// LimitPolicy decides what to do with a series whose labels can not all be stored as tags,
+// because there are too many tags, a value is longer than the tag length, a name is refused by TDengine,
+// or lowercase names of labels collide, in which case the first label in name order is kept.
+type LimitPolicy int
+
+const (
+	// Truncate drops labels which can not be tags and truncates long values, so every series is written.
+	// Series which only differ in dropped labels or truncated values get the same tags in different child tables,
+	// and promread returns them merged into one series. Use Reject when such series must be kept apart.
+	Truncate LimitPolicy = iota
+	// Reject skips such series, the others are written and the request fails with 400, which Prometheus does not retry
+	Reject
+)
+
+type Option func(h *Handler)
+
+// WithDatabase sets database of the super table, it defaults to the database of client
+func WithDatabase(db string) Option {
+	return func(h *Handler) {
+		h.database = db
+	}
+}
+
+// WithMetricTag sets the tag of metric names, default is `metric`
+func WithMetricTag(name string) Option {
+	return func(h *Handler) {
+		h.metricTag = name
+	}
+}
+
+// WithTagLength sets length of BINARY tags created for labels, default is 128 bytes.
+// Longer tags allow fewer tags, because TDengine limits the total length of tags to 16KB.
+// NewHandler panics if n is not positive or does not fit in 16KB.
+func WithTagLength(n int) Option {
+	return func(h *Handler) {
+		h.tagLength = n
+	}
+}
+
+// WithMaxTags limits number of tags including the metric tag, it can only be lower than the limit of TDengine
+func WithMaxTags(n int) Option {
+	return func(h *Handler) {
+		h.maxTags = n
+	}
+}
+
+func WithLimitPolicy(p LimitPolicy) Option {
+	return func(h *Handler) {
+		h.policy = p
+	}
+}
+
+// WithBatchSize sets max rows of an insert statement, default is 1000, NewHandler panics if rows is not positive
+func WithBatchSize(rows int) Option {
+	return func(h *Handler) {
+		h.batchSize = rows
+	}
+}
+
+// Handler is an http.Handler of Prometheus remote_write.
+// Failed inserts respond 500 and are retried by Prometheus, rewriting the same samples is harmless
+// because TDengine keeps one row per timestamp of a table.
+type Handler struct {
+	client    *tdquery.Client
+	database  string
+	stable    string
+	metricTag string
+	tagLength int
+	maxTags   int
+	batchSize int
+	policy    LimitPolicy
+	// ddl serializes loading and altering the super table, it is not held by writes which need no new tag
+	ddl sync.Mutex
+	// lock guards tags and refused, it is never held during network calls
+	lock sync.Mutex
+	// tags of the super table, it is nil before loaded
+	tags map[string]bool
+	// refused are tag names refused by TDengine
+	refused map[string]bool
+}
+
+// childTable is a series to insert
+type childTable struct {
+	name     string
+	tagNames []string
+	tags     []interface{}
+	samples  []prompb.Sample
+}
+
+func NewHandler(client *tdquery.Client, stable string, opts ...Option) *Handler {
+	h := &Handler{
+		client:    client,
+		stable:    stable,
+		metricTag: defaultMetricTag,
+		tagLength: defaultTagLength,
+		maxTags:   maxTags,
+		batchSize: defaultBatchSize,
+		refused:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.database == "" {
+		h.database = client.DefaultDatabase()
+	}
+	if h.tagLength <= 0 || h.tagLength+2 > maxTagsLength {
+		panic("promwrite: invalid tag length " + strconv.Itoa(h.tagLength))
+	}
+	if h.batchSize <= 0 {
+		panic("promwrite: invalid batch size " + strconv.Itoa(h.batchSize))
+	}
+	// a BINARY tag takes 2 more bytes for its length
+	if n := maxTagsLength / (h.tagLength + 2); n < h.maxTags {
+		h.maxTags = n
+	}
+	if h.maxTags > maxTags {
+		h.maxTags = maxTags
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	compressed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	series, err := prompb.ParseWriteRequest(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rejected, err := h.write(r.Context(), series)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rejected > 0 {
+		http.Error(w, fmt.Sprintf("promwrite: %d of %d series rejected", rejected, len(series)), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// write writes series and returns number of series rejected by LimitPolicy Reject or without a metric name
+func (h *Handler) write(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	tables, rejected, err := h.prepare(ctx, series)
+	if err != nil || len(tables) == 0 {
+		return rejected, err
+	}
+	// timestamps of Prometheus are milliseconds, the database may be in microseconds or nanoseconds
+	precision, err := h.client.DatabasePrecision(ctx, h.database)
+	if err != nil {
+		return rejected, err
+	}
+	scale := int64(time.Millisecond) / int64(precision.Unit())
+	b := h.newInsert()
+	for _, t := range tables {
+		for len(t.samples) > 0 {
+			n := h.batchSize - b.Rows()
+			if n > len(t.samples) {
+				n = len(t.samples)
+			}
+			b.Into(t.name).Using(h.stable, t.tags...).TagNames(t.tagNames...).Columns("ts", "value")
+			for _, s := range t.samples[:n] {
+				b.Values(s.Timestamp*scale, s.Value)
+			}
+			t.samples = t.samples[n:]
+			if b.Rows() >= h.batchSize {
+				if err := b.Exec(ctx); err != nil {
+					return rejected, err
+				}
+				b = h.newInsert()
+			}
+		}
+	}
+	if b.Rows() > 0 {
+		if err := b.Exec(ctx); err != nil {
+			return rejected, err
+		}
+	}
+	return rejected, nil
+}
+
+func (h *Handler) newInsert() *tdquery.InsertBuilder {
+	return h.client.NewInsertBuilder().UseDatabase(h.database)
+}
+
+// prepare maps series to child tables, adding tags to the super table when needed.
+// Tags are looked up under lock, and missing tags are added without holding it.
+func (h *Handler) prepare(ctx context.Context, series []prompb.TimeSeries) ([]*childTable, int, error) {
+	tags, refused := h.schema()
+	if names := h.missing(series, tags, refused); tags == nil || len(names) > 0 {
+		if err := h.extend(ctx, names); err != nil {
+			return nil, 0, err
+		}
+		tags, _ = h.schema()
+	}
+	tables := make([]*childTable, 0, len(series))
+	rejected := 0
+	for _, s := range series {
+		t := h.childTable(s, tags)
+		if t == nil {
+			rejected++
+			continue
+		}
+		if len(t.samples) > 0 {
+			tables = append(tables, t)
+		}
+	}
+	return tables, rejected, nil
+}
+
+// schema returns copies of tags of the super table and of refused names, tags is nil before loaded
+func (h *Handler) schema() (map[string]bool, map[string]bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.tags == nil {
+		return nil, nil
+	}
+	tags := make(map[string]bool, len(h.tags))
+	for name := range h.tags {
+		tags[name] = true
+	}
+	refused := make(map[string]bool, len(h.refused))
+	for name := range h.refused {
+		refused[name] = true
+	}
+	return tags, refused
+}
+
+// missing returns names of labels which may be added as tags, in order of appearance
+func (h *Handler) missing(series []prompb.TimeSeries, tags, refused map[string]bool) []string {
+	names := make([]string, 0)
+	if tags != nil && len(tags) >= h.maxTags {
+		return names
+	}
+	seen := make(map[string]bool)
+	for _, s := range series {
+		for _, l := range s.Labels {
+			name := strings.ToLower(l.Name)
+			if l.Name == metricLabel || l.Value == "" || seen[name] || tags[name] || refused[name] || len(name) > maxTagNameLength {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// extend loads the super table and adds tags for names, tags may have been added by another request meanwhile
+func (h *Handler) extend(ctx context.Context, names []string) error {
+	h.ddl.Lock()
+	defer h.ddl.Unlock()
+	// h.tags and h.refused are only changed with ddl held, so they can be read here without lock
+	if h.tags == nil {
+		if err := h.load(ctx); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if h.tags[name] || h.refused[name] {
+			continue
+		}
+		if len(h.tags) >= h.maxTags {
+			return nil
+		}
+		if err := h.addTag(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads tags of the super table, it is created when it does not exist
+func (h *Handler) load(ctx context.Context) error {
+	columns, err := h.client.Describe(ctx, h.database, h.stable)
+	if tdquery.IsTableNotExist(err) {
+		err = h.client.NewCreateSTable(h.stable).UseDatabase(h.database).IfNotExists().
+			Columns(tdquery.NewColumn("ts", tdquery.ColumnTypeTimestamp), tdquery.NewColumn("value", tdquery.ColumnTypeDouble)).
+			Tags(tdquery.NewSizedColumn(h.metricTag, tdquery.ColumnTypeBinary, h.tagLength)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		columns, err = h.client.Describe(ctx, h.database, h.stable)
+	}
+	if err != nil {
+		return err
+	}
+	tags := make(map[string]bool)
+	for _, c := range columns {
+		if c.Tag {
+			tags[c.Name] = true
+		}
+	}
+	if !tags[h.metricTag] {
+		return fmt.Errorf("promwrite: super table %s has no tag %s", h.stable, h.metricTag)
+	}
+	h.lock.Lock()
+	h.tags = tags
+	h.lock.Unlock()
+	return nil
+}
+
+// addTag adds a tag for label name, the name is refused when TDengine fails to add it
+func (h *Handler) addTag(ctx context.Context, name string) error {
+	err := h.client.NewAlterSTable(h.stable).UseDatabase(h.database).
+		AddTag(tdquery.NewSizedColumn(name, tdquery.ColumnTypeBinary, h.tagLength)).
+		Exec(ctx)
+	var tdErr *tdquery.TDEngineError
+	if errors.As(err, &tdErr) {
+		// the tag may be added by another writer, otherwise TDengine refuses the name
+		if err := h.load(ctx); err != nil {
+			return err
+		}
+		if !h.tags[name] {
+			h.lock.Lock()
+			h.refused[name] = true
+			h.lock.Unlock()
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	h.lock.Lock()
+	h.tags[name] = true
+	h.lock.Unlock()
+	return nil
+}
+
+// childTable maps a series to a child table with tags of the super table, it returns nil if the series is rejected
+func (h *Handler) childTable(s prompb.TimeSeries, tags map[string]bool) *childTable {
+	labels := append([]prompb.Label(nil), s.Labels...)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	hash := md5.New()
+	metric := ""
+	for _, l := range labels {
+		if l.Name == metricLabel {
+			metric = l.Value
+		}
+		hash.Write([]byte(l.Name))
+		hash.Write([]byte{0xff})
+		hash.Write([]byte(l.Value))
+		hash.Write([]byte{0xff})
+	}
+	if metric == "" {
+		return nil
+	}
+	t := &childTable{name: tablePrefix + hex.EncodeToString(hash.Sum(nil))}
+	used := map[string]bool{h.metricTag: true}
+	for _, l := range labels {
+		name, value := strings.ToLower(l.Name), l.Value
+		if value == "" {
+			// an empty value is the same as a missing label
+			continue
+		}
+		if l.Name == metricLabel {
+			name = h.metricTag
+		} else {
+			if used[name] || !tags[name] {
+				if h.policy == Reject {
+					return nil
+				}
+				continue
+			}
+			used[name] = true
+		}
+		if len(value) > h.tagLength {
+			if h.policy == Reject {
+				return nil
+			}
+			value = truncate(value, h.tagLength)
+		}
+		t.tagNames = append(t.tagNames, name)
+		t.tags = append(t.tags, value)
+	}
+	for _, sample := range s.Samples {
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		t.samples = append(t.samples, sample)
+	}
+	return t
+}
+
+// truncate cuts s to at most n bytes without breaking a UTF-8 character
+func truncate(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
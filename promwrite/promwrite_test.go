@@ -0,0 +1,305 @@
+package promwrite
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/prompb"
+	"github.com/snownd/tdquery/internal/standin"
+)
+
+// stable is a stand-in of the super table prom.metrics, it is created by CREATE STABLE and altered by ADD TAG.
+// The database prom is in precision, default is ms.
+type stable struct {
+	precision string
+	lock      sync.Mutex
+	tags      []string
+	refuse    map[string]bool
+	inserts   []string
+}
+
+func (s *stable) handle(sql string) *standin.Result {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch {
+	case sql == "SELECT SERVER_VERSION()":
+		return &standin.Result{Columns: []tdquery.ColumnMeta{{Name: "server_version()", Type: tdquery.ColumnTypeBinary}}, Rows: [][]interface{}{{"2.4.0.0"}}}
+	case sql == "SHOW DATABASES":
+		precision := s.precision
+		if precision == "" {
+			precision = "ms"
+		}
+		return &standin.Result{
+			Columns: []tdquery.ColumnMeta{{Name: "name", Type: tdquery.ColumnTypeBinary}, {Name: "precision", Type: tdquery.ColumnTypeBinary}},
+			Rows:    [][]interface{}{{"prom", precision}},
+		}
+	case sql == "DESCRIBE prom.metrics":
+		if s.tags == nil {
+			return standin.Error(standin.TableNotExist, "Table does not exist")
+		}
+		res := &standin.Result{
+			Columns: []tdquery.ColumnMeta{
+				{Name: "field", Type: tdquery.ColumnTypeBinary, Length: 64},
+				{Name: "type", Type: tdquery.ColumnTypeBinary, Length: 16},
+				{Name: "length", Type: tdquery.ColumnTypeInt, Length: 4},
+				{Name: "note", Type: tdquery.ColumnTypeBinary, Length: 16},
+			},
+			Rows: [][]interface{}{{"ts", "TIMESTAMP", 8, ""}, {"value", "DOUBLE", 8, ""}},
+		}
+		for _, tag := range s.tags {
+			res.Rows = append(res.Rows, []interface{}{tag, "BINARY", 16, "TAG"})
+		}
+		return res
+	case strings.HasPrefix(sql, "CREATE STABLE IF NOT EXISTS prom.metrics "):
+		if s.tags == nil {
+			s.tags = []string{"metric"}
+		}
+	case strings.HasPrefix(sql, "ALTER STABLE prom.metrics ADD TAG "):
+		name := strings.Fields(sql)[5]
+		if s.refuse[name] {
+			return standin.Error(0x2617, "Invalid tag name")
+		}
+		s.tags = append(s.tags, name)
+	case strings.HasPrefix(sql, "INSERT INTO "):
+		s.inserts = append(s.inserts, sql)
+		return standin.Affected(1)
+	}
+	return nil
+}
+
+func series(samples []prompb.Sample, labels ...string) prompb.TimeSeries {
+	s := prompb.TimeSeries{Samples: samples}
+	for i := 0; i < len(labels); i += 2 {
+		s.Labels = append(s.Labels, prompb.Label{Name: labels[i], Value: labels[i+1]})
+	}
+	return s
+}
+
+// tableName is md5 of labels in name order
+func tableName(s prompb.TimeSeries) string {
+	labels := append([]prompb.Label(nil), s.Labels...)
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	hash := md5.New()
+	for _, l := range labels {
+		hash.Write([]byte(l.Name + "\xff" + l.Value + "\xff"))
+	}
+	return tablePrefix + hex.EncodeToString(hash.Sum(nil))
+}
+
+func TestChildTable(t *testing.T) {
+	h := NewHandler(tdquery.NewClient(), "metrics", WithTagLength(4))
+	tags := map[string]bool{"metric": true, "job": true, "zone": true}
+	samples := []prompb.Sample{{Value: 1, Timestamp: 1000}, {Value: math.NaN(), Timestamp: 2000}, {Value: math.Inf(-1), Timestamp: 3000}, {Value: -1, Timestamp: 4000}}
+	cases := []struct {
+		name     string
+		series   prompb.TimeSeries
+		tagNames []string
+		tags     []interface{}
+		// rejected by Reject, tags are written by Truncate, series without tags are always rejected
+		rejected bool
+	}{
+		{
+			name:     "lowercase names",
+			series:   series(samples, "zone", "a", "__name__", "up", "Job", "node"),
+			tagNames: []string{"job", "metric", "zone"},
+			tags:     []interface{}{"node", "up", "a"},
+		},
+		{
+			name:     "empty values",
+			series:   series(samples, "__name__", "up", "job", ""),
+			tagNames: []string{"metric"},
+			tags:     []interface{}{"up"},
+		},
+		{
+			name:     "collision keeps the first name",
+			series:   series(samples, "job", "b", "__name__", "up", "JOB", "a"),
+			tagNames: []string{"job", "metric"},
+			tags:     []interface{}{"a", "up"},
+			rejected: true,
+		},
+		{
+			name:     "unknown tag",
+			series:   series(samples, "__name__", "up", "region", "x"),
+			tagNames: []string{"metric"},
+			tags:     []interface{}{"up"},
+			rejected: true,
+		},
+		{
+			name:     "long values",
+			series:   series(samples, "__name__", "uptime", "zone", "ab电"),
+			tagNames: []string{"metric", "zone"},
+			tags:     []interface{}{"upti", "ab"},
+			rejected: true,
+		},
+		{
+			name:     "no metric",
+			series:   series(samples, "job", "node"),
+			rejected: true,
+		},
+	}
+	for _, policy := range []LimitPolicy{Truncate, Reject} {
+		h.policy = policy
+		for _, c := range cases {
+			got := h.childTable(c.series, tags)
+			if c.rejected && (policy == Reject || c.tagNames == nil) {
+				if got != nil {
+					t.Errorf("%s, policy %d: series is not rejected, %+v", c.name, policy, got)
+				}
+				continue
+			}
+			if got == nil {
+				t.Errorf("%s, policy %d: series is rejected", c.name, policy)
+				continue
+			}
+			want := &childTable{
+				name:     tableName(c.series),
+				tagNames: c.tagNames,
+				tags:     c.tags,
+				samples:  []prompb.Sample{samples[0], samples[3]},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s, policy %d: childTable() = %+v\nwant %+v", c.name, policy, got, want)
+			}
+		}
+	}
+}
+
+func write(h http.Handler, series ...prompb.TimeSeries) *httptest.ResponseRecorder {
+	body := snappy.Encode(nil, prompb.AppendWriteRequest(nil, series))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body)))
+	return w
+}
+
+var rowPattern = regexp.MustCompile(`\(\d+, [-\d.]+\)`)
+
+func TestHandler(t *testing.T) {
+	st := &stable{refuse: map[string]bool{"bad": true}}
+	s := standin.New(t, st.handle)
+	h := NewHandler(s.Client(t, tdquery.WithDatabase("prom")), "metrics")
+	node := series([]prompb.Sample{{Value: 1.5, Timestamp: 1000}, {Value: 2.5, Timestamp: 2000}},
+		"__name__", "up", "job", "node", "Instance", "h1", "bad", "x")
+	api := series([]prompb.Sample{{Value: 3.5, Timestamp: 1000}}, "__name__", "up", "job", "api")
+	if w := write(h, node, api, series(nil, "job", "x")); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "1 of 3 series rejected") {
+		t.Errorf("series without metric name: status %d, %s", w.Code, w.Body)
+	}
+	nodeInsert := "INSERT INTO prom." + tableName(node) +
+		" USING prom.metrics (instance, metric, job) TAGS ('h1', 'up', 'node') (ts, value) VALUES (1000, 1.5) (2000, 2.5)"
+	want := []string{
+		"DESCRIBE prom.metrics",
+		"CREATE STABLE IF NOT EXISTS prom.metrics (ts TIMESTAMP, value DOUBLE) TAGS (metric BINARY(128))",
+		"DESCRIBE prom.metrics",
+		"ALTER STABLE prom.metrics ADD TAG job BINARY(128)",
+		"ALTER STABLE prom.metrics ADD TAG instance BINARY(128)",
+		"ALTER STABLE prom.metrics ADD TAG bad BINARY(128)",
+		"DESCRIBE prom.metrics",
+		"SELECT SERVER_VERSION()",
+		"SHOW DATABASES",
+		nodeInsert + " prom." + tableName(api) + " USING prom.metrics (metric, job) TAGS ('up', 'api') (ts, value) VALUES (1000, 3.5)",
+	}
+	if got := s.SQLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sqls = %q\nwant %q", got, want)
+	}
+
+	// tags and precision are known and refused names are not added again
+	s.Reset()
+	if w := write(h, node); w.Code != http.StatusNoContent {
+		t.Errorf("status %d, %s", w.Code, w.Body)
+	}
+	if got := s.SQLs(); !reflect.DeepEqual(got, []string{nodeInsert}) {
+		t.Errorf("sqls = %q", got)
+	}
+}
+
+func TestHandlerPrecision(t *testing.T) {
+	cases := []struct {
+		precision string
+		values    string
+	}{
+		{"ms", "VALUES (1700000000123, 1.5)"},
+		{"us", "VALUES (1700000000123000, 1.5)"},
+		{"ns", "VALUES (1700000000123000000, 1.5)"},
+	}
+	for _, c := range cases {
+		st := &stable{precision: c.precision}
+		s := standin.New(t, st.handle)
+		h := NewHandler(s.Client(t, tdquery.WithDatabase("prom")), "metrics")
+		if w := write(h, series([]prompb.Sample{{Value: 1.5, Timestamp: 1700000000123}}, "__name__", "up")); w.Code != http.StatusNoContent {
+			t.Fatalf("%s: status %d, %s", c.precision, w.Code, w.Body)
+		}
+		if len(st.inserts) != 1 || !strings.HasSuffix(st.inserts[0], c.values) {
+			t.Errorf("%s: inserts = %q, want values %s", c.precision, st.inserts, c.values)
+		}
+	}
+}
+
+func TestHandlerBatch(t *testing.T) {
+	st := &stable{}
+	s := standin.New(t, st.handle)
+	h := NewHandler(s.Client(t, tdquery.WithDatabase("prom")), "metrics", WithBatchSize(2))
+	samples := make([]prompb.Sample, 0)
+	for i := 0; i < 5; i++ {
+		samples = append(samples, prompb.Sample{Value: float64(i), Timestamp: int64(i) * 1000})
+	}
+	if w := write(h, series(samples, "__name__", "a"), series(samples[:1], "__name__", "b")); w.Code != http.StatusNoContent {
+		t.Fatalf("status %d, %s", w.Code, w.Body)
+	}
+	rows := make([]int, 0)
+	for _, sql := range st.inserts {
+		rows = append(rows, len(rowPattern.FindAllString(sql, -1)))
+	}
+	if !reflect.DeepEqual(rows, []int{2, 2, 2}) {
+		t.Errorf("rows of inserts = %v\n%q", rows, st.inserts)
+	}
+}
+
+func TestHandlerReject(t *testing.T) {
+	st := &stable{}
+	s := standin.New(t, st.handle)
+	h := NewHandler(s.Client(t, tdquery.WithDatabase("prom")), "metrics", WithMaxTags(2), WithTagLength(8), WithLimitPolicy(Reject))
+	samples := []prompb.Sample{{Value: 1, Timestamp: 1000}}
+	written := series(samples, "__name__", "up", "job", "a")
+	w := write(h, written,
+		// the third tag is over the limit
+		series(samples, "__name__", "up", "job", "b", "zone", "c"),
+		series(samples, "__name__", "up", "job", "longer than 8"))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "2 of 3 series rejected") {
+		t.Errorf("status %d, %s", w.Code, w.Body)
+	}
+	if !reflect.DeepEqual(st.tags, []string{"metric", "job"}) {
+		t.Errorf("tags = %v", st.tags)
+	}
+	if len(st.inserts) != 1 || !strings.HasPrefix(st.inserts[0], "INSERT INTO prom."+tableName(written)+" ") || len(rowPattern.FindAllString(st.inserts[0], -1)) != 1 {
+		t.Errorf("inserts = %q", st.inserts)
+	}
+}
+
+func TestNewHandlerPanics(t *testing.T) {
+	opts := map[string]Option{
+		"tag length 0":     WithTagLength(0),
+		"tag length 16384": WithTagLength(maxTagsLength),
+		"batch size 0":     WithBatchSize(0),
+	}
+	for name, opt := range opts {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewHandler() does not panic with %s", name)
+				}
+			}()
+			NewHandler(tdquery.NewClient(), "metrics", opt)
+		}()
+	}
+}
@@ -0,0 +1,72 @@
+package tdquery
+
+// Pseudo columns supported by TDengine.
+// _wstart, _wend, _wduration, _qstart, _qend and _irowts are only available in TDengine 3.x.
+const (
+	// ColumnFirst is the first column of a table, which is always the timestamp column.
+	ColumnFirst = "_c0"
+	// ColumnRowTs is the primary timestamp of a row.
+	ColumnRowTs = "_rowts"
+	// ColumnWStart is the start time of a window.
+	ColumnWStart = "_wstart"
+	// ColumnWEnd is the end time of a window.
+	ColumnWEnd = "_wend"
+	// ColumnWDuration is the duration of a window, in the precision of the database.
+	ColumnWDuration = "_wduration"
+	// ColumnQStart is the start time of the query time range.
+	ColumnQStart = "_qstart"
+	// ColumnQEnd is the end time of the query time range.
+	ColumnQEnd = "_qend"
+	// ColumnIRowTs is the timestamp of the rows generated by INTERP.
+	ColumnIRowTs = "_irowts"
+	// ColumnTbname is the child table name.
+	ColumnTbname = "TBNAME"
+)
+
+// Aliases used by the pseudo column selects, they match result struct fields with the same name,
+// e.g. a `WindowStart time.Time` field receives the value of WindowStart().
+const (
+	AliasWindowStart    = "WindowStart"
+	AliasWindowEnd      = "WindowEnd"
+	AliasWindowDuration = "WindowDuration"
+	AliasQueryStart     = "QueryStart"
+	AliasQueryEnd       = "QueryEnd"
+	AliasInterpTs       = "InterpTs"
+	AliasTbname         = "Tbname"
+)
+
+// WindowStart selects _wstart as WindowStart
+func WindowStart() Select {
+	return Select{ColumnName: ColumnWStart, Alias: AliasWindowStart}
+}
+
+// WindowEnd selects _wend as WindowEnd
+func WindowEnd() Select {
+	return Select{ColumnName: ColumnWEnd, Alias: AliasWindowEnd}
+}
+
+// WindowDuration selects _wduration as WindowDuration.
+// It can be decoded into a time.Duration field, the number is read in QueryResult.Precision.
+func WindowDuration() Select {
+	return Select{ColumnName: ColumnWDuration, Alias: AliasWindowDuration}
+}
+
+// QueryStart selects _qstart as QueryStart
+func QueryStart() Select {
+	return Select{ColumnName: ColumnQStart, Alias: AliasQueryStart}
+}
+
+// QueryEnd selects _qend as QueryEnd
+func QueryEnd() Select {
+	return Select{ColumnName: ColumnQEnd, Alias: AliasQueryEnd}
+}
+
+// InterpTs selects _irowts as InterpTs
+func InterpTs() Select {
+	return Select{ColumnName: ColumnIRowTs, Alias: AliasInterpTs}
+}
+
+// Tbname selects TBNAME as Tbname
+func Tbname() Select {
+	return Select{ColumnName: ColumnTbname, Alias: AliasTbname}
+}
@@ -2,20 +2,21 @@ package tdquery
 
 import (
 	"context"
-	"strings"
 )
 
 type QueryBuilder struct {
 	c        *Client
-	builder  strings.Builder
 	database string
 	sTable   string
 	tables   []string
 	query    string
 }
 
+// Build returns an empty sql, QueryBuilder only keeps the state shared by builders.
+//
+// Deprecated: use Build of SelectQueryBuilder.
 func (b *QueryBuilder) Build() (string, error) {
-	return b.builder.String(), nil
+	return "", nil
 }
 
 func (b *QueryBuilder) FromSTable(stable string) *QueryBuilder {
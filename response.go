@@ -6,34 +6,32 @@ import (
 
 type queryResultMeta [3]interface{}
 
-type columnType int
-
 const (
 	QueryErrCodeTableNotExist = 866
 )
 
-const (
-	_ columnType = iota
-	columnTypeBool
-	columnTypeTinyInt
-	columnTypeSmallInt
-	columnTypeInt
-	columnTypeBigInt
-	columnTypeFloat
-	columnTypeDouble
-	columnTypeBinary
-	columnTypeTimestamp
-	columnTypeNchar
-)
-
-func (m queryResultMeta) GetColumnType() columnType {
-	return columnType(m[1].(float64))
+func (m queryResultMeta) GetColumnType() ColumnType {
+	return ColumnType(m[1].(float64))
 }
 
 func (m queryResultMeta) GetColumnName() string {
 	return m[0].(string)
 }
 
+func (m queryResultMeta) GetColumnLength() int {
+	if l, ok := m[2].(float64); ok {
+		return int(l)
+	}
+	return 0
+}
+
+// ColumnMeta describes a column of query result
+type ColumnMeta struct {
+	Name   string     `json:"name"`
+	Type   ColumnType `json:"type"`
+	Length int        `json:"length"`
+}
+
 type rawQueryResult struct {
 	Status     string            `json:"status"`
 	Code       int               `json:"code"`
@@ -50,6 +48,10 @@ type QueryResult struct {
 	SQL     string                   `json:"sql,omitempty"`
 	Data    []map[string]interface{} `json:"data"`
 	Rows    int                      `json:"rows"`
+	// Columns keeps the order of result columns which is lost in Data
+	Columns []ColumnMeta `json:"columns,omitempty"`
+	// Precision is the unit of numeric timestamps in Data, they are float64 through REST and int64 through WebSocket
+	Precision Precision `json:"precision,omitempty"`
 	// 单位毫秒
 	Cost int `json:"cost"`
 }
@@ -66,12 +68,16 @@ func NewQueryResult(raw *rawQueryResult, sql string, cost time.Duration) *QueryR
 		return r
 	}
 	meta := raw.ColumnMeta
+	r.Columns = make([]ColumnMeta, 0, len(meta))
+	for _, m := range meta {
+		r.Columns = append(r.Columns, ColumnMeta{Name: m.GetColumnName(), Type: m.GetColumnType(), Length: m.GetColumnLength()})
+	}
 
 	for _, row := range raw.Data {
 		mapedValue := make(map[string]interface{})
 		for i, rowColumn := range row {
 			switch meta[i].GetColumnType() {
-			case columnTypeBool:
+			case ColumnTypeBool:
 				mapedValue[meta[i].GetColumnName()] = rowColumn.(float64) == 1
 			default:
 				mapedValue[meta[i].GetColumnName()] = rowColumn
@@ -0,0 +1,288 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Rows is a streaming iterator over a query result, rows are decoded from the response body
+// while iterating, so results larger than memory can be consumed.
+//
+//	rows, err := client.QueryRows(ctx, "SELECT * FROM power.meters")
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//		values := rows.Values()
+//	}
+//	return rows.Err()
+type Rows struct {
+	body    io.ReadCloser
+	iter    *jsoniter.Iterator
+	columns []ColumnMeta
+	values  []interface{}
+	// buffered keeps data when it comes before column_meta in response
+	buffered [][]interface{}
+	inData   bool
+	code     int
+	desc     string
+	err      error
+	// precision is the unit of numeric timestamps in response
+	precision Precision
+	// ws and ctx are set when rows are fetched through WebSocket
+	ws  *wsCursor
+	ctx context.Context
+}
+
+// QueryRows runs sql and returns a streaming iterator over its result.
+// Unlike Query, it is not limited by WithQueryTimeout, use ctx to cancel it.
+// Rows must be closed after use.
+func (c *Client) QueryRows(ctx context.Context, sql string, params ...interface{}) (*Rows, error) {
+	broker, ok := c.pickAliveBroker()
+	if !ok {
+		return nil, ErrorNoAvailableBroker
+	}
+	fullSQL, err := interpolate(sql, params, c.encoders)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.validate(fullSQL); err != nil {
+		return nil, err
+	}
+	if c.ws != nil {
+		cur, res, err := c.ws.query(ctx, broker, fullSQL)
+		if err != nil {
+			return nil, err
+		}
+		if res.Code != 0 {
+			return nil, &TDEngineError{Code: res.Code, Message: res.Message}
+		}
+		return &Rows{ws: cur, ctx: ctx, columns: cur.columns, precision: wsPrecision(cur.precision)}, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.newReqUrl(broker), strings.NewReader(fullSQL))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if u := c.h.UserInfo; u != nil {
+		req.SetBasicAuth(u.Username, u.Password)
+	}
+	// resty client timeout covers reading the whole body, so a client without timeout is used
+	hc := &http.Client{Transport: c.h.GetClient().Transport}
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	rows := &Rows{
+		body:      res.Body,
+		iter:      jsoniter.Parse(json, res.Body, 64*1024),
+		precision: c.restPrecision(),
+	}
+	if err := rows.readHeader(); err != nil {
+		res.Body.Close()
+		if _, ok := err.(*TDEngineError); !ok && res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("tdquery: unexpected http status %s: %w", res.Status, err)
+		}
+		return nil, err
+	}
+	return rows, nil
+}
+
+// readHeader reads response fields until data, it returns *TDEngineError when query failed
+func (r *Rows) readHeader() error {
+	iter := r.iter
+	for field := iter.ReadObject(); field != ""; field = iter.ReadObject() {
+		switch field {
+		case "code":
+			r.code = iter.ReadInt()
+		case "desc":
+			r.desc = iter.ReadString()
+		case "column_meta":
+			var meta []queryResultMeta
+			iter.ReadVal(&meta)
+			r.columns = make([]ColumnMeta, 0, len(meta))
+			for _, m := range meta {
+				r.columns = append(r.columns, ColumnMeta{Name: m.GetColumnName(), Type: m.GetColumnType(), Length: m.GetColumnLength()})
+			}
+		case "data":
+			if r.columns != nil {
+				r.inData = true
+				return r.iterErr()
+			}
+			iter.ReadVal(&r.buffered)
+		default:
+			iter.Skip()
+		}
+		if err := r.iterErr(); err != nil {
+			return err
+		}
+	}
+	if err := r.iterErr(); err != nil {
+		return err
+	}
+	if r.code != 0 {
+		return &TDEngineError{Code: r.code, Message: r.desc}
+	}
+	return nil
+}
+
+func (r *Rows) iterErr() error {
+	if r.iter.Error != nil && r.iter.Error != io.EOF {
+		return r.iter.Error
+	}
+	return nil
+}
+
+// Columns returns result columns in order
+func (r *Rows) Columns() []ColumnMeta {
+	return r.columns
+}
+
+// Next prepares the next row for Values, it returns false when there are no more rows or an error happened.
+func (r *Rows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	if r.ws != nil {
+		r.values, r.err = r.ws.next(r.ctx)
+		return r.values != nil
+	}
+	if r.buffered != nil {
+		if len(r.buffered) == 0 {
+			return false
+		}
+		row := r.buffered[0]
+		r.buffered = r.buffered[1:]
+		r.values = make([]interface{}, len(row))
+		for i, v := range row {
+			if i < len(r.columns) {
+				v = typedValue(r.columns[i].Type, v, r.precision.Unit())
+			}
+			r.values[i] = v
+		}
+		return true
+	}
+	if !r.inData {
+		return false
+	}
+	if !r.iter.ReadArray() {
+		r.inData = false
+		// drain the remaining fields, e.g. rows
+		for field := r.iter.ReadObject(); field != ""; field = r.iter.ReadObject() {
+			r.iter.Skip()
+		}
+		r.err = r.iterErr()
+		return false
+	}
+	r.values = make([]interface{}, 0, len(r.columns))
+	for i := 0; r.iter.ReadArray(); i++ {
+		t := ColumnType(0)
+		if i < len(r.columns) {
+			t = r.columns[i].Type
+		}
+		r.values = append(r.values, r.readValue(t))
+	}
+	if r.err = r.iterErr(); r.err != nil {
+		return false
+	}
+	return true
+}
+
+// readValue reads a value by column type
+func (r *Rows) readValue(t ColumnType) interface{} {
+	iter := r.iter
+	next := iter.WhatIsNext()
+	if next == jsoniter.NilValue {
+		iter.ReadNil()
+		return nil
+	}
+	switch t {
+	case ColumnTypeBool:
+		if next == jsoniter.BoolValue {
+			return iter.ReadBool()
+		}
+		return iter.ReadInt() != 0
+	case ColumnTypeTinyInt, ColumnTypeSmallInt, ColumnTypeInt, ColumnTypeBigInt:
+		if next == jsoniter.NumberValue {
+			return iter.ReadInt64()
+		}
+	case ColumnTypeUTinyInt, ColumnTypeUSmallInt, ColumnTypeUInt, ColumnTypeUBigInt:
+		if next == jsoniter.NumberValue {
+			return iter.ReadUint64()
+		}
+	case ColumnTypeFloat, ColumnTypeDouble:
+		if next == jsoniter.NumberValue {
+			return iter.ReadFloat64()
+		}
+	case ColumnTypeTimestamp:
+		if next == jsoniter.NumberValue {
+			return time.Unix(0, iter.ReadInt64()*int64(r.precision.Unit()))
+		}
+		if next == jsoniter.StringValue {
+			return decodeTime(iter.ReadString(), r.precision.Unit())
+		}
+	}
+	return iter.Read()
+}
+
+// typedValue converts a value decoded into interface{} to the type returned by readValue,
+// numeric timestamps are in unit
+func typedValue(t ColumnType, v interface{}, unit time.Duration) interface{} {
+	switch x := v.(type) {
+	case float64:
+		switch t {
+		case ColumnTypeBool:
+			return x != 0
+		case ColumnTypeTinyInt, ColumnTypeSmallInt, ColumnTypeInt, ColumnTypeBigInt:
+			return int64(x)
+		case ColumnTypeUTinyInt, ColumnTypeUSmallInt, ColumnTypeUInt, ColumnTypeUBigInt:
+			return uint64(x)
+		case ColumnTypeTimestamp:
+			return time.Unix(0, int64(x)*int64(unit))
+		}
+	case string:
+		if t == ColumnTypeTimestamp {
+			return decodeTime(x, unit)
+		}
+	}
+	return v
+}
+
+// Precision returns the precision timestamps of the result are read in,
+// it is set by WithPrecision for REST and returned by server for WebSocket.
+func (r *Rows) Precision() Precision {
+	return r.precision
+}
+
+// Values returns values of current row in column order.
+// Values are typed by column: bool, int64 for signed integers, uint64 for unsigned integers,
+// float64, time.Time for timestamps and string for BINARY, NCHAR and JSON, NULL is nil.
+func (r *Rows) Values() []interface{} {
+	return r.values
+}
+
+// Err returns the error happened while iterating
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close closes response body, it is safe to call Close more than once.
+func (r *Rows) Close() error {
+	if r.ws != nil {
+		r.ws.close()
+		return nil
+	}
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
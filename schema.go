@@ -0,0 +1,337 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Database is a database returned by Client.Databases
+type Database struct {
+	Name        string
+	CreatedTime time.Time
+	NTables     int
+	VGroups     int
+	Replica     int
+	// Keep is days to keep data, like `3650` in 2.x or `3650d,3650d,3650d` in 3.x
+	Keep      string
+	Precision string
+	Status    string
+}
+
+// STableInfo is a super table returned by Client.STables
+type STableInfo struct {
+	Name        string
+	Database    string
+	CreatedTime time.Time
+	Columns     int
+	Tags        int
+}
+
+// TableInfo is a table returned by Client.Tables, STable and Tags are empty for normal tables.
+type TableInfo struct {
+	Name     string
+	Database string
+	STable   string
+	Tags     map[string]interface{}
+}
+
+// ColumnInfo is a column or tag returned by Client.Describe
+type ColumnInfo struct {
+	Column
+	Tag  bool
+	Note string
+}
+
+// ServerVersion returns version of TDengine server like `2.4.0.0` or `3.0.1.0`, it is cached after the first call.
+func (c *Client) ServerVersion(ctx context.Context) (string, error) {
+	c.lock.RLock()
+	version := c.serverVersion
+	c.lock.RUnlock()
+	if version != "" {
+		return version, nil
+	}
+	rows, err := c.queryRows(ctx, "SELECT SERVER_VERSION()")
+	if err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		for _, v := range row {
+			version = fmt.Sprint(v)
+		}
+	}
+	if version == "" {
+		return "", fmt.Errorf("tdquery: empty server version")
+	}
+	c.lock.Lock()
+	c.serverVersion = version
+	c.lock.Unlock()
+	return version, nil
+}
+
+func (c *Client) isVersion3(ctx context.Context) (bool, error) {
+	version, err := c.ServerVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(version, "3."), nil
+}
+
+// queryRows runs sql and returns rows, error codes from TDengine are returned as *TDEngineError
+func (c *Client) queryRows(ctx context.Context, sql string, params ...interface{}) ([]map[string]interface{}, error) {
+	r, err := c.Query(ctx, sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	if r.Code != 0 {
+		return nil, &TDEngineError{Code: r.Code, Message: r.Message}
+	}
+	return r.Data, nil
+}
+
+// Databases lists databases, it uses `SHOW DATABASES` with 2.x and information_schema with 3.x
+func (c *Client) Databases(ctx context.Context) ([]Database, error) {
+	v3, err := c.isVersion3(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sql := "SHOW DATABASES"
+	if v3 {
+		sql = "SELECT * FROM information_schema.ins_databases"
+	}
+	rows, err := c.queryRows(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Database, 0, len(rows))
+	for _, row := range rows {
+		keep := rowString(row, "keep")
+		if keep == "" {
+			// 2.x names the column like `keep0,keep1,keep(D)`
+			for k, v := range row {
+				if strings.HasPrefix(strings.ToLower(k), "keep") {
+					keep = fmt.Sprint(v)
+				}
+			}
+		}
+		ret = append(ret, Database{
+			Name:        rowString(row, "name"),
+			CreatedTime: rowTime(row, "created_time", "create_time"),
+			NTables:     rowInt(row, "ntables"),
+			VGroups:     rowInt(row, "vgroups"),
+			Replica:     rowInt(row, "replica"),
+			Keep:        keep,
+			Precision:   rowString(row, "precision"),
+			Status:      rowString(row, "status"),
+		})
+	}
+	return ret, nil
+}
+
+// DatabasePrecision returns the precision of db, it is cached after the first call.
+// ErrUnknownDatabase is returned when db does not exist.
+func (c *Client) DatabasePrecision(ctx context.Context, db string) (Precision, error) {
+	if p, ok := c.precisions.Load(db); ok {
+		return p.(Precision), nil
+	}
+	databases, err := c.Databases(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range databases {
+		if d.Name == db {
+			p := Precision(strings.ToLower(d.Precision))
+			c.precisions.Store(db, p)
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%w %s", ErrUnknownDatabase, db)
+}
+
+// STables lists super tables of db
+func (c *Client) STables(ctx context.Context, db string) ([]STableInfo, error) {
+	v3, err := c.isVersion3(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if v3 {
+		rows, err = c.queryRows(ctx, "SELECT * FROM information_schema.ins_stables WHERE db_name = ?", db)
+	} else {
+		rows, err = c.queryRows(ctx, "SHOW "+db+".STABLES")
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]STableInfo, 0, len(rows))
+	for _, row := range rows {
+		ret = append(ret, STableInfo{
+			Name:        rowString(row, "stable_name", "name"),
+			Database:    db,
+			CreatedTime: rowTime(row, "created_time", "create_time"),
+			Columns:     rowInt(row, "columns"),
+			Tags:        rowInt(row, "tags"),
+		})
+	}
+	return ret, nil
+}
+
+// Tables lists child tables of stable with their tag values, or all tables of db when stable is empty.
+func (c *Client) Tables(ctx context.Context, db, stable string) ([]TableInfo, error) {
+	v3, err := c.isVersion3(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if stable == "" {
+		return c.allTables(ctx, db, v3)
+	}
+	if v3 {
+		return c.childTablesV3(ctx, db, stable)
+	}
+	return c.childTablesV2(ctx, db, stable)
+}
+
+func (c *Client) allTables(ctx context.Context, db string, v3 bool) ([]TableInfo, error) {
+	var rows []map[string]interface{}
+	var err error
+	if v3 {
+		rows, err = c.queryRows(ctx, "SELECT * FROM information_schema.ins_tables WHERE db_name = ?", db)
+	} else {
+		rows, err = c.queryRows(ctx, "SHOW "+db+".TABLES")
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]TableInfo, 0, len(rows))
+	for _, row := range rows {
+		ret = append(ret, TableInfo{
+			Name:     rowString(row, "table_name"),
+			Database: db,
+			STable:   rowString(row, "stable_name"),
+		})
+	}
+	return ret, nil
+}
+
+func (c *Client) childTablesV3(ctx context.Context, db, stable string) ([]TableInfo, error) {
+	rows, err := c.queryRows(ctx, "SELECT table_name, tag_name, tag_value FROM information_schema.ins_tags WHERE db_name = ? AND stable_name = ?", db, stable)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	ret := make([]TableInfo, 0)
+	for _, row := range rows {
+		name := rowString(row, "table_name")
+		i, ok := index[name]
+		if !ok {
+			i = len(ret)
+			index[name] = i
+			ret = append(ret, TableInfo{Name: name, Database: db, STable: stable, Tags: make(map[string]interface{})})
+		}
+		ret[i].Tags[rowString(row, "tag_name")] = rowValue(row, "tag_value")
+	}
+	return ret, nil
+}
+
+func (c *Client) childTablesV2(ctx context.Context, db, stable string) ([]TableInfo, error) {
+	columns, err := c.Describe(ctx, db, stable)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0)
+	for _, column := range columns {
+		if column.Tag {
+			tags = append(tags, column.Name)
+		}
+	}
+	// selecting only tags from a super table returns one row for each child table
+	rows, err := c.queryRows(ctx, "SELECT TBNAME, "+strings.Join(tags, ", ")+" FROM "+qualifiedName(db, stable))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]TableInfo, 0, len(rows))
+	for _, row := range rows {
+		t := TableInfo{Name: rowString(row, "tbname"), Database: db, STable: stable, Tags: make(map[string]interface{}, len(tags))}
+		for _, tag := range tags {
+			t.Tags[tag] = rowValue(row, tag)
+		}
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+// Describe returns columns and tags of a table or super table
+func (c *Client) Describe(ctx context.Context, db, table string) ([]ColumnInfo, error) {
+	rows, err := c.queryRows(ctx, "DESCRIBE "+qualifiedName(db, table))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]ColumnInfo, 0, len(rows))
+	for _, row := range rows {
+		t, err := ParseColumnType(rowString(row, "type"))
+		if err != nil {
+			return nil, err
+		}
+		info := ColumnInfo{
+			Column: Column{Name: rowString(row, "field"), Type: t},
+			Note:   rowString(row, "note"),
+		}
+		if t.HasLength() {
+			info.Length = rowInt(row, "length")
+		}
+		info.Tag = strings.EqualFold(info.Note, "TAG")
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
+
+// rowValue finds value by keys case insensitively, because 2.x and 3.x return different column names
+func rowValue(row map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if v, ok := row[key]; ok {
+			return v
+		}
+		for k, v := range row {
+			if strings.EqualFold(k, key) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func rowString(row map[string]interface{}, keys ...string) string {
+	v := rowValue(row, keys...)
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func rowInt(row map[string]interface{}, keys ...string) int {
+	switch v := rowValue(row, keys...).(type) {
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	default:
+		return 0
+	}
+}
+
+func rowTime(row map[string]interface{}, keys ...string) time.Time {
+	v := rowValue(row, keys...)
+	if v == nil {
+		return time.Time{}
+	}
+	if ret, ok := decodeTime(v, time.Millisecond).(time.Time); ok {
+		return ret
+	}
+	return time.Time{}
+}
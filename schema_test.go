@@ -0,0 +1,175 @@
+package tdquery_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snownd/tdquery"
+	"github.com/snownd/tdquery/internal/standin"
+)
+
+func columns(names ...string) []tdquery.ColumnMeta {
+	ret := make([]tdquery.ColumnMeta, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, tdquery.ColumnMeta{Name: name, Type: tdquery.ColumnTypeBinary})
+	}
+	return ret
+}
+
+func timestampColumn(columns []tdquery.ColumnMeta, name string) []tdquery.ColumnMeta {
+	for i := range columns {
+		if columns[i].Name == name {
+			columns[i].Type = tdquery.ColumnTypeTimestamp
+		}
+	}
+	return columns
+}
+
+// describe is `DESCRIBE power.meters`, 2.x capitalizes the column names
+func describe(field, typ, length, note string) *standin.Result {
+	return &standin.Result{
+		Columns: columns(field, typ, length, note),
+		Rows: [][]interface{}{
+			{"ts", "TIMESTAMP", 8, ""},
+			{"current", "FLOAT", 4, ""},
+			{"location", "BINARY", 64, "TAG"},
+			{"group_id", "INT", 4, "TAG"},
+		},
+	}
+}
+
+var created = time.Unix(1700000000, 0)
+
+var schema2x = map[string]*standin.Result{
+	"SELECT SERVER_VERSION()": {Columns: columns("server_version()"), Rows: [][]interface{}{{"2.4.0.7"}}},
+	"SHOW DATABASES": {
+		Columns: timestampColumn(columns("name", "created_time", "ntables", "vgroups", "replica", "keep0,keep1,keep(D)", "precision", "status"), "created_time"),
+		Rows:    [][]interface{}{{"power", created.UnixNano() / 1e6, 2, 1, 1, "3650,3650,3650", "us", "ready"}},
+	},
+	"SHOW power.STABLES": {
+		Columns: timestampColumn(columns("name", "created_time", "columns", "tags", "tables"), "created_time"),
+		Rows:    [][]interface{}{{"meters", created.UnixNano() / 1e6, 2, 2, 2}},
+	},
+	"SHOW power.TABLES": {
+		Columns: columns("table_name", "created_time", "columns", "stable_name"),
+		Rows:    [][]interface{}{{"d1", 0, 2, "meters"}, {"t0", 0, 2, ""}},
+	},
+	"DESCRIBE power.meters": describe("Field", "Type", "Length", "Note"),
+	"SELECT TBNAME, location, group_id FROM power.meters": {
+		Columns: columns("tbname", "location", "group_id"),
+		Rows:    [][]interface{}{{"d1", "sf", 1}, {"d2", "la", 2}},
+	},
+}
+
+var schema3x = map[string]*standin.Result{
+	"SELECT SERVER_VERSION()": {Columns: columns("server_version()"), Rows: [][]interface{}{{"3.0.1.0"}}},
+	"SELECT * FROM information_schema.ins_databases": {
+		Columns: timestampColumn(columns("name", "create_time", "vgroups", "ntables", "replica", "keep", "precision", "status"), "create_time"),
+		Rows:    [][]interface{}{{"power", created.UnixNano() / 1e6, 1, 2, 1, "3650d,3650d,3650d", "us", "ready"}},
+	},
+	"SELECT * FROM information_schema.ins_stables WHERE db_name = 'power'": {
+		Columns: timestampColumn(columns("stable_name", "db_name", "create_time", "columns", "tags"), "create_time"),
+		Rows:    [][]interface{}{{"meters", "power", created.UnixNano() / 1e6, 2, 2}},
+	},
+	"SELECT * FROM information_schema.ins_tables WHERE db_name = 'power'": {
+		Columns: columns("table_name", "db_name", "stable_name"),
+		Rows:    [][]interface{}{{"d1", "power", "meters"}, {"t0", "power", nil}},
+	},
+	"DESCRIBE power.meters": describe("field", "type", "length", "note"),
+	"SELECT table_name, tag_name, tag_value FROM information_schema.ins_tags WHERE db_name = 'power' AND stable_name = 'meters'": {
+		Columns: columns("table_name", "tag_name", "tag_value"),
+		Rows:    [][]interface{}{{"d1", "location", "sf"}, {"d1", "group_id", "1"}, {"d2", "location", "la"}, {"d2", "group_id", "2"}},
+	},
+}
+
+func TestSchema(t *testing.T) {
+	cases := []struct {
+		name     string
+		results  map[string]*standin.Result
+		version  string
+		keep     string
+		children []tdquery.TableInfo
+	}{
+		{"2.x", schema2x, "2.4.0.7", "3650,3650,3650", []tdquery.TableInfo{
+			{Name: "d1", Database: "power", STable: "meters", Tags: map[string]interface{}{"location": "sf", "group_id": float64(1)}},
+			{Name: "d2", Database: "power", STable: "meters", Tags: map[string]interface{}{"location": "la", "group_id": float64(2)}},
+		}},
+		{"3.x", schema3x, "3.0.1.0", "3650d,3650d,3650d", []tdquery.TableInfo{
+			{Name: "d1", Database: "power", STable: "meters", Tags: map[string]interface{}{"location": "sf", "group_id": "1"}},
+			{Name: "d2", Database: "power", STable: "meters", Tags: map[string]interface{}{"location": "la", "group_id": "2"}},
+		}},
+	}
+	ctx := context.Background()
+	for _, tc := range cases {
+		results := tc.results
+		s := standin.New(t, func(sql string) *standin.Result {
+			if r, ok := results[sql]; ok {
+				return r
+			}
+			return standin.Error(standin.TableNotExist, "Table does not exist")
+		})
+		c := s.Client(t)
+
+		version, err := c.ServerVersion(ctx)
+		if err != nil || version != tc.version {
+			t.Errorf("%s: version %s, %v", tc.name, version, err)
+		}
+		// the version is cached
+		if _, err := c.ServerVersion(ctx); err != nil || len(s.SQLs()) != 1 {
+			t.Errorf("%s: sqls %v, %v", tc.name, s.SQLs(), err)
+		}
+
+		databases, err := c.Databases(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		want := []tdquery.Database{{Name: "power", CreatedTime: created, NTables: 2, VGroups: 1, Replica: 1, Keep: tc.keep, Precision: "us", Status: "ready"}}
+		if !reflect.DeepEqual(databases, want) {
+			t.Errorf("%s: databases %+v", tc.name, databases)
+		}
+
+		stables, err := c.STables(ctx, "power")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(stables) != 1 || stables[0] != (tdquery.STableInfo{Name: "meters", Database: "power", CreatedTime: created, Columns: 2, Tags: 2}) {
+			t.Errorf("%s: stables %+v", tc.name, stables)
+		}
+
+		tables, err := c.Tables(ctx, "power", "")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(tables, []tdquery.TableInfo{{Name: "d1", Database: "power", STable: "meters"}, {Name: "t0", Database: "power"}}) {
+			t.Errorf("%s: tables %+v", tc.name, tables)
+		}
+
+		children, err := c.Tables(ctx, "power", "meters")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(children, tc.children) {
+			t.Errorf("%s: child tables %+v", tc.name, children)
+		}
+
+		columns, err := c.Describe(ctx, "power", "meters")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		wantColumns := []tdquery.ColumnInfo{
+			{Column: tdquery.NewColumn("ts", tdquery.ColumnTypeTimestamp)},
+			{Column: tdquery.NewColumn("current", tdquery.ColumnTypeFloat)},
+			{Column: tdquery.NewSizedColumn("location", tdquery.ColumnTypeBinary, 64), Tag: true, Note: "TAG"},
+			{Column: tdquery.NewColumn("group_id", tdquery.ColumnTypeInt), Tag: true, Note: "TAG"},
+		}
+		if !reflect.DeepEqual(columns, wantColumns) {
+			t.Errorf("%s: columns %+v", tc.name, columns)
+		}
+
+		if _, err := c.Describe(ctx, "power", "missing"); !tdquery.IsTableNotExist(err) {
+			t.Errorf("%s: describe missing table %v", tc.name, err)
+		}
+	}
+}
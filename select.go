@@ -2,12 +2,11 @@ package tdquery
 
 import (
 	"context"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/mitchellh/mapstructure"
 )
 
 var periodRegexp = regexp.MustCompile("^[0-9]+[BUASMHDWNY]$")
@@ -16,6 +15,27 @@ func IsValidPeriod(period string) bool {
 	return periodRegexp.MatchString(period)
 }
 
+// periodUnits are lengths of period units, N and Y are counted in months because they have no fixed duration
+var periodUnits = map[byte]int64{
+	'B': int64(time.Nanosecond),
+	'U': int64(time.Microsecond),
+	'A': int64(time.Millisecond),
+	'S': int64(time.Second),
+	'M': int64(time.Minute),
+	'H': int64(time.Hour),
+	'D': 24 * int64(time.Hour),
+	'W': 7 * 24 * int64(time.Hour),
+	'N': 1,
+	'Y': 12,
+}
+
+// periodLength returns the length of a valid period, in months when months is true
+func periodLength(period string) (length int64, months bool) {
+	unit := period[len(period)-1]
+	n, _ := strconv.ParseInt(period[:len(period)-1], 10, 64)
+	return n * periodUnits[unit], unit == 'N' || unit == 'Y'
+}
+
 type Order int
 
 const (
@@ -34,9 +54,66 @@ func (o Order) String() string {
 	}
 }
 
+type NullsOrder int
+
+const (
+	NullsDefault NullsOrder = iota
+	NullsFirst
+	NullsLast
+)
+
+func (n NullsOrder) String() string {
+	switch n {
+	case NullsDefault:
+		return ""
+	case NullsFirst:
+		return "NULLS FIRST"
+	case NullsLast:
+		return "NULLS LAST"
+	default:
+		panic("tdquery: unknown nulls order")
+	}
+}
+
 type orderBy struct {
-	columns []string
-	order   Order
+	expr  string
+	order Order
+	nulls NullsOrder
+	// listed expressions of OrderBy have no direction except the last one
+	listed bool
+	// time orders by the time column of the builder, resolved when building
+	time bool
+}
+
+func appendOrderBy(builder *strings.Builder, orders []orderBy) {
+	if len(orders) == 0 {
+		return
+	}
+	builder.WriteString(" ORDER BY ")
+	for i, o := range orders {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(o.expr)
+		if o.listed {
+			continue
+		}
+		builder.WriteRune(' ')
+		builder.WriteString(o.order.String())
+		if o.nulls != NullsDefault {
+			builder.WriteRune(' ')
+			builder.WriteString(o.nulls.String())
+		}
+	}
+}
+
+// listedOrderBy is `ORDER BY col1, col2 DESC`, order is written after the last column like the SQL
+func listedOrderBy(columns []string, order Order) []orderBy {
+	orders := make([]orderBy, 0, len(columns))
+	for i, column := range columns {
+		orders = append(orders, orderBy{expr: column, order: order, listed: i < len(columns)-1})
+	}
+	return orders
 }
 
 type Interval struct {
@@ -137,12 +214,21 @@ type SelectQueryBuilder struct {
 	soffset  int
 	limit    int
 	offset   int
-	orderBy  *orderBy
+	orderBy  []orderBy
 	where    []*Condition
-	groupby  []string
-	fill     *Fill
-	subQuery *SelectQueryBuilder
-	params   []interface{}
+	// wherePredicates are combined with where by AND
+	wherePredicates []Predicate
+	groupby         []string
+	// partitionBy only works with TDengine 3.x
+	partitionBy []string
+	having      Predicate
+	fill        *Fill
+	subQuery    *SelectQueryBuilder
+	// timeColumn is used by WithTimeScope, Asc and Desc, default is _c0
+	timeColumn string
+	alias      string
+	joins      []join
+	sliding    string
 }
 
 func (b *SelectQueryBuilder) Select(selects ...Select) *SelectQueryBuilder {
@@ -166,6 +252,54 @@ func (b *SelectQueryBuilder) SelectAll() *SelectQueryBuilder {
 	return b.SelectColumn("*")
 }
 
+// Clone returns an independent copy of the builder, so a base query can be reused with different conditions.
+// Conditions and predicates are shared and should not be modified after being added.
+func (b *SelectQueryBuilder) Clone() *SelectQueryBuilder {
+	c := &SelectQueryBuilder{
+		QueryBuilder: QueryBuilder{
+			c:        b.c,
+			database: b.database,
+			sTable:   b.sTable,
+			tables:   append([]string(nil), b.tables...),
+			query:    b.query,
+		},
+		selects:         append([]Select(nil), b.selects...),
+		slimit:          b.slimit,
+		soffset:         b.soffset,
+		limit:           b.limit,
+		offset:          b.offset,
+		orderBy:         append([]orderBy(nil), b.orderBy...),
+		where:           append([]*Condition(nil), b.where...),
+		wherePredicates: append([]Predicate(nil), b.wherePredicates...),
+		groupby:         append([]string(nil), b.groupby...),
+		fill:            b.fill,
+		timeColumn:      b.timeColumn,
+		partitionBy:     append([]string(nil), b.partitionBy...),
+		having:          b.having,
+		alias:           b.alias,
+		joins:           append([]join(nil), b.joins...),
+		sliding:         b.sliding,
+	}
+	if b.interval != nil {
+		// Interval.WithOffset changes the interval in place
+		interval := *b.interval
+		c.interval = &interval
+	}
+	if b.subQuery != nil {
+		c.subQuery = b.subQuery.Clone()
+	}
+	return c
+}
+
+func (b *SelectQueryBuilder) hasWildcard() bool {
+	for _, s := range b.selects {
+		if strings.HasSuffix(s.ColumnName, "*") {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *SelectQueryBuilder) FromSTable(stable string) *SelectQueryBuilder {
 	b.QueryBuilder.FromSTable(stable)
 	return b
@@ -176,11 +310,20 @@ func (b *SelectQueryBuilder) FromTables(tables ...string) *SelectQueryBuilder {
 	return b
 }
 
+// FromSubQuery selects from a derived table, use As to give it an alias.
+// Params of the sub query are merged in order, sub queries can be nested.
 func (b *SelectQueryBuilder) FromSubQuery(subQuery *SelectQueryBuilder) *SelectQueryBuilder {
 	b.subQuery = subQuery
 	return b
 }
 
+// As sets an alias for the table, super table or sub query in FROM clause, it follows the first table of FromTables,
+// so columns can be referenced as `alias.column`.
+func (b *SelectQueryBuilder) As(alias string) *SelectQueryBuilder {
+	b.alias = alias
+	return b
+}
+
 func (b *SelectQueryBuilder) UseDatabase(db string) *SelectQueryBuilder {
 	b.QueryBuilder.UseDatabase(db)
 	return b
@@ -195,28 +338,76 @@ func (b *SelectQueryBuilder) AndWhere(conditions ...*Condition) *SelectQueryBuil
 	return b.Where(conditions...)
 }
 
-// WithTimeScope generate sql with BETWEEN: _co between start and end
+// WherePredicate adds predicates combined by And or Or to WHERE, they follow conditions of Where,
+// e.g. WherePredicate(Or(NotEquals("location", "sf"), IsNull("location")))
+func (b *SelectQueryBuilder) WherePredicate(predicates ...Predicate) *SelectQueryBuilder {
+	b.wherePredicates = append(b.wherePredicates, predicates...)
+	return b
+}
+
+// TimeColumn sets the timestamp column used by WithTimeScope, Asc and Desc, e.g. `ts` or `_rowts`.
+// The column is resolved when building, so it can be called before or after them.
+func (b *SelectQueryBuilder) TimeColumn(column string) *SelectQueryBuilder {
+	b.timeColumn = column
+	return b
+}
+
+func (b *SelectQueryBuilder) timeColumnName() string {
+	if b.timeColumn == "" {
+		return ColumnFirst
+	}
+	return b.timeColumn
+}
+
+// WithTimeScope generate sql with BETWEEN: _c0 between start and end.
+// Use TimeColumn to change the timestamp column.
 func (b *SelectQueryBuilder) WithTimeScope(start, end time.Time) *SelectQueryBuilder {
-	return b.Where(NewCondition("_c0", "BETWEEN", []interface{}{start, end}))
+	c := NewCondition(b.timeColumnName(), "BETWEEN", []interface{}{start, end})
+	c.timeColumn = true
+	return b.Where(c)
 }
 
-// OrderBy only support ASC or DESC with time column
+// OrderBy replaces previous orderings with `ORDER BY col1, col2 DESC`, order is written after the last column,
+// so other columns are ascending. Use AddOrderBy for the order of each column.
+// TDengine 2.x only supports ordering by the time column.
 func (b *SelectQueryBuilder) OrderBy(columns []string, order Order) *SelectQueryBuilder {
-	b.orderBy = &orderBy{
-		columns: columns,
-		order:   order,
-	}
+	b.orderBy = listedOrderBy(columns, order)
+	return b
+}
+
+// AddOrderBy appends an ordering expression: ORDER BY col1 ASC, expr DESC
+func (b *SelectQueryBuilder) AddOrderBy(expr string, order Order) *SelectQueryBuilder {
+	return b.AddOrderByNulls(expr, order, NullsDefault)
+}
+
+// AddOrderByNulls appends an ordering expression with NULLS FIRST or NULLS LAST, TDengine 3.x only.
+func (b *SelectQueryBuilder) AddOrderByNulls(expr string, order Order, nulls NullsOrder) *SelectQueryBuilder {
+	b.orderBy = append(b.orderBy, orderBy{expr: expr, order: order, nulls: nulls})
 	return b
 }
 
 // order by time column with DESC order
 func (b *SelectQueryBuilder) Desc() *SelectQueryBuilder {
-	return b.OrderBy([]string{"_c0"}, DESC)
+	b.orderBy = []orderBy{{order: DESC, time: true}}
+	return b
 }
 
 // order by time column with ASC order
 func (b *SelectQueryBuilder) Asc() *SelectQueryBuilder {
-	return b.OrderBy([]string{"_c0"}, ASC)
+	b.orderBy = []orderBy{{order: ASC, time: true}}
+	return b
+}
+
+// resolvedOrderBy replaces orderings of Asc and Desc with the time column.
+func (b *SelectQueryBuilder) resolvedOrderBy() []orderBy {
+	orders := make([]orderBy, len(b.orderBy))
+	for i, o := range b.orderBy {
+		if o.time {
+			o.expr = b.timeColumnName()
+		}
+		orders[i] = o
+	}
+	return orders
 }
 
 func (b *SelectQueryBuilder) Limit(limit int) *SelectQueryBuilder {
@@ -234,11 +425,31 @@ func (b *SelectQueryBuilder) Interval(interval *Interval) *SelectQueryBuilder {
 	return b
 }
 
+// Sliding sets the step of INTERVAL windows like `30s`, it must not be greater than the interval.
+// Build checks it unless only one of them is in months or years, which have no fixed duration.
+func (b *SelectQueryBuilder) Sliding(period string) *SelectQueryBuilder {
+	b.sliding = strings.ToUpper(period)
+	return b
+}
+
 func (b *SelectQueryBuilder) GroupBy(columns ...string) *SelectQueryBuilder {
 	b.groupby = append(b.groupby, columns...)
 	return b
 }
 
+// PartitionBy partitions data by tbname, tags or columns, TDengine 3.x only.
+func (b *SelectQueryBuilder) PartitionBy(columns ...string) *SelectQueryBuilder {
+	b.partitionBy = append(b.partitionBy, columns...)
+	return b
+}
+
+// Having filters results of GROUP BY or window aggregations, e.g. Having(Greater("MAX(value)", 10)).
+// It replaces previous Having predicate, use And or Or to combine predicates.
+func (b *SelectQueryBuilder) Having(predicate Predicate) *SelectQueryBuilder {
+	b.having = predicate
+	return b
+}
+
 func (b *SelectQueryBuilder) SLimit(limit int) *SelectQueryBuilder {
 	b.slimit = limit
 	return b
@@ -254,128 +465,206 @@ func (b *SelectQueryBuilder) Fill(f *Fill) *SelectQueryBuilder {
 	return b
 }
 
+// Build generates sql with `?` placeholders from the current state of the builder.
+// It can be called many times, e.g. after changing the time scope of a cloned builder.
 func (b *SelectQueryBuilder) Build() (string, error) {
-	if b.builder.Len() == 0 {
-		if err := b.buildSQL(); err != nil {
-			return "", err
-		}
+	sql, _, err := b.BuildWithParams()
+	return sql, err
+}
+
+// BuildWithParams generates sql with `?` placeholders and the params for them.
+func (b *SelectQueryBuilder) BuildWithParams() (string, []interface{}, error) {
+	builder := &strings.Builder{}
+	params := make([]interface{}, 0)
+	if err := b.buildSQL(builder, &params); err != nil {
+		return "", nil, err
 	}
-	return b.QueryBuilder.Build()
+	return builder.String(), params, nil
 }
 
-func (b *SelectQueryBuilder) buildSQL() error {
+func (b *SelectQueryBuilder) buildSQL(builder *strings.Builder, params *[]interface{}) error {
 	if len(b.selects) == 0 {
 		return ErrEmptySelect
 	}
-	b.builder.WriteString("SELECT ")
+	if err := b.validateJoins(); err != nil {
+		return err
+	}
+	builder.WriteString("SELECT ")
 	for i, s := range b.selects {
 		if i > 0 {
-			b.builder.WriteString(", ")
+			builder.WriteString(", ")
 		}
-		b.builder.WriteString(s.ColumnName)
+		builder.WriteString(s.ColumnName)
 		if s.Alias != "" {
-			b.builder.WriteString(" AS \"")
-			b.builder.WriteString(s.Alias)
-			b.builder.WriteRune('"')
+			builder.WriteString(" AS \"")
+			builder.WriteString(s.Alias)
+			builder.WriteRune('"')
 		}
 	}
-	b.builder.WriteString(" FROM ")
-	if b.QueryBuilder.sTable != "" {
+	builder.WriteString(" FROM ")
+	writeTable := func(name, alias string) {
 		if b.QueryBuilder.database != "" {
-			b.builder.WriteString(b.database)
-			b.builder.WriteRune('.')
+			builder.WriteString(b.database)
+			builder.WriteRune('.')
+		}
+		builder.WriteString(name)
+		if alias != "" {
+			builder.WriteRune(' ')
+			builder.WriteString(alias)
 		}
-		b.builder.WriteString(b.QueryBuilder.sTable)
+	}
+	if b.QueryBuilder.sTable != "" {
+		writeTable(b.QueryBuilder.sTable, b.alias)
 	} else if len(b.QueryBuilder.tables) > 0 {
 		for i, table := range b.QueryBuilder.tables {
 			if i > 0 {
-				b.builder.WriteString(", ")
-			}
-			if b.QueryBuilder.database != "" {
-				b.builder.WriteString(b.database)
-				b.builder.WriteRune('.')
+				builder.WriteString(", ")
+				writeTable(table, "")
+			} else {
+				writeTable(table, b.alias)
 			}
-			b.builder.WriteString(table)
 		}
 	} else if b.subQuery != nil {
-		subSql, err := b.subQuery.Build()
+		subSql, subParams, err := b.subQuery.BuildWithParams()
 		if err != nil {
 			return err
 		}
-		b.builder.WriteRune('(')
-		b.builder.WriteString(subSql)
-		b.builder.WriteRune(')')
+		// params of sub query come before params of WHERE clause
+		*params = append(*params, subParams...)
+		builder.WriteRune('(')
+		builder.WriteString(subSql)
+		builder.WriteRune(')')
+		if b.alias != "" {
+			builder.WriteRune(' ')
+			builder.WriteString(b.alias)
+		}
 	} else {
 		return ErrEmptyFrom
 	}
-	builder := &b.builder
+	for _, j := range b.joins {
+		builder.WriteString(", ")
+		writeTable(j.table.Name, j.table.Alias)
+	}
+	// join conditions are written as WHERE conditions, which works with both TDengine 2.x and 3.x
+	for i, j := range b.joins {
+		if i > 0 {
+			builder.WriteString(" AND ")
+		} else {
+			builder.WriteString(" WHERE ")
+		}
+		if err := j.on.appendPredicate(builder, params); err != nil {
+			return err
+		}
+	}
 	for i, c := range b.where {
-		if err := c.appendToBuilder(i, builder, &b.params); err != nil {
+		if c.timeColumn {
+			scoped := *c
+			scoped.ColumnName = b.timeColumnName()
+			c = &scoped
+		}
+		if err := c.appendToBuilder(i+len(b.joins), builder, params); err != nil {
 			return err
 		}
 	}
+	for i, p := range b.wherePredicates {
+		if i+len(b.joins)+len(b.where) > 0 {
+			builder.WriteString(" AND ")
+		} else {
+			builder.WriteString(" WHERE ")
+		}
+		if err := p.appendPredicate(builder, params); err != nil {
+			return err
+		}
+	}
+	if len(b.partitionBy) > 0 {
+		builder.WriteString(" PARTITION BY ")
+		for i, p := range b.partitionBy {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			builder.WriteString(p)
+		}
+	}
+
 	if b.interval != nil {
-		b.builder.WriteString(" INTERVAL(")
-		b.builder.WriteString(b.interval.String())
-		b.builder.WriteRune(')')
+		builder.WriteString(" INTERVAL(")
+		builder.WriteString(b.interval.String())
+		builder.WriteRune(')')
+	}
+	if b.sliding != "" {
+		if b.interval == nil {
+			return fmt.Errorf("%w, SLIDING needs INTERVAL", ErrInvalidWindow)
+		}
+		if !IsValidPeriod(b.sliding) {
+			return fmt.Errorf("%w, invalid sliding period %s", ErrInvalidWindow, b.sliding)
+		}
+		if IsValidPeriod(b.interval.period) {
+			sliding, slidingMonths := periodLength(b.sliding)
+			interval, intervalMonths := periodLength(b.interval.period)
+			if slidingMonths == intervalMonths && sliding > interval {
+				return fmt.Errorf("%w, sliding %s is greater than interval %s", ErrInvalidWindow, b.sliding, b.interval.period)
+			}
+		}
+		builder.WriteString(" SLIDING(")
+		builder.WriteString(b.sliding)
+		builder.WriteRune(')')
 	}
 
 	if b.fill != nil {
-		b.builder.WriteString(" FILL(")
-		b.builder.WriteString(b.fill.String())
-		b.builder.WriteRune(')')
+		builder.WriteString(" FILL(")
+		builder.WriteString(b.fill.String())
+		builder.WriteRune(')')
 	}
 
 	if len(b.groupby) > 0 {
-		b.builder.WriteString(" GROUP BY ")
+		builder.WriteString(" GROUP BY ")
 		for i, g := range b.groupby {
 			if i > 0 {
-				b.builder.WriteString(", ")
+				builder.WriteString(", ")
 			}
-			b.builder.WriteString(g)
+			builder.WriteString(g)
 		}
 	}
 
-	if b.orderBy != nil {
-		b.builder.WriteString(" ORDER BY ")
-		for i, o := range b.orderBy.columns {
-			if i > 0 {
-				b.builder.WriteString(", ")
-			}
-			b.builder.WriteString(o)
+	if b.having != nil {
+		builder.WriteString(" HAVING ")
+		if err := b.having.appendPredicate(builder, params); err != nil {
+			return err
 		}
-		b.builder.WriteString(" ")
-		b.builder.WriteString(b.orderBy.order.String())
 	}
 
+	appendOrderBy(builder, b.resolvedOrderBy())
+
 	if b.slimit > 0 {
-		b.builder.WriteString(" SLIMIT ")
-		b.builder.WriteString(strconv.Itoa(b.slimit))
+		builder.WriteString(" SLIMIT ")
+		builder.WriteString(strconv.Itoa(b.slimit))
 		if b.soffset > 0 {
-			b.builder.WriteString(" SOFFSET ")
-			b.builder.WriteString(strconv.Itoa(b.soffset))
+			builder.WriteString(" SOFFSET ")
+			builder.WriteString(strconv.Itoa(b.soffset))
 		}
 	}
 
 	if b.limit > 0 {
-		b.builder.WriteString(" LIMIT ")
-		b.builder.WriteString(strconv.Itoa(b.limit))
+		builder.WriteString(" LIMIT ")
+		builder.WriteString(strconv.Itoa(b.limit))
 		if b.offset > 0 {
-			b.builder.WriteString(" OFFSET ")
-			b.builder.WriteString(strconv.Itoa(b.offset))
+			builder.WriteString(" OFFSET ")
+			builder.WriteString(strconv.Itoa(b.offset))
 		}
 	}
 	return nil
 }
 
 func (s *SelectQueryBuilder) GetRaw(ctx context.Context) (*QueryResult, error) {
-	sql, err := s.Build()
+	sql, params, err := s.BuildWithParams()
 	if err != nil {
 		return nil, err
 	}
-	return s.QueryBuilder.GetRaw(ctx, sql, s.params...)
+	return s.QueryBuilder.GetRaw(ctx, sql, params...)
 }
 
+// GetResult decodes rows into v, numeric timestamps and durations like WindowDuration are read
+// in the precision of the result, REST results are in the precision set by WithPrecision.
 func (s *SelectQueryBuilder) GetResult(ctx context.Context, v interface{}) error {
 	raw, err := s.GetRaw(ctx)
 	if err != nil {
@@ -384,5 +673,5 @@ func (s *SelectQueryBuilder) GetResult(ctx context.Context, v interface{}) error
 	if raw.Code != 0 {
 		return &TDEngineError{Code: raw.Code, Message: raw.Message}
 	}
-	return mapstructure.Decode(raw.Data, v)
+	return decodeResult(raw.Data, raw.Precision, v)
 }
@@ -0,0 +1,250 @@
+package tdquery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFromSubQueryInterpolation(t *testing.T) {
+	c := NewClient()
+	start, end := time.Unix(1700000000, 0), time.Unix(1700003600, 0)
+	cases := []struct {
+		name  string
+		build func() *SelectQueryBuilder
+		sql   string
+		final string
+	}{
+		{
+			name: "sub query",
+			build: func() *SelectQueryBuilder {
+				sub := c.NewSelectQueryBuilder().SelectAll().FromSTable("meters").
+					Where(NewCondition("location", "=", "it's ?"))
+				return c.NewSelectQueryBuilder().SelectColumn("avg(current)").FromSubQuery(sub).
+					Where(NewCondition("voltage", ">", 200))
+			},
+			sql:   "SELECT avg(current) FROM (SELECT * FROM meters WHERE location = ?) WHERE voltage > ?",
+			final: "SELECT avg(current) FROM (SELECT * FROM meters WHERE location = 'it''s ?') WHERE voltage > 200",
+		},
+		{
+			name: "nested sub queries",
+			build: func() *SelectQueryBuilder {
+				inner := c.NewSelectQueryBuilder().SelectColumn("ts").SelectColumn("current").FromSTable("meters").
+					WithTimeScope(start, end).Where(NewCondition("location", "IN", []string{"a?", "b"}))
+				middle := c.NewSelectQueryBuilder().SelectColumnWithAlias("max(current)", "m").FromSubQuery(inner).
+					Interval(NewInterval("1m")).Having(NewCondition("max(current)", ">", 1.5))
+				return c.NewSelectQueryBuilder().SelectColumn("count(*)").FromSubQuery(middle).As("t").
+					Where(NewCondition("t.m", "<", 9))
+			},
+			sql: "SELECT count(*) FROM (SELECT max(current) AS \"m\" FROM (SELECT ts, current FROM meters WHERE _c0 BETWEEN ? AND ? AND location IN ?)" +
+				" INTERVAL(1M) HAVING max(current) > ?) t WHERE t.m < ?",
+			final: "SELECT count(*) FROM (SELECT max(current) AS \"m\" FROM (SELECT ts, current FROM meters WHERE _c0 BETWEEN 1700000000000 AND 1700003600000 AND location IN ('a?','b'))" +
+				" INTERVAL(1M) HAVING max(current) > 1.5) t WHERE t.m < 9",
+		},
+		{
+			name: "literal with ? in a column",
+			build: func() *SelectQueryBuilder {
+				sub := c.NewSelectQueryBuilder().SelectColumnWithAlias("concat(location, '?')", "l").FromTables("d1").
+					Where(NewCondition("current", ">", 1))
+				return c.NewSelectQueryBuilder().SelectColumn("l").FromSubQuery(sub).
+					Where(NewCondition("l", "LIKE", "%?"))
+			},
+			sql:   "SELECT l FROM (SELECT concat(location, '?') AS \"l\" FROM d1 WHERE current > ?) WHERE l LIKE ?",
+			final: "SELECT l FROM (SELECT concat(location, '?') AS \"l\" FROM d1 WHERE current > 1) WHERE l LIKE '%?'",
+		},
+	}
+	for _, tc := range cases {
+		sql, params, err := tc.build().BuildWithParams()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+		final, err := interpolate(sql, params, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if final != tc.final {
+			t.Errorf("%s: interpolated sql = %s\nwant %s", tc.name, final, tc.final)
+		}
+	}
+}
+
+func TestFromSubQuerySent(t *testing.T) {
+	s := newWSStandIn(t, map[string]*standInResult{"show dnodes": dnodes3x})
+	c := s.client(t, 1)
+	sub := c.NewSelectQueryBuilder().SelectAll().FromSTable("meters").Where(NewCondition("location", "=", "?"))
+	q := c.NewSelectQueryBuilder().SelectColumn("last(*)").FromSubQuery(sub).Where(NewCondition("current", ">", 2))
+	if _, err := q.GetRaw(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT last(*) FROM (SELECT * FROM meters WHERE location = '?') WHERE current > 2"
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if got := s.sqls[len(s.sqls)-1]; got != want {
+		t.Errorf("sent sql = %s\nwant %s", got, want)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	c := NewClient()
+	cases := []struct {
+		name  string
+		build func() interface{ Build() (string, error) }
+		sql   string
+	}{
+		{
+			name: "columns",
+			build: func() interface{ Build() (string, error) } {
+				return c.NewSelectQueryBuilder().SelectAll().FromTables("t").OrderBy([]string{"x", "y"}, DESC)
+			},
+			sql: "SELECT * FROM t ORDER BY x, y DESC",
+		},
+		{
+			name: "order of each column",
+			build: func() interface{ Build() (string, error) } {
+				return c.NewSelectQueryBuilder().SelectAll().FromTables("t").
+					AddOrderBy("x", DESC).AddOrderByNulls("y", ASC, NullsLast)
+			},
+			sql: "SELECT * FROM t ORDER BY x DESC, y ASC NULLS LAST",
+		},
+		{
+			name: "OrderBy replaces orderings",
+			build: func() interface{ Build() (string, error) } {
+				return c.NewSelectQueryBuilder().SelectAll().FromTables("t").OrderBy([]string{"x"}, DESC).OrderBy([]string{"y", "z"}, ASC)
+			},
+			sql: "SELECT * FROM t ORDER BY y, z ASC",
+		},
+		{
+			name: "Desc replaces orderings",
+			build: func() interface{ Build() (string, error) } {
+				return c.NewSelectQueryBuilder().SelectAll().FromTables("t").AddOrderBy("x", DESC).Desc()
+			},
+			sql: "SELECT * FROM t ORDER BY _c0 DESC",
+		},
+		{
+			name: "union",
+			build: func() interface{ Build() (string, error) } {
+				return c.Union(c.NewSelectQueryBuilder().SelectColumn("x").FromTables("a"), c.NewSelectQueryBuilder().SelectColumn("x").FromTables("b")).
+					OrderBy([]string{"x", "y"}, ASC)
+			},
+			sql: "SELECT x FROM a UNION ALL SELECT x FROM b ORDER BY x, y ASC",
+		},
+	}
+	for _, tc := range cases {
+		sql, err := tc.build().Build()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+	}
+}
+
+func TestTimeColumn(t *testing.T) {
+	c := NewClient()
+	start, end := time.Unix(1700000000, 0), time.Unix(1700003600, 0)
+	cases := map[string]*SelectQueryBuilder{
+		"before": c.NewSelectQueryBuilder().SelectAll().FromTables("d1").TimeColumn("ts").
+			WithTimeScope(start, end).Where(NewCondition("v", ">", 1)).Desc(),
+		"after": c.NewSelectQueryBuilder().SelectAll().FromTables("d1").
+			WithTimeScope(start, end).Where(NewCondition("v", ">", 1)).Desc().TimeColumn("ts"),
+	}
+	want := "SELECT * FROM d1 WHERE ts BETWEEN ? AND ? AND v > ? ORDER BY ts DESC"
+	for name, b := range cases {
+		sql, err := b.Build()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if sql != want {
+			t.Errorf("%s: sql = %s\nwant %s", name, sql, want)
+		}
+	}
+
+	base := c.NewSelectQueryBuilder().SelectAll().FromTables("d1").WithTimeScope(start, end).Asc()
+	clone := base.Clone().TimeColumn("_rowts")
+	if sql, _ := base.Build(); sql != "SELECT * FROM d1 WHERE _c0 BETWEEN ? AND ? ORDER BY _c0 ASC" {
+		t.Errorf("base sql = %s", sql)
+	}
+	if sql, _ := clone.Build(); sql != "SELECT * FROM d1 WHERE _rowts BETWEEN ? AND ? ORDER BY _rowts ASC" {
+		t.Errorf("clone sql = %s", sql)
+	}
+}
+
+func TestClone(t *testing.T) {
+	c := NewClient()
+	interval := NewInterval("1m")
+	base := c.NewSelectQueryBuilder().SelectColumn("avg(v)").FromTables("d1").Where(NewCondition("v", ">", 1)).Interval(interval)
+	clone := base.Clone().Where(NewCondition("v", "<", 9)).PartitionBy("tbname")
+	clone.interval.WithOffset(10)
+	interval.WithOffset(20)
+	if sql, _ := base.Build(); sql != "SELECT avg(v) FROM d1 WHERE v > ? INTERVAL(1M, 20)" {
+		t.Errorf("base sql = %s", sql)
+	}
+	if sql, _ := clone.Build(); sql != "SELECT avg(v) FROM d1 WHERE v > ? AND v < ? PARTITION BY tbname INTERVAL(1M, 10)" {
+		t.Errorf("clone sql = %s", sql)
+	}
+}
+
+func TestSliding(t *testing.T) {
+	c := NewClient()
+	query := func(interval string) *SelectQueryBuilder {
+		return c.NewSelectQueryBuilder().SelectColumn("avg(v)").FromTables("d1").Interval(NewInterval(interval))
+	}
+	cases := []struct {
+		name string
+		b    *SelectQueryBuilder
+		sql  string
+		err  error
+	}{
+		{"smaller", query("1m").Sliding("30s"), "SELECT avg(v) FROM d1 INTERVAL(1M) SLIDING(30S)", nil},
+		{"equal in another unit", query("1h").Sliding("60m"), "SELECT avg(v) FROM d1 INTERVAL(1H) SLIDING(60M)", nil},
+		{"milliseconds", query("1s").Sliding("500a"), "SELECT avg(v) FROM d1 INTERVAL(1S) SLIDING(500A)", nil},
+		{"months", query("1y").Sliding("3n"), "SELECT avg(v) FROM d1 INTERVAL(1Y) SLIDING(3N)", nil},
+		// months have no fixed duration, TDengine checks them
+		{"days of months", query("1n").Sliding("1d"), "SELECT avg(v) FROM d1 INTERVAL(1N) SLIDING(1D)", nil},
+		{"greater", query("30s").Sliding("1m"), "", ErrInvalidWindow},
+		{"greater in months", query("1n").Sliding("1y"), "", ErrInvalidWindow},
+		{"greater in nanoseconds", query("1u").Sliding("1001b"), "", ErrInvalidWindow},
+		{"invalid", query("1m").Sliding("30x"), "", ErrInvalidWindow},
+		{"without interval", c.NewSelectQueryBuilder().SelectColumn("v").FromTables("d1").Sliding("30s"), "", ErrInvalidWindow},
+	}
+	for _, tc := range cases {
+		sql, err := tc.b.Build()
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: error %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+	}
+}
+
+func TestWherePredicate(t *testing.T) {
+	c := NewClient()
+	b := c.NewSelectQueryBuilder().SelectAll().FromTables("d1").
+		WherePredicate(Or(NotEquals("location", "sf"), IsNull("location"))).Where(Greater("v", 1))
+	clone := b.Clone().WherePredicate(And(Less("v", 9), IsNotNull("v")))
+	sql, params, err := b.BuildWithParams()
+	if err != nil || sql != "SELECT * FROM d1 WHERE v > ? AND (location != ? OR location IS NULL)" || !reflect.DeepEqual(params, []interface{}{1, "sf"}) {
+		t.Errorf("sql = %s %v, %v", sql, params, err)
+	}
+	if sql, _ := clone.Build(); sql != "SELECT * FROM d1 WHERE v > ? AND (location != ? OR location IS NULL) AND (v < ? AND v IS NOT NULL)" {
+		t.Errorf("clone sql = %s", sql)
+	}
+	if sql, _ := c.NewSelectQueryBuilder().SelectAll().FromTables("d1").WherePredicate(IsNull("v")).Build(); sql != "SELECT * FROM d1 WHERE v IS NULL" {
+		t.Errorf("only predicate sql = %s", sql)
+	}
+	if _, err := c.NewSelectQueryBuilder().SelectAll().FromTables("d1").WherePredicate(Or()).Build(); !errors.Is(err, ErrInvalidCondition) {
+		t.Errorf("empty predicate: %v", err)
+	}
+}
@@ -0,0 +1,1021 @@
+// Package sqlparse tokenizes and parses TDengine sql enough to classify statements, find referenced tables
+// and placeholders, and reject obviously malformed sql before it is sent.
+//
+//	stmt, err := sqlparse.Parse("SELECT avg(v) FROM db.st WHERE host = ? INTERVAL(1m)")
+//	// stmt.Kind is KindSelect, stmt.Tables is [db.st], stmt.Params is [""]
+//
+// Pass Validate to tdquery.WithValidator to check every query of a client.
+package sqlparse
+
+import (
+	"strings"
+)
+
+// Kind is the kind of a statement
+type Kind int
+
+const (
+	KindSelect Kind = iota + 1
+	KindInsert
+	KindCreate
+	KindAlter
+	KindDrop
+	KindDelete
+	KindShow
+	KindDescribe
+	KindUse
+	KindKill
+	KindExplain
+)
+
+var kindNames = map[Kind]string{
+	KindSelect:   "SELECT",
+	KindInsert:   "INSERT",
+	KindCreate:   "CREATE",
+	KindAlter:    "ALTER",
+	KindDrop:     "DROP",
+	KindDelete:   "DELETE",
+	KindShow:     "SHOW",
+	KindDescribe: "DESCRIBE",
+	KindUse:      "USE",
+	KindKill:     "KILL",
+	KindExplain:  "EXPLAIN",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// TableRef is a table or super table referenced by a statement, Database is empty when the name is not qualified
+type TableRef struct {
+	Database string
+	Name     string
+}
+
+func (t TableRef) String() string {
+	if t.Database == "" {
+		return t.Name
+	}
+	return t.Database + "." + t.Name
+}
+
+// Statement is a parsed statement
+type Statement struct {
+	Kind Kind
+	// Object is what DDL works on like TABLE, STABLE, DATABASE, TOPIC or STREAM, or what SHOW lists like TABLES
+	Object string
+	// Tables are referenced tables in order of appearance, a table referenced twice appears once
+	Tables []TableRef
+	// Databases are databases of qualified tables and databases named by statements like USE and CREATE DATABASE
+	Databases []string
+	// Params are names of placeholders outside literals and comments, names are empty for `?`
+	Params []string
+	Tokens []Token
+
+	ifExists    bool
+	ifNotExists bool
+	usesNow     bool
+}
+
+// ReadOnly reports whether the statement does not change data or schema
+func (s *Statement) ReadOnly() bool {
+	switch s.Kind {
+	case KindSelect, KindShow, KindDescribe, KindUse, KindExplain:
+		return true
+	}
+	return false
+}
+
+// Retryable reports whether running the statement again has the same effect, so it is safe to retry after a network error.
+// Inserts are retryable because TDengine keeps one row per timestamp, and deletes of a time range are retryable too,
+// unless they use NOW or TODAY which differ on each run.
+// CREATE and DROP are retryable when every object has IF NOT EXISTS or IF EXISTS.
+func (s *Statement) Retryable() bool {
+	switch s.Kind {
+	case KindInsert, KindDelete:
+		return !s.usesNow
+	case KindCreate:
+		return s.ifNotExists
+	case KindDrop:
+		return s.ifExists
+	}
+	return s.ReadOnly()
+}
+
+// Validate parses sql and rejects placeholders, it checks sql about to be sent and works with tdquery.WithValidator.
+func Validate(sql string) error {
+	s, err := Parse(sql)
+	if err != nil {
+		return err
+	}
+	if len(s.Params) > 0 {
+		t := s.placeholder()
+		return syntaxError(t.Pos, "unbound placeholder %s", t.Text)
+	}
+	return nil
+}
+
+func (s *Statement) placeholder() Token {
+	for _, t := range s.Tokens {
+		if t.Kind == TokenPlaceholder {
+			return t
+		}
+	}
+	return Token{}
+}
+
+type parser struct {
+	sql    string
+	tokens []Token
+	// closing maps index of `(` to index of its `)`
+	closing map[int]int
+	pos     int
+	stmt    *Statement
+}
+
+// Parse parses one statement, a trailing `;` is allowed.
+// It classifies the statement and finds referenced tables, it is not a full grammar of TDengine,
+// but rejects obviously malformed sql like unbalanced parentheses, unknown statements, missing names and typos in keywords of selects.
+func Parse(sql string) (*Statement, error) {
+	tokens, err := Tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(tokens); n > 0 && tokens[n-1].Text == ";" && tokens[n-1].Kind == TokenSymbol {
+		tokens = tokens[:n-1]
+	}
+	if len(tokens) == 0 {
+		return nil, syntaxError(0, "empty statement")
+	}
+	p := &parser{sql: sql, tokens: tokens, closing: make(map[int]int), stmt: &Statement{Tokens: tokens}}
+	if err := p.scan(); err != nil {
+		return nil, err
+	}
+	if err := p.statement(); err != nil {
+		return nil, err
+	}
+	return p.stmt, nil
+}
+
+// scan checks parentheses, separators and placeholders
+func (p *parser) scan() error {
+	var open []int
+	positional, named := false, false
+	for i, t := range p.tokens {
+		switch t.Kind {
+		case TokenPlaceholder:
+			if t.Text == "?" {
+				positional = true
+			} else {
+				named = true
+			}
+			if positional && named {
+				return syntaxError(t.Pos, "positional and named placeholders can not be mixed")
+			}
+			p.stmt.Params = append(p.stmt.Params, t.Unquote())
+		case TokenKeyword:
+			if t.Text == "NOW" || t.Text == "TODAY" {
+				p.stmt.usesNow = true
+			}
+		case TokenSymbol:
+			switch t.Text {
+			case "(":
+				open = append(open, i)
+			case ")":
+				if len(open) == 0 {
+					return syntaxError(t.Pos, "unbalanced )")
+				}
+				p.closing[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			case ";":
+				return syntaxError(t.Pos, "only one statement is allowed")
+			case ",":
+				if i == 0 || p.tokens[i-1].Text == "(" || p.tokens[i-1].Text == "," {
+					return syntaxError(t.Pos, "unexpected ,")
+				}
+				if i+1 == len(p.tokens) || p.tokens[i+1].Text == ")" || p.tokens[i+1].Text == "FROM" {
+					return syntaxError(t.Pos, "unexpected ,")
+				}
+			}
+		}
+	}
+	if len(open) > 0 {
+		return syntaxError(p.tokens[open[len(open)-1]].Pos, "unbalanced (")
+	}
+	return nil
+}
+
+func (p *parser) peek() *Token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) next() *Token {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes keywords if they are next
+func (p *parser) accept(words ...string) bool {
+	if p.pos+len(words) > len(p.tokens) {
+		return false
+	}
+	for i, w := range words {
+		if t := p.tokens[p.pos+i]; t.Kind != TokenKeyword || t.Text != w {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *parser) unexpected(t *Token, expected string) error {
+	if t == nil {
+		return syntaxError(len(p.sql), "expected %s at end", expected)
+	}
+	return syntaxError(t.Pos, "expected %s but got %s", expected, t.Text)
+}
+
+func isName(t *Token) bool {
+	return t != nil && (t.Kind == TokenIdent || t.Kind == TokenQuotedIdent)
+}
+
+// name reads a name of database, topic or stream
+func (p *parser) name(what string) (string, error) {
+	t := p.peek()
+	if !isName(t) {
+		return "", p.unexpected(t, what)
+	}
+	p.pos++
+	return t.Unquote(), nil
+}
+
+func (p *parser) addDatabase(db string) {
+	for _, d := range p.stmt.Databases {
+		if d == db {
+			return
+		}
+	}
+	p.stmt.Databases = append(p.stmt.Databases, db)
+}
+
+// tableRef reads `name` or `db.name`
+func (p *parser) tableRef() error {
+	name, err := p.name("table name")
+	if err != nil {
+		return err
+	}
+	ref := TableRef{Name: name}
+	if t := p.peek(); t != nil && t.Kind == TokenSymbol && t.Text == "." {
+		p.pos++
+		t = p.peek()
+		if !isName(t) && (t == nil || t.Kind != TokenKeyword) {
+			return p.unexpected(t, "table name")
+		}
+		p.pos++
+		ref = TableRef{Database: name, Name: t.Unquote()}
+		p.addDatabase(name)
+	}
+	for _, r := range p.stmt.Tables {
+		if r == ref {
+			return nil
+		}
+	}
+	p.stmt.Tables = append(p.stmt.Tables, ref)
+	return nil
+}
+
+// group skips a parenthesized group, it returns false if next is not `(`
+func (p *parser) group() bool {
+	t := p.peek()
+	if t == nil || t.Kind != TokenSymbol || t.Text != "(" {
+		return false
+	}
+	p.pos = p.closing[p.pos] + 1
+	return true
+}
+
+func (p *parser) statement() error {
+	first := p.next()
+	if first.Kind == TokenSymbol && first.Text == "(" {
+		// a union of parenthesized selects
+		p.stmt.Kind = KindSelect
+		return p.queries(0)
+	}
+	if first.Kind != TokenKeyword {
+		return syntaxError(first.Pos, "unknown statement %s", first.Text)
+	}
+	switch first.Text {
+	case "SELECT":
+		p.stmt.Kind = KindSelect
+		return p.queries(0)
+	case "INSERT":
+		p.stmt.Kind = KindInsert
+		return p.insert()
+	case "CREATE":
+		p.stmt.Kind = KindCreate
+		return p.create()
+	case "DROP":
+		p.stmt.Kind = KindDrop
+		return p.drop()
+	case "ALTER":
+		p.stmt.Kind = KindAlter
+		return p.alter()
+	case "DELETE":
+		p.stmt.Kind = KindDelete
+		if !p.accept("FROM") {
+			return p.unexpected(p.peek(), "FROM")
+		}
+		return p.queries(p.pos - 1)
+	case "SHOW":
+		p.stmt.Kind = KindShow
+		return p.show()
+	case "DESCRIBE", "DESC":
+		p.stmt.Kind = KindDescribe
+		return p.end(p.tableRef())
+	case "USE":
+		p.stmt.Kind = KindUse
+		db, err := p.name("database name")
+		if err != nil {
+			return err
+		}
+		p.addDatabase(db)
+		return p.end(nil)
+	case "KILL":
+		p.stmt.Kind = KindKill
+		if t := p.next(); !isName(t) && (t == nil || t.Kind != TokenKeyword) {
+			return p.unexpected(t, "QUERY, CONNECTION or STREAM")
+		}
+		if p.peek() == nil {
+			return p.unexpected(nil, "id")
+		}
+		return nil
+	case "EXPLAIN":
+		p.stmt.Kind = KindExplain
+		return p.queries(p.pos)
+	}
+	return syntaxError(first.Pos, "unknown statement %s", first.Text)
+}
+
+// end checks that nothing follows the statement
+func (p *parser) end(err error) error {
+	if err != nil {
+		return err
+	}
+	if t := p.peek(); t != nil {
+		return syntaxError(t.Pos, "unexpected %s", t.Text)
+	}
+	return nil
+}
+
+// clauses are keywords which need something after them
+var clauses = map[string]struct{}{
+	"WHERE": {}, "HAVING": {}, "BY": {}, "LIMIT": {}, "SLIMIT": {}, "OFFSET": {}, "SOFFSET": {}, "INTERVAL": {},
+	"SLIDING": {}, "FILL": {}, "SESSION": {}, "STATE_WINDOW": {}, "FROM": {}, "UNION": {}, "ON": {}, "SELECT": {},
+}
+
+// clauseEnds are keywords which end a select list or the expression of WHERE, HAVING and ON
+var clauseEnds = map[string]struct{}{
+	"FROM": {}, "WHERE": {}, "GROUP": {}, "ORDER": {}, "PARTITION": {}, "INTERVAL": {}, "SLIDING": {}, "FILL": {},
+	"LIMIT": {}, "SLIMIT": {}, "SOFFSET": {}, "OFFSET": {}, "SESSION": {}, "STATE_WINDOW": {}, "EVENT_WINDOW": {},
+	"COUNT_WINDOW": {}, "UNION": {}, "HAVING": {}, "JOIN": {}, "ON": {}, "RANGE": {}, "EVERY": {},
+	"INNER": {}, "LEFT": {}, "RIGHT": {}, "FULL": {},
+}
+
+// queries checks selects and finds tables of FROM and JOIN in tokens from start
+func (p *parser) queries(start int) error {
+	for i := start; i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		if t.Kind != TokenKeyword {
+			continue
+		}
+		if _, ok := clauses[t.Text]; ok {
+			if i+1 == len(p.tokens) {
+				return syntaxError(len(p.sql), "expected something after %s", t.Text)
+			}
+			if next := p.tokens[i+1]; next.Kind == TokenKeyword && next.Text != "ALL" && next.Text != "DISTINCT" && next.Text != "SELECT" {
+				if _, ok := clauses[next.Text]; ok {
+					return syntaxError(next.Pos, "unexpected %s after %s", next.Text, t.Text)
+				}
+			} else if next.Kind == TokenSymbol && next.Text == ")" {
+				return syntaxError(next.Pos, "unexpected ) after %s", t.Text)
+			}
+		}
+		switch t.Text {
+		case "SELECT":
+			if err := p.selectList(i + 1); err != nil {
+				return err
+			}
+		case "WHERE", "HAVING", "ON":
+			items, end, err := p.collect(i + 1)
+			if err != nil {
+				return err
+			}
+			if err := p.expression(items, end); err != nil {
+				return err
+			}
+		case "FROM", "JOIN":
+			p.pos = i + 1
+			if err := p.sources(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sources reads tables of FROM, sub queries are checked by queries
+func (p *parser) sources() error {
+	for {
+		if !p.group() {
+			if err := p.tableRef(); err != nil {
+				return err
+			}
+		}
+		// alias
+		if p.accept("AS") {
+			if _, err := p.name("alias"); err != nil {
+				return err
+			}
+		} else if isName(p.peek()) {
+			p.pos++
+		}
+		if t := p.peek(); t == nil || t.Kind != TokenSymbol || t.Text != "," {
+			return nil
+		}
+		p.pos++
+	}
+}
+
+// tokenExpr is the kind of a group or a CASE expression collapsed into one operand by collect
+const tokenExpr TokenKind = -1
+
+// operators are keywords used inside expressions, other keywords are treated as names
+var operators = map[string]struct{}{
+	"AND": {}, "OR": {}, "NOT": {}, "IS": {}, "IN": {}, "LIKE": {}, "MATCH": {}, "NMATCH": {}, "BETWEEN": {},
+	"AS": {}, "DISTINCT": {}, "ALL": {}, "CASE": {}, "WHEN": {}, "THEN": {}, "ELSE": {},
+}
+
+// binaryOperators are keywords between two operands
+var binaryOperators = map[string]struct{}{
+	"AND": {}, "OR": {}, "IS": {}, "IN": {}, "LIKE": {}, "MATCH": {}, "NMATCH": {}, "BETWEEN": {},
+}
+
+// collect reads tokens from start until a `,` or `)` of the same level, a keyword of clauseEnds or the end,
+// it returns the tokens and the index where it stopped. Groups and CASE expressions are collapsed into one operand,
+// and a group after a name is the arguments of a function call.
+func (p *parser) collect(start int) ([]Token, int, error) {
+	items := make([]Token, 0)
+	i := start
+	for ; i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		if t.Kind == TokenSymbol && (t.Text == "," || t.Text == ")") {
+			break
+		}
+		if t.Kind == TokenKeyword {
+			if _, ok := clauseEnds[t.Text]; ok {
+				break
+			}
+		}
+		if t.Kind == TokenSymbol && t.Text == "(" {
+			if n := len(items); n == 0 || !isCallee(items[n-1]) {
+				items = append(items, Token{Kind: tokenExpr, Text: "(", Pos: t.Pos})
+			}
+			i = p.closing[i]
+			continue
+		}
+		if t.Kind == TokenKeyword && t.Text == "CASE" {
+			end, err := p.caseEnd(i)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, Token{Kind: tokenExpr, Text: "CASE", Pos: t.Pos})
+			i = end
+			continue
+		}
+		items = append(items, t)
+	}
+	return items, i, nil
+}
+
+// isCallee reports whether a group after t is the arguments of a function, like `count(*)` or `NOW()`
+func isCallee(t Token) bool {
+	if t.Kind == TokenKeyword {
+		_, ok := operators[t.Text]
+		return !ok
+	}
+	return t.Kind == TokenIdent
+}
+
+// selectList checks items of a select list starting at start.
+// Two operands next to each other are rejected unless the second is an alias, so `SELECT * FORM t` is an error.
+func (p *parser) selectList(start int) error {
+	for i := start; ; {
+		items, end, err := p.collect(i)
+		if err != nil {
+			return err
+		}
+		if err := p.selectItem(items, end); err != nil {
+			return err
+		}
+		if end == len(p.tokens) || p.tokens[end].Text != "," {
+			return nil
+		}
+		i = end + 1
+	}
+}
+
+// caseEnd returns index of END of CASE at start
+func (p *parser) caseEnd(start int) (int, error) {
+	depth := 0
+	for i := start; i < len(p.tokens); i++ {
+		t := p.tokens[i]
+		if t.Kind != TokenKeyword {
+			continue
+		}
+		switch t.Text {
+		case "CASE":
+			depth++
+		case "END":
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, syntaxError(p.tokens[start].Pos, "CASE without END")
+}
+
+// isOperand reports whether item[i] is a value or a name, `*` is a wildcard at the beginning or after `.`
+func isOperand(item []Token, i int) bool {
+	t := item[i]
+	switch t.Kind {
+	case TokenKeyword:
+		_, ok := operators[t.Text]
+		return !ok
+	case TokenSymbol:
+		return t.Text == "*" && (i == 0 || item[i-1].Text == ".")
+	}
+	return true
+}
+
+func (p *parser) selectItem(item []Token, end int) error {
+	if len(item) == 0 {
+		return p.expression(item, end)
+	}
+	// strip alias
+	n := len(item)
+	if last := item[n-1]; n > 1 && (last.Kind == TokenIdent || last.Kind == TokenQuotedIdent || last.Kind == TokenString) {
+		if prev := item[n-2]; prev.Kind == TokenKeyword && prev.Text == "AS" {
+			n -= 2
+		} else if isOperand(item, n-2) {
+			n--
+		}
+	}
+	if n == 0 {
+		return syntaxError(item[0].Pos, "expected a select item before AS")
+	}
+	return p.expression(item[:n], end)
+}
+
+// expression checks that operands and operators alternate in items collected before end.
+// NOT, `-`, `+`, `~` and DISTINCT can precede an operand, and NOT can precede IN, LIKE, MATCH and BETWEEN.
+func (p *parser) expression(items []Token, end int) error {
+	operand := true
+	for i, t := range items {
+		if operand {
+			if isPrefix(t) {
+				continue
+			}
+			if t.Kind == TokenSymbol && t.Text != "*" || !isOperand(items[i:i+1], 0) {
+				return syntaxError(t.Pos, "unexpected %s", t.Text)
+			}
+			operand = false
+			continue
+		}
+		if t.Kind == TokenKeyword && t.Text == "NOT" && i+1 < len(items) && items[i+1].Kind == TokenKeyword {
+			switch items[i+1].Text {
+			case "IN", "LIKE", "MATCH", "NMATCH", "BETWEEN":
+				continue
+			}
+		}
+		if !isBinary(t) {
+			return syntaxError(t.Pos, "unexpected %s", t.Text)
+		}
+		operand = true
+	}
+	if !operand {
+		return nil
+	}
+	if end == len(p.tokens) {
+		return syntaxError(len(p.sql), "expected an operand at end")
+	}
+	return syntaxError(p.tokens[end].Pos, "expected an operand before %s", p.tokens[end].Text)
+}
+
+func isPrefix(t Token) bool {
+	switch t.Kind {
+	case TokenKeyword:
+		return t.Text == "NOT" || t.Text == "DISTINCT" || t.Text == "ALL"
+	case TokenSymbol:
+		return t.Text == "-" || t.Text == "+" || t.Text == "~"
+	}
+	return false
+}
+
+func isBinary(t Token) bool {
+	switch t.Kind {
+	case TokenKeyword:
+		_, ok := binaryOperators[t.Text]
+		return ok
+	case TokenSymbol:
+		return true
+	}
+	return false
+}
+
+func (p *parser) ifNotExists() {
+	p.stmt.ifNotExists = p.accept("IF", "NOT", "EXISTS")
+}
+
+func (p *parser) ifExists() {
+	p.stmt.ifExists = p.accept("IF", "EXISTS")
+}
+
+// object reads the object keyword of DDL
+func (p *parser) object() error {
+	t := p.next()
+	if t == nil || (t.Kind != TokenKeyword && t.Kind != TokenIdent) {
+		return p.unexpected(t, "TABLE, STABLE, DATABASE or other object")
+	}
+	p.stmt.Object = strings.ToUpper(t.Text)
+	return nil
+}
+
+func (p *parser) insert() error {
+	if !p.accept("INTO") {
+		return p.unexpected(p.peek(), "INTO")
+	}
+	for {
+		if err := p.tableRef(); err != nil {
+			return err
+		}
+		hasValues := false
+	clauses:
+		for {
+			t := p.peek()
+			switch {
+			case t == nil:
+				break clauses
+			case p.accept("USING"):
+				if err := p.tableRef(); err != nil {
+					return err
+				}
+			case p.accept("TAGS"):
+				if !p.group() {
+					return p.unexpected(p.peek(), "( after TAGS")
+				}
+			case p.accept("VALUES"):
+				if !p.group() {
+					return p.unexpected(p.peek(), "( after VALUES")
+				}
+				for p.group() {
+				}
+				hasValues = true
+			case p.accept("FILE"):
+				if t := p.next(); t == nil || t.Kind != TokenString {
+					return p.unexpected(t, "file path")
+				}
+				hasValues = true
+			case t.Kind == TokenKeyword && t.Text == "SELECT":
+				return p.queries(p.pos)
+			case p.group():
+			default:
+				break clauses
+			}
+		}
+		if !hasValues {
+			return p.unexpected(p.peek(), "VALUES, FILE or SELECT")
+		}
+		t := p.peek()
+		if t == nil {
+			return nil
+		}
+		if !isName(t) {
+			return syntaxError(t.Pos, "unexpected %s", t.Text)
+		}
+	}
+}
+
+func (p *parser) create() error {
+	if err := p.object(); err != nil {
+		return err
+	}
+	switch p.stmt.Object {
+	case "DATABASE":
+		p.ifNotExists()
+		db, err := p.name("database name")
+		if err != nil {
+			return err
+		}
+		// options are not checked
+		p.addDatabase(db)
+		return nil
+	case "TABLE":
+		// a table, child tables, or a continuous query of 2.x
+		all := true
+		for {
+			p.ifNotExists()
+			all = all && p.stmt.ifNotExists
+			if err := p.tableRef(); err != nil {
+				return err
+			}
+			if p.accept("AS") {
+				return p.queries(p.pos)
+			}
+			if p.accept("USING") {
+				if err := p.tableRef(); err != nil {
+					return err
+				}
+				p.group()
+				if !p.accept("TAGS") {
+					return p.unexpected(p.peek(), "TAGS")
+				}
+			}
+			if !p.group() {
+				return p.unexpected(p.peek(), "(")
+			}
+			// tags of a normal table are not allowed, but this parser does not know the difference
+			if p.accept("TAGS") && !p.group() {
+				return p.unexpected(p.peek(), "( after TAGS")
+			}
+			if err := p.options(); err != nil {
+				return err
+			}
+			if t := p.peek(); t == nil || (!isName(t) && !(t.Kind == TokenKeyword && t.Text == "IF")) {
+				break
+			}
+		}
+		// retrying creates tables again if one of them has no IF NOT EXISTS
+		p.stmt.ifNotExists = all
+	case "STABLE":
+		p.ifNotExists()
+		if err := p.tableRef(); err != nil {
+			return err
+		}
+		if !p.group() {
+			return p.unexpected(p.peek(), "columns")
+		}
+		if !p.accept("TAGS") {
+			return p.unexpected(p.peek(), "TAGS")
+		}
+		if !p.group() {
+			return p.unexpected(p.peek(), "( after TAGS")
+		}
+		if err := p.options(); err != nil {
+			return err
+		}
+	case "TOPIC":
+		p.ifNotExists()
+		if _, err := p.name("topic name"); err != nil {
+			return err
+		}
+		// WITH META is not made of keywords, so names like meta keep working elsewhere
+		if t := p.peek(); t != nil && t.Kind == TokenIdent && strings.EqualFold(t.Text, "WITH") {
+			p.pos++
+			if t := p.next(); t == nil || t.Kind != TokenIdent || !strings.EqualFold(t.Text, "META") {
+				return p.unexpected(t, "META")
+			}
+		}
+		if !p.accept("AS") {
+			return p.unexpected(p.peek(), "AS")
+		}
+		switch {
+		case p.accept("STABLE"):
+			if err := p.tableRef(); err != nil {
+				return err
+			}
+		case p.accept("DATABASE"):
+			db, err := p.name("database name")
+			if err != nil {
+				return err
+			}
+			p.addDatabase(db)
+		default:
+			return p.queries(p.pos)
+		}
+	case "STREAM":
+		p.ifNotExists()
+		if _, err := p.name("stream name"); err != nil {
+			return err
+		}
+		for t := p.peek(); t != nil && !(t.Kind == TokenKeyword && t.Text == "INTO"); t = p.peek() {
+			p.pos++
+		}
+		if !p.accept("INTO") {
+			return p.unexpected(nil, "INTO")
+		}
+		if err := p.tableRef(); err != nil {
+			return err
+		}
+		for t := p.peek(); t != nil && !(t.Kind == TokenKeyword && t.Text == "AS"); t = p.peek() {
+			if !p.group() {
+				p.pos++
+			}
+		}
+		if !p.accept("AS") {
+			return p.unexpected(nil, "AS")
+		}
+		if t := p.peek(); t == nil || t.Text != "SELECT" {
+			return p.unexpected(t, "SELECT")
+		}
+		return p.queries(p.pos)
+	default:
+		// users, indexes, functions and nodes are not checked
+		return nil
+	}
+	return p.end(nil)
+}
+
+// tableOptions can follow the definition of a table or a super table in TDengine 3.x, like `COMMENT 'x'` or `TTL 10`
+var tableOptions = map[string]struct{}{
+	"COMMENT": {}, "WATERMARK": {}, "MAX_DELAY": {}, "ROLLUP": {}, "SMA": {}, "TTL": {}, "DELETE_MARK": {},
+}
+
+// options reads table options, a value is a literal, a number, a list of durations like `WATERMARK 5s,10s` or a group
+func (p *parser) options() error {
+	for {
+		t := p.peek()
+		if t == nil || t.Kind != TokenIdent {
+			return nil
+		}
+		option := strings.ToUpper(t.Text)
+		if _, ok := tableOptions[option]; !ok {
+			return nil
+		}
+		p.pos++
+		if p.group() {
+			continue
+		}
+		for {
+			if v := p.next(); v == nil || (v.Kind != TokenString && v.Kind != TokenNumber) {
+				return p.unexpected(v, "value of "+option)
+			}
+			if t := p.peek(); t == nil || t.Kind != TokenSymbol || t.Text != "," {
+				break
+			}
+			p.pos++
+		}
+	}
+}
+
+func (p *parser) drop() error {
+	if err := p.object(); err != nil {
+		return err
+	}
+	switch p.stmt.Object {
+	case "DATABASE":
+		p.ifExists()
+		db, err := p.name("database name")
+		if err != nil {
+			return err
+		}
+		p.addDatabase(db)
+	case "TABLE", "STABLE":
+		all := true
+		for {
+			p.ifExists()
+			all = all && p.stmt.ifExists
+			if err := p.tableRef(); err != nil {
+				return err
+			}
+			if t := p.peek(); t == nil || t.Kind != TokenSymbol || t.Text != "," {
+				break
+			}
+			p.pos++
+		}
+		p.stmt.ifExists = all
+	case "TOPIC", "STREAM":
+		p.ifExists()
+		if _, err := p.name(strings.ToLower(p.stmt.Object) + " name"); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	return p.end(nil)
+}
+
+func (p *parser) alter() error {
+	if err := p.object(); err != nil {
+		return err
+	}
+	switch p.stmt.Object {
+	case "DATABASE":
+		db, err := p.name("database name")
+		if err != nil {
+			return err
+		}
+		p.addDatabase(db)
+	case "TABLE", "STABLE":
+		if err := p.tableRef(); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	if p.peek() == nil {
+		return p.unexpected(nil, "changes")
+	}
+	return nil
+}
+
+// showWords can follow what to show, like `SHOW TABLE TAGS` or `SHOW DNODE 1 VARIABLES`
+var showWords = map[string]struct{}{
+	"VARIABLES": {}, "PRIVILEGES": {}, "TAGS": {}, "DISTRIBUTED": {}, "ALIVE": {},
+}
+
+func (p *parser) show() error {
+	if p.accept("CREATE") {
+		if err := p.object(); err != nil {
+			return err
+		}
+		if p.stmt.Object == "DATABASE" {
+			db, err := p.name("database name")
+			if err != nil {
+				return err
+			}
+			p.addDatabase(db)
+			return p.end(nil)
+		}
+		return p.end(p.tableRef())
+	}
+	// SHOW db.TABLES
+	if p.pos+2 < len(p.tokens) && isName(&p.tokens[p.pos]) && p.tokens[p.pos+1].Text == "." {
+		p.addDatabase(p.tokens[p.pos].Unquote())
+		p.pos += 2
+	}
+	t := p.next()
+	if t == nil || (t.Kind != TokenKeyword && t.Kind != TokenIdent) {
+		return p.unexpected(t, "what to show")
+	}
+	p.stmt.Object = strings.ToUpper(t.Text)
+	// an id like `SHOW DNODE 1 VARIABLES`
+	if t := p.peek(); t != nil && t.Kind == TokenNumber {
+		p.pos++
+	}
+	for t := p.peek(); t != nil && (t.Kind == TokenKeyword || t.Kind == TokenIdent); t = p.peek() {
+		word := strings.ToUpper(t.Text)
+		if _, ok := showWords[word]; !ok {
+			break
+		}
+		p.pos++
+		if word == "DISTRIBUTED" {
+			return p.end(p.tableRef())
+		}
+	}
+	from := 0
+	for {
+		switch {
+		case p.accept("LIKE"):
+			if t := p.next(); t == nil || t.Kind != TokenString {
+				return p.unexpected(t, "pattern")
+			}
+		case p.accept("FROM"):
+			from++
+			if from == 1 {
+				if err := p.tableRef(); err != nil {
+					return err
+				}
+				continue
+			}
+			// SHOW TAGS FROM tb FROM db
+			db, err := p.name("database name")
+			if err != nil {
+				return err
+			}
+			if last := &p.stmt.Tables[len(p.stmt.Tables)-1]; last.Database == "" {
+				last.Database = db
+			}
+			p.addDatabase(db)
+		case p.accept("ON"):
+			// SHOW VNODES ON DNODE 1
+			if t := p.next(); t == nil || !strings.EqualFold(t.Text, "DNODE") {
+				return p.unexpected(t, "DNODE")
+			}
+			if t := p.next(); t == nil || t.Kind != TokenNumber {
+				return p.unexpected(t, "dnode id")
+			}
+		default:
+			return p.end(nil)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package sqlparse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		sql       string
+		kind      Kind
+		object    string
+		tables    []string
+		databases []string
+		params    []string
+		retryable bool
+	}{
+		// selects
+		{"SELECT * FROM t", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"select 1", KindSelect, "", nil, nil, nil, true},
+		{"SELECT t.*, `a b` FROM db.t t WHERE ts > NOW - 1h;", KindSelect, "", []string{"db.t"}, []string{"db"}, nil, true},
+		{"SELECT avg(v) AS a, last(*) FROM st WHERE host = ? AND v BETWEEN ? AND ? PARTITION BY tbname INTERVAL(1m) SLIDING(30s) FILL(PREV) ORDER BY a DESC LIMIT 10",
+			KindSelect, "", []string{"st"}, nil, []string{"", "", ""}, true},
+		{"SELECT count(DISTINCT host) c FROM st WHERE host IN ('a', 'b') AND v IS NOT NULL AND name NOT LIKE 'x%' GROUP BY host HAVING count(*) > 1",
+			KindSelect, "", []string{"st"}, nil, nil, true},
+		{"SELECT CASE WHEN v > 0 THEN 'up' ELSE 'down' END state, CAST(v AS BIGINT), -v, ~flags FROM t", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"SELECT _wstart, max(v) FROM t STATE_WINDOW(status)", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"SELECT _wstart, count(*) FROM t SESSION(ts, 10m)", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"SELECT _wstart, count(*) FROM t EVENT_WINDOW START WITH v > 0 END WITH v < 0", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"SELECT interp(v) FROM t RANGE('2022-01-01', '2022-01-02') EVERY(1h) FILL(LINEAR)", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"SELECT * FROM t ORDER BY v NULLS FIRST", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"SELECT info->'k' FROM t WHERE info->'k' = 'v'", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"SELECT * FROM t WHERE host = :host AND v > @min", KindSelect, "", []string{"t"}, nil, []string{"host", "min"}, true},
+		{"SELECT * FROM (SELECT v FROM db1.a WHERE v > ?) WHERE v < ?", KindSelect, "", []string{"db1.a"}, []string{"db1"}, []string{"", ""}, true},
+		{"SELECT a.v, b.v FROM a INNER JOIN b ON a.ts = b.ts", KindSelect, "", []string{"a", "b"}, nil, nil, true},
+		{"SELECT a.v FROM a LEFT JOIN b ON a.ts = b.ts AND a.id = b.id", KindSelect, "", []string{"a", "b"}, nil, nil, true},
+		{"(SELECT v FROM a) UNION ALL (SELECT v FROM b)", KindSelect, "", []string{"a", "b"}, nil, nil, true},
+		{"SELECT v FROM a UNION ALL SELECT v FROM a", KindSelect, "", []string{"a"}, nil, nil, true},
+		{"SELECT * FROM t WHERE name = 'it''s ? -- not a comment'", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"SELECT * FROM t -- ?\nWHERE /* ? */ v = 1", KindSelect, "", []string{"t"}, nil, nil, true},
+		{"EXPLAIN SELECT * FROM t", KindExplain, "", []string{"t"}, nil, nil, true},
+
+		// inserts
+		{"INSERT INTO t VALUES (NOW, 1)", KindInsert, "", []string{"t"}, nil, nil, false},
+		{"INSERT INTO t VALUES (?, ?) (?, ?)", KindInsert, "", []string{"t"}, nil, []string{"", "", "", ""}, true},
+		{"INSERT INTO d1 USING st TAGS ('a') VALUES (1, 1) d2 USING db.st (host) TAGS ('b') (ts, v) VALUES (2, 2)",
+			KindInsert, "", []string{"d1", "st", "d2", "db.st"}, []string{"db"}, nil, true},
+		{"INSERT INTO t FILE '/tmp/a.csv'", KindInsert, "", []string{"t"}, nil, nil, true},
+		{"INSERT INTO t SELECT * FROM s", KindInsert, "", []string{"t", "s"}, nil, nil, true},
+
+		// deletes
+		{"DELETE FROM t WHERE ts < '2022-01-01'", KindDelete, "", []string{"t"}, nil, nil, true},
+		{"DELETE FROM t WHERE ts < NOW - 1d", KindDelete, "", []string{"t"}, nil, nil, false},
+
+		// create
+		{"CREATE DATABASE IF NOT EXISTS db PRECISION 'us'", KindCreate, "DATABASE", nil, []string{"db"}, nil, true},
+		{"CREATE DATABASE db", KindCreate, "DATABASE", nil, []string{"db"}, nil, false},
+		{"CREATE STABLE IF NOT EXISTS st (ts TIMESTAMP, v DOUBLE) TAGS (host BINARY(64))", KindCreate, "STABLE", []string{"st"}, nil, nil, true},
+		{"CREATE STABLE st (ts TIMESTAMP, v DOUBLE) TAGS (host BINARY(64)) COMMENT 'x'", KindCreate, "STABLE", []string{"st"}, nil, nil, false},
+		{"CREATE STABLE st (ts TIMESTAMP, v DOUBLE) TAGS (host NCHAR(8)) ROLLUP(avg) WATERMARK 1s MAX_DELAY 5s, 10s", KindCreate, "STABLE", []string{"st"}, nil, nil, false},
+		{"CREATE TABLE IF NOT EXISTS t (ts TIMESTAMP, v INT) TTL 10", KindCreate, "TABLE", []string{"t"}, nil, nil, true},
+		{"CREATE TABLE IF NOT EXISTS a USING st TAGS (1) IF NOT EXISTS b USING st (host) TAGS (2) TTL 1", KindCreate, "TABLE", []string{"a", "st", "b"}, nil, nil, true},
+		{"CREATE TABLE a USING st TAGS (1) IF NOT EXISTS b USING st TAGS (2)", KindCreate, "TABLE", []string{"a", "st", "b"}, nil, nil, false},
+		{"CREATE TABLE IF NOT EXISTS a USING st TAGS (1) b USING st TAGS (2)", KindCreate, "TABLE", []string{"a", "st", "b"}, nil, nil, false},
+		{"CREATE TOPIC IF NOT EXISTS tp AS SELECT * FROM st", KindCreate, "TOPIC", []string{"st"}, nil, nil, true},
+		{"CREATE TOPIC tp AS DATABASE db", KindCreate, "TOPIC", nil, []string{"db"}, nil, false},
+		{"CREATE TOPIC t2 WITH META AS STABLE power.meters", KindCreate, "TOPIC", []string{"power.meters"}, []string{"power"}, nil, false},
+		{"create topic tp with meta as database db", KindCreate, "TOPIC", nil, []string{"db"}, nil, false},
+		{"SELECT meta, with FROM st", KindSelect, "", []string{"st"}, nil, nil, true},
+		{"CREATE STREAM IF NOT EXISTS s TRIGGER AT_ONCE INTO out AS SELECT _wstart, count(*) FROM st INTERVAL(1m)", KindCreate, "STREAM", []string{"out", "st"}, nil, nil, true},
+
+		// drop
+		{"DROP TABLE IF EXISTS a, IF EXISTS db.b", KindDrop, "TABLE", []string{"a", "db.b"}, []string{"db"}, nil, true},
+		{"DROP TABLE IF EXISTS a, b", KindDrop, "TABLE", []string{"a", "b"}, nil, nil, false},
+		{"DROP STABLE st", KindDrop, "STABLE", []string{"st"}, nil, nil, false},
+		{"DROP DATABASE IF EXISTS db", KindDrop, "DATABASE", nil, []string{"db"}, nil, true},
+		{"DROP TOPIC tp", KindDrop, "TOPIC", nil, nil, nil, false},
+
+		// alter
+		{"ALTER STABLE st ADD TAG region BINARY(16)", KindAlter, "STABLE", []string{"st"}, nil, nil, false},
+		{"ALTER DATABASE db KEEP 365", KindAlter, "DATABASE", nil, []string{"db"}, nil, false},
+
+		// show, describe and others
+		{"SHOW TABLES", KindShow, "TABLES", nil, nil, nil, true},
+		{"SHOW db.STABLES LIKE 'st%'", KindShow, "STABLES", nil, []string{"db"}, nil, true},
+		{"SHOW DNODES", KindShow, "DNODES", nil, nil, nil, true},
+		{"SHOW DNODE 1 VARIABLES", KindShow, "DNODE", nil, nil, nil, true},
+		{"SHOW VNODES ON DNODE 1", KindShow, "VNODES", nil, nil, nil, true},
+		{"SHOW TAGS FROM t FROM db", KindShow, "TAGS", []string{"db.t"}, []string{"db"}, nil, true},
+		{"SHOW TABLE TAGS FROM st", KindShow, "TABLE", []string{"st"}, nil, nil, true},
+		{"SHOW TABLE DISTRIBUTED db.st", KindShow, "TABLE", []string{"db.st"}, []string{"db"}, nil, true},
+		{"SHOW CREATE TABLE db.t", KindShow, "TABLE", []string{"db.t"}, []string{"db"}, nil, true},
+		{"SHOW CREATE DATABASE db", KindShow, "DATABASE", nil, []string{"db"}, nil, true},
+		{"DESCRIBE db.t", KindDescribe, "", []string{"db.t"}, []string{"db"}, nil, true},
+		{"USE db", KindUse, "", nil, []string{"db"}, nil, true},
+		{"KILL QUERY '1:2'", KindKill, "", nil, nil, nil, false},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.sql)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", c.sql, err)
+			continue
+		}
+		if s.Kind != c.kind {
+			t.Errorf("Parse(%q).Kind = %v, want %v", c.sql, s.Kind, c.kind)
+		}
+		if s.Object != c.object {
+			t.Errorf("Parse(%q).Object = %q, want %q", c.sql, s.Object, c.object)
+		}
+		tables := make([]string, 0)
+		for _, table := range s.Tables {
+			tables = append(tables, table.String())
+		}
+		if len(tables) != len(c.tables) || (len(tables) > 0 && !reflect.DeepEqual(tables, c.tables)) {
+			t.Errorf("Parse(%q).Tables = %v, want %v", c.sql, tables, c.tables)
+		}
+		if len(s.Databases) != len(c.databases) || (len(s.Databases) > 0 && !reflect.DeepEqual(s.Databases, c.databases)) {
+			t.Errorf("Parse(%q).Databases = %v, want %v", c.sql, s.Databases, c.databases)
+		}
+		if len(s.Params) != len(c.params) || (len(s.Params) > 0 && !reflect.DeepEqual(s.Params, c.params)) {
+			t.Errorf("Parse(%q).Params = %q, want %q", c.sql, s.Params, c.params)
+		}
+		if s.Retryable() != c.retryable {
+			t.Errorf("Parse(%q).Retryable() = %v, want %v", c.sql, s.Retryable(), c.retryable)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		"",
+		";",
+		"SELECT",
+		"SELECT * FORM t",
+		"SELECT a b c FROM t",
+		"SELECT a, FROM t",
+		"SELECT * FROM",
+		"SELECT * FROM t WHERE",
+		"SELECT * FROM t WHERE v > 0 AND AND v < 1",
+		"SELECT * FROM t WHERE v > 0 AND",
+		"SELECT * FROM t WHERE v v",
+		"SELECT * FROM t WHERE ORDER BY v",
+		"SELECT * FROM a JOIN b ON",
+		"SELECT count(*) FROM t GROUP BY host HAVING > 1",
+		"SELECT CASE WHEN v > 0 THEN 1 FROM t",
+		"SELECT (v FROM t",
+		"SELECT v) FROM t",
+		"SELECT * FROM t; SELECT 1",
+		"SELECT 'abc FROM t",
+		"SELECT * FROM t WHERE a = ? AND b = :b",
+		"INSERT t VALUES (1)",
+		"INSERT INTO t",
+		"INSERT INTO t VALUES",
+		"CREATE TABLE t",
+		"CREATE TABLE t (ts TIMESTAMP) TTL",
+		"CREATE STABLE st (ts TIMESTAMP, v INT)",
+		"CREATE STABLE st (ts TIMESTAMP, v INT) TAGS (a INT) COMMENT",
+		"CREATE STABLE st (ts TIMESTAMP, v INT) TAGS (a INT) garbage",
+		"DROP TABLE",
+		"DROP TABLE a b",
+		"ALTER TABLE t",
+		"DELETE t",
+		"SHOW",
+		"SHOW TABLES garbage garbage",
+		"SHOW TABLES LIKE",
+		"SHOW VNODES ON 1",
+		"CREATE TOPIC tp WITH AS DATABASE db",
+		"CREATE TOPIC tp META AS DATABASE db",
+		"CREATE TOPIC tp WITH META",
+		"USE",
+		"USE a b",
+		"DESCRIBE",
+		"UPDATE t SET v = 1",
+		"SELECT * FROM t WHERE a = #",
+	}
+	for _, sql := range cases {
+		if _, err := Parse(sql); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q) error = %v, want ErrSyntax", sql, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("SELECT * FROM t WHERE v = 1"); err != nil {
+		t.Error(err)
+	}
+	if err := Validate("SELECT * FROM t WHERE v = ?"); !errors.Is(err, ErrSyntax) {
+		t.Errorf("unbound placeholder is accepted: %v", err)
+	}
+	if err := Validate("SELECT * FROM t WHERE v = '?'"); err != nil {
+		t.Errorf("placeholder in a literal is rejected: %v", err)
+	}
+	// built by NewCreateTopic(..).WithMeta().AsSTable(..)
+	if err := Validate("CREATE TOPIC t2 WITH META AS STABLE power.meters"); err != nil {
+		t.Errorf("topic with meta is rejected: %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	cases := map[string]bool{
+		"SELECT * FROM t":            true,
+		"SHOW TABLES":                true,
+		"DESCRIBE t":                 true,
+		"USE db":                     true,
+		"EXPLAIN SELECT * FROM t":    true,
+		"INSERT INTO t VALUES (1)":   false,
+		"DELETE FROM t":              false,
+		"DROP TABLE IF EXISTS t":     false,
+		"ALTER TABLE t ADD COLUMN v": false,
+	}
+	for sql, want := range cases {
+		s, err := Parse(sql)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", sql, err)
+			continue
+		}
+		if s.ReadOnly() != want {
+			t.Errorf("Parse(%q).ReadOnly() = %v, want %v", sql, s.ReadOnly(), want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, err := Tokenize("select `a b`, 'it''s', 1.5e3, 10s, :name, ?, a->'k' -- c\nfrom t /* c */ where x<=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Token{
+		{TokenKeyword, "SELECT", 0},
+		{TokenQuotedIdent, "`a b`", 7},
+		{TokenSymbol, ",", 12},
+		{TokenString, "'it''s'", 14},
+		{TokenSymbol, ",", 21},
+		{TokenNumber, "1.5e3", 23},
+		{TokenSymbol, ",", 28},
+		{TokenNumber, "10s", 30},
+		{TokenSymbol, ",", 33},
+		{TokenPlaceholder, ":name", 35},
+		{TokenSymbol, ",", 40},
+		{TokenPlaceholder, "?", 42},
+		{TokenSymbol, ",", 43},
+		{TokenIdent, "a", 45},
+		{TokenSymbol, "->", 46},
+		{TokenString, "'k'", 48},
+		{TokenKeyword, "FROM", 57},
+		{TokenIdent, "t", 62},
+		{TokenKeyword, "WHERE", 72},
+		{TokenIdent, "x", 78},
+		{TokenSymbol, "<=", 79},
+		{TokenNumber, "1", 81},
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("Tokenize() = %v\nwant %v", tokens, want)
+	}
+	if got := tokens[1].Unquote(); got != "a b" {
+		t.Errorf("Unquote() = %q", got)
+	}
+	if got := tokens[3].Unquote(); got != "it's" {
+		t.Errorf("Unquote() = %q", got)
+	}
+	if got := tokens[9].Unquote(); got != "name" {
+		t.Errorf("Unquote() = %q", got)
+	}
+}
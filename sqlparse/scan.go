@@ -0,0 +1,99 @@
+package sqlparse
+
+import (
+	"strings"
+)
+
+// Skip returns the end of the quoted literal, quoted identifier or comment starting at i of sql, or i when none starts there.
+// Quotes are escaped by doubling them, or with a backslash except in identifiers quoted by backticks.
+// A line comment ends before its newline.
+func Skip(sql string, i int) (int, error) {
+	switch c := sql[i]; {
+	case c == '\'' || c == '"' || c == '`':
+		for j := i + 1; j < len(sql); j++ {
+			switch sql[j] {
+			case '\\':
+				if c != '`' {
+					j++
+				}
+			case c:
+				if j+1 < len(sql) && sql[j+1] == c {
+					j++
+					continue
+				}
+				return j + 1, nil
+			}
+		}
+		return 0, syntaxError(i, "unterminated literal")
+	case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+		if end := strings.IndexByte(sql[i:], '\n'); end != -1 {
+			return i + end, nil
+		}
+		return len(sql), nil
+	case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+		end := strings.Index(sql[i+2:], "*/")
+		if end == -1 {
+			return 0, syntaxError(i, "unterminated comment")
+		}
+		return i + end + 4, nil
+	}
+	return i, nil
+}
+
+func isComment(sql string, i int) bool {
+	return sql[i] == '-' || sql[i] == '/'
+}
+
+// split splits sql by `;` outside literals and comments, comments are replaced by a space.
+// last is the trimmed statement after the last `;` and rest is its raw text.
+func split(sql string) (statements []string, last string, rest string, err error) {
+	statements = make([]string, 0)
+	current := &strings.Builder{}
+	start := 0
+	for i := 0; i < len(sql); {
+		end, err := Skip(sql, i)
+		if err != nil {
+			return statements, "", sql[start:], err
+		}
+		if end > i {
+			if isComment(sql, i) {
+				current.WriteByte(' ')
+			} else {
+				current.WriteString(sql[i:end])
+			}
+			i = end
+			continue
+		}
+		if sql[i] == ';' {
+			if s := strings.TrimSpace(current.String()); s != "" {
+				statements = append(statements, s)
+			}
+			current.Reset()
+			start = i + 1
+		} else {
+			current.WriteByte(sql[i])
+		}
+		i++
+	}
+	return statements, strings.TrimSpace(current.String()), sql[start:], nil
+}
+
+// Split splits a script into statements separated by `;`, the last statement does not need a `;`.
+// Comments are removed, statements are trimmed and empty ones are dropped.
+func Split(script string) ([]string, error) {
+	statements, last, _, err := split(script)
+	if err != nil {
+		return nil, err
+	}
+	if last != "" {
+		statements = append(statements, last)
+	}
+	return statements, nil
+}
+
+// SplitPrefix splits statements terminated by `;` from input which is still being written, like lines of a shell.
+// rest is the raw text after the last `;`, which may end in an unterminated literal or comment.
+func SplitPrefix(input string) (statements []string, rest string) {
+	statements, _, rest, _ = split(input)
+	return statements, rest
+}
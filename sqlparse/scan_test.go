@@ -0,0 +1,92 @@
+package sqlparse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSkip(t *testing.T) {
+	cases := []struct {
+		sql  string
+		i    int
+		want int
+	}{
+		{"a 'b' c", 0, 0},
+		{"a 'b' c", 2, 5},
+		{`'it''s' x`, 0, 7},
+		{`'it\'s' x`, 0, 7},
+		{`"a\"b" x`, 0, 6},
+		{"`a\\` x", 0, 4},
+		{"`a``b` x", 0, 6},
+		{"-- c\nx", 0, 4},
+		{"-- c", 0, 4},
+		{"/* ' */ x", 0, 7},
+		{"- 1", 0, 0},
+		{"/ 1", 0, 0},
+	}
+	for _, c := range cases {
+		got, err := Skip(c.sql, c.i)
+		if err != nil {
+			t.Errorf("Skip(%q, %d): %v", c.sql, c.i, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Skip(%q, %d) = %d, want %d", c.sql, c.i, got, c.want)
+		}
+	}
+	for _, sql := range []string{"'abc", "`abc", "/* abc", `'abc\'`} {
+		if _, err := Skip(sql, 0); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Skip(%q) error = %v, want ErrSyntax", sql, err)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		script string
+		want   []string
+	}{
+		{"", []string{}},
+		{" ; ;\n", []string{}},
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1;\nSELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"INSERT INTO t VALUES (1, 'a;b');SELECT ';'", []string{"INSERT INTO t VALUES (1, 'a;b')", "SELECT ';'"}},
+		{"-- first; still a comment\nCREATE TABLE t (ts TIMESTAMP); /* ; */ DROP TABLE t", []string{"CREATE TABLE t (ts TIMESTAMP)", "DROP TABLE t"}},
+		{"SELECT `a;b` FROM t", []string{"SELECT `a;b` FROM t"}},
+	}
+	for _, c := range cases {
+		got, err := Split(c.script)
+		if err != nil {
+			t.Errorf("Split(%q): %v", c.script, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Split(%q) = %q, want %q", c.script, got, c.want)
+		}
+	}
+	if _, err := Split("SELECT 1; SELECT 'a"); !errors.Is(err, ErrSyntax) {
+		t.Errorf("unterminated literal is accepted: %v", err)
+	}
+}
+
+func TestSplitPrefix(t *testing.T) {
+	cases := []struct {
+		input      string
+		statements []string
+		rest       string
+	}{
+		{"SELECT 1", []string{}, "SELECT 1"},
+		{"SELECT 1;", []string{"SELECT 1"}, ""},
+		{"SELECT 1; SELECT\n", []string{"SELECT 1"}, " SELECT\n"},
+		{"SELECT ';", []string{}, "SELECT ';"},
+		{"SELECT 1; /* ;", []string{"SELECT 1"}, " /* ;"},
+		{"SELECT ';'; -- x;", []string{"SELECT ';'"}, " -- x;"},
+	}
+	for _, c := range cases {
+		statements, rest := SplitPrefix(c.input)
+		if !reflect.DeepEqual(statements, c.statements) || rest != c.rest {
+			t.Errorf("SplitPrefix(%q) = %q, %q, want %q, %q", c.input, statements, rest, c.statements, c.rest)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package sqlparse
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSyntax is wrapped by errors of malformed sql
+var ErrSyntax = errors.New("sqlparse: syntax error")
+
+type TokenKind int
+
+const (
+	TokenIdent TokenKind = iota + 1
+	TokenKeyword
+	// TokenQuotedIdent is a name quoted by backticks
+	TokenQuotedIdent
+	// TokenString is quoted by single or double quotes
+	TokenString
+	// TokenNumber is a number or a duration like `10s`
+	TokenNumber
+	// TokenPlaceholder is `?`, `:name` or `@name`
+	TokenPlaceholder
+	TokenSymbol
+)
+
+// Token is a token of sql, Text of keywords is upper case and Text of others is as written.
+// Pos is the byte offset in sql.
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  int
+}
+
+// keywords are words with a meaning in statement structure, other words are identifiers or functions
+var keywords = map[string]struct{}{
+	"ADD": {}, "ALL": {}, "ALTER": {}, "AND": {}, "AS": {}, "ASC": {}, "BETWEEN": {}, "BY": {},
+	"CASE": {}, "COUNT_WINDOW": {}, "CREATE": {}, "DATABASE": {}, "DATABASES": {}, "DELETE": {}, "DESC": {},
+	"DESCRIBE": {}, "DISTINCT": {}, "DROP": {}, "ELSE": {}, "END": {}, "EVENT_WINDOW": {}, "EVERY": {},
+	"EXISTS": {}, "EXPLAIN": {}, "FALSE": {}, "FILE": {}, "FILL": {}, "FROM": {}, "FULL": {}, "GROUP": {},
+	"HAVING": {}, "IF": {}, "IN": {}, "INNER": {}, "INSERT": {}, "INTERVAL": {}, "INTO": {}, "IS": {},
+	"JOIN": {}, "KILL": {}, "LEFT": {}, "LIKE": {}, "LIMIT": {}, "MATCH": {}, "NMATCH": {}, "NOT": {},
+	"NOW": {}, "NULL": {}, "OFFSET": {}, "ON": {}, "OR": {}, "ORDER": {}, "PARTITION": {}, "RANGE": {},
+	"RIGHT": {}, "SELECT": {}, "SESSION": {}, "SHOW": {}, "SLIDING": {}, "SLIMIT": {}, "SOFFSET": {},
+	"STABLE": {}, "STABLES": {}, "STATE_WINDOW": {}, "STREAM": {}, "TABLE": {}, "TABLES": {}, "TAGS": {},
+	"THEN": {}, "TODAY": {}, "TOPIC": {}, "TRUE": {}, "UNION": {}, "USE": {}, "USING": {}, "VALUES": {},
+	"WHEN": {}, "WHERE": {},
+}
+
+// symbols of two characters, others are one character
+var symbols = []string{"<=", ">=", "<>", "!=", "==", "->", "||", "<<", ">>"}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNamePart(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func syntaxError(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w at %d, %s", ErrSyntax, pos, fmt.Sprintf(format, args...))
+}
+
+// Tokenize splits sql into tokens, comments are dropped.
+// Literals are unquoted like TDengine does: quotes are escaped by doubling them or with a backslash.
+func Tokenize(sql string) ([]Token, error) {
+	tokens := make([]Token, 0)
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start := i
+		end, err := Skip(sql, i)
+		if err != nil {
+			return nil, err
+		}
+		if end > i {
+			i = end
+			if isComment(sql, start) {
+				continue
+			}
+			kind := TokenString
+			if c == '`' {
+				kind = TokenQuotedIdent
+			}
+			tokens = append(tokens, Token{Kind: kind, Text: sql[start:i], Pos: start})
+			continue
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			i = scanNumber(sql, i)
+			tokens = append(tokens, Token{Kind: TokenNumber, Text: sql[start:i], Pos: start})
+		case isNameStart(c):
+			for i < len(sql) && isNamePart(sql[i]) {
+				i++
+			}
+			word := sql[start:i]
+			if _, ok := keywords[strings.ToUpper(word)]; ok {
+				tokens = append(tokens, Token{Kind: TokenKeyword, Text: strings.ToUpper(word), Pos: start})
+			} else {
+				tokens = append(tokens, Token{Kind: TokenIdent, Text: word, Pos: start})
+			}
+		case c == '?':
+			i++
+			tokens = append(tokens, Token{Kind: TokenPlaceholder, Text: "?", Pos: start})
+		case (c == ':' || c == '@') && i+1 < len(sql) && isNameStart(sql[i+1]):
+			i++
+			for i < len(sql) && isNamePart(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, Token{Kind: TokenPlaceholder, Text: sql[start:i], Pos: start})
+		default:
+			text := ""
+			for _, s := range symbols {
+				if strings.HasPrefix(sql[i:], s) {
+					text = s
+					break
+				}
+			}
+			if text == "" {
+				if !strings.ContainsRune("(),.;*+-/%=<>&|~^!:", rune(c)) {
+					return nil, syntaxError(i, "unexpected character %q", c)
+				}
+				text = sql[i : i+1]
+			}
+			i += len(text)
+			tokens = append(tokens, Token{Kind: TokenSymbol, Text: text, Pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+// scanNumber returns the end of a number, letters after digits are kept for durations like `10s` and hex like `0x1f`
+func scanNumber(sql string, i int) int {
+	for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.') {
+		i++
+	}
+	if i+1 < len(sql) && (sql[i] == 'e' || sql[i] == 'E') && (isDigit(sql[i+1]) || sql[i+1] == '+' || sql[i+1] == '-') {
+		i += 2
+		for i < len(sql) && isDigit(sql[i]) {
+			i++
+		}
+	}
+	for i < len(sql) && isNamePart(sql[i]) {
+		i++
+	}
+	return i
+}
+
+// Unquote returns the value of a string, quoted identifier or placeholder name, others are returned as they are
+func (t Token) Unquote() string {
+	switch t.Kind {
+	case TokenString, TokenQuotedIdent:
+		quote := t.Text[0]
+		body := t.Text[1 : len(t.Text)-1]
+		b := &strings.Builder{}
+		for i := 0; i < len(body); i++ {
+			c := body[i]
+			if c == '\\' && quote != '`' && i+1 < len(body) {
+				i++
+				c = body[i]
+			} else if c == quote && i+1 < len(body) && body[i+1] == quote {
+				i++
+			}
+			b.WriteByte(c)
+		}
+		return b.String()
+	case TokenPlaceholder:
+		return strings.TrimLeft(t.Text, ":@?")
+	}
+	return t.Text
+}
@@ -0,0 +1,114 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Statement is a parsed sql template which can be queried many times with different args.
+// Placeholders can be positional `?` or named `:name` / `@name`, but can not be mixed in one statement.
+// Named placeholders are only parsed for named args, so `:name` is kept as it is with positional args.
+// Placeholders in quoted literals and comments are ignored.
+type Statement struct {
+	c          *Client
+	positional *sqlTemplate
+	// named is the template of named args, err is returned when it is used
+	named    *sqlTemplate
+	namedErr error
+}
+
+// Prepare parses sql once, the returned Statement is safe for concurrent use.
+func (c *Client) Prepare(sql string) (*Statement, error) {
+	positional, err := parseTemplate(sql, false)
+	if err != nil {
+		return nil, err
+	}
+	named, namedErr := parseTemplate(sql, true)
+	return &Statement{c: c, positional: positional, named: named, namedErr: namedErr}, nil
+}
+
+// Params returns the names of named params in order, names are empty for positional params.
+func (s *Statement) Params() []string {
+	if s.namedErr == nil && s.named.named {
+		return append([]string(nil), s.named.params...)
+	}
+	return append([]string(nil), s.positional.params...)
+}
+
+// SQL renders the statement with args, see Query for the supported args.
+func (s *Statement) SQL(args interface{}) (string, error) {
+	switch v := args.(type) {
+	case nil:
+		return s.positional.renderPositional(nil, s.c.encoders)
+	case []interface{}:
+		return s.positional.renderPositional(v, s.c.encoders)
+	case map[string]interface{}:
+		return s.renderNamed(v)
+	}
+	named, err := structArgs(args)
+	if err != nil {
+		return "", err
+	}
+	return s.renderNamed(named)
+}
+
+func (s *Statement) renderNamed(args map[string]interface{}) (string, error) {
+	if s.namedErr != nil {
+		return "", s.namedErr
+	}
+	return s.named.renderNamed(args, s.c.encoders)
+}
+
+// Query renders the statement with args and sends it.
+// args can be nil, []interface{} for positional params,
+// map[string]interface{} or a struct for named params.
+// Struct fields are matched by `mapstructure` tag or field name, case insensitively.
+func (s *Statement) Query(ctx context.Context, args interface{}) (*QueryResult, error) {
+	sql, err := s.SQL(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.c.validate(sql); err != nil {
+		return nil, err
+	}
+	broker, ok := s.c.pickAliveBroker()
+	if !ok {
+		return nil, ErrorNoAvailableBroker
+	}
+	return s.c.request(ctx, broker, sql)
+}
+
+func structArgs(args interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(args)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("%w, nil pointer args", ErrorInvalidQueryArgs)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w, unsupported args type %T", ErrorInvalidQueryArgs, args)
+	}
+	t := v.Type()
+	ret := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := f.Tag.Get("mapstructure"); tag != "" {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		ret[name] = v.Field(i).Interface()
+	}
+	return ret, nil
+}
@@ -0,0 +1,247 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StreamTrigger is the trigger mode of a stream
+type StreamTrigger string
+
+const (
+	// TriggerAtOnce computes results when data is written
+	TriggerAtOnce StreamTrigger = "AT_ONCE"
+	// TriggerWindowClose computes results when a window closes, the query needs a window
+	TriggerWindowClose StreamTrigger = "WINDOW_CLOSE"
+)
+
+// StreamBuilder builds `CREATE STREAM` of TDengine 3.x, options are written in the order they are set.
+//
+//	client.NewCreateStream("avg_current").
+//		Trigger(tdquery.TriggerWindowClose).
+//		Watermark(10 * time.Second).
+//		Into("avg_current_1m").
+//		AsSelect(client.NewSelectQueryBuilder().
+//			Select(tdquery.WindowStart(), tdquery.Select{ColumnName: "AVG(current)", Alias: "current"}).
+//			FromSTable("meters").
+//			Interval(tdquery.NewInterval("1m")))
+type StreamBuilder struct {
+	c           *Client
+	name        string
+	ifNotExists bool
+	options     []dbOption
+	database    string
+	into        string
+	intoColumns []string
+	tags        []Column
+	subTable    string
+	query       *SelectQueryBuilder
+	needsWindow bool
+}
+
+func (c *Client) NewCreateStream(name string) *StreamBuilder {
+	return &StreamBuilder{c: c, name: name, database: c.defaultDatabase()}
+}
+
+func (b *StreamBuilder) IfNotExists() *StreamBuilder {
+	b.ifNotExists = true
+	return b
+}
+
+// Option sets an option not covered by other methods, e.g. Option("DELETE_MARK", "1d")
+func (b *StreamBuilder) Option(key, value string) *StreamBuilder {
+	key = strings.ToUpper(key)
+	for i, o := range b.options {
+		if o.key == key {
+			b.options[i].value = value
+			return b
+		}
+	}
+	b.options = append(b.options, dbOption{key: key, value: value})
+	return b
+}
+
+func (b *StreamBuilder) Trigger(t StreamTrigger) *StreamBuilder {
+	b.needsWindow = t == TriggerWindowClose
+	return b.Option("TRIGGER", string(t))
+}
+
+// TriggerMaxDelay computes results when a window closes or d has passed since data is written, the query needs a window
+func (b *StreamBuilder) TriggerMaxDelay(d time.Duration) *StreamBuilder {
+	b.needsWindow = true
+	return b.Option("TRIGGER", "MAX_DELAY "+encodeDuration(d))
+}
+
+// Watermark sets how long to wait for out-of-order data before a window closes
+func (b *StreamBuilder) Watermark(d time.Duration) *StreamBuilder {
+	return b.Option("WATERMARK", encodeDuration(d))
+}
+
+// IgnoreExpired ignores data arriving after its window closes, it is the default of TDengine
+func (b *StreamBuilder) IgnoreExpired(ignore bool) *StreamBuilder {
+	return b.Option("IGNORE EXPIRED", boolFlag(ignore))
+}
+
+// IgnoreUpdate ignores updated data, needs TDengine 3.0.4.0 or later
+func (b *StreamBuilder) IgnoreUpdate(ignore bool) *StreamBuilder {
+	return b.Option("IGNORE UPDATE", boolFlag(ignore))
+}
+
+// FillHistory also computes results of data written before the stream is created
+func (b *StreamBuilder) FillHistory(fill bool) *StreamBuilder {
+	return b.Option("FILL_HISTORY", boolFlag(fill))
+}
+
+func boolFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// Into sets the super table storing results, it is created by TDengine if it does not exist
+func (b *StreamBuilder) Into(stable string) *StreamBuilder {
+	b.into = stable
+	return b
+}
+
+// UseDatabase sets database of the target super table
+func (b *StreamBuilder) UseDatabase(db string) *StreamBuilder {
+	b.database = db
+	return b
+}
+
+// IntoColumns names columns of the target super table in the order of selects
+func (b *StreamBuilder) IntoColumns(names ...string) *StreamBuilder {
+	b.intoColumns = append(b.intoColumns, names...)
+	return b
+}
+
+// Tags defines tags of the target super table, their values come from PARTITION BY of the query
+func (b *StreamBuilder) Tags(tags ...Column) *StreamBuilder {
+	b.tags = append(b.tags, tags...)
+	return b
+}
+
+// SubTable sets the expression of child table names, e.g. `CONCAT('avg_', tbname)`
+func (b *StreamBuilder) SubTable(expr string) *StreamBuilder {
+	b.subTable = expr
+	return b
+}
+
+func (b *StreamBuilder) AsSelect(query *SelectQueryBuilder) *StreamBuilder {
+	b.query = query
+	return b
+}
+
+// BuildWithParams generates sql with `?` placeholders of the query
+func (b *StreamBuilder) BuildWithParams() (string, []interface{}, error) {
+	if b.name == "" {
+		return "", nil, fmt.Errorf("%w, empty stream name", ErrInvalidDDL)
+	}
+	if b.into == "" {
+		return "", nil, fmt.Errorf("%w, stream %s has no target super table", ErrInvalidDDL, b.name)
+	}
+	if b.query == nil {
+		return "", nil, fmt.Errorf("%w, stream %s has no query", ErrInvalidDDL, b.name)
+	}
+	if b.needsWindow && b.query.interval == nil {
+		return "", nil, fmt.Errorf("%w, trigger of stream %s needs a window query", ErrInvalidDDL, b.name)
+	}
+	if len(b.tags) > 0 && len(b.query.partitionBy) == 0 {
+		return "", nil, fmt.Errorf("%w, tags of stream %s need PARTITION BY", ErrInvalidDDL, b.name)
+	}
+	sql, params, err := b.query.BuildWithParams()
+	if err != nil {
+		return "", nil, err
+	}
+	builder := &strings.Builder{}
+	builder.WriteString("CREATE STREAM ")
+	if b.ifNotExists {
+		builder.WriteString("IF NOT EXISTS ")
+	}
+	builder.WriteString(b.name)
+	for _, o := range b.options {
+		builder.WriteRune(' ')
+		builder.WriteString(o.key)
+		builder.WriteRune(' ')
+		builder.WriteString(o.value)
+	}
+	builder.WriteString(" INTO ")
+	builder.WriteString(qualifiedName(b.database, b.into))
+	if len(b.intoColumns) > 0 {
+		builder.WriteString(" (")
+		builder.WriteString(strings.Join(b.intoColumns, ", "))
+		builder.WriteRune(')')
+	}
+	if len(b.tags) > 0 {
+		builder.WriteString(" TAGS ")
+		if err := writeColumns(builder, b.tags); err != nil {
+			return "", nil, err
+		}
+	}
+	if b.subTable != "" {
+		builder.WriteString(" SUBTABLE(")
+		builder.WriteString(b.subTable)
+		builder.WriteRune(')')
+	}
+	builder.WriteString(" AS ")
+	builder.WriteString(sql)
+	return builder.String(), params, nil
+}
+
+func (b *StreamBuilder) Build() (string, error) {
+	sql, _, err := b.BuildWithParams()
+	return sql, err
+}
+
+func (b *StreamBuilder) Exec(ctx context.Context) error {
+	sql, params, err := b.BuildWithParams()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql, params...)
+}
+
+// NewDropStream drops a stream of TDengine 3.x
+func (c *Client) NewDropStream(name string) *DropBuilder {
+	return &DropBuilder{c: c, kind: "STREAM", names: []string{name}}
+}
+
+// StreamInfo is a stream returned by Client.Streams
+type StreamInfo struct {
+	// ID is only returned by 2.x, it is used by Client.KillStream
+	ID          string
+	Name        string
+	SQL         string
+	CreatedTime time.Time
+	// Status, Target, Watermark and Trigger are only returned by 3.x
+	Status    string
+	Target    string
+	Watermark string
+	Trigger   string
+}
+
+// Streams lists streams by `SHOW STREAMS`
+func (c *Client) Streams(ctx context.Context) ([]StreamInfo, error) {
+	rows, err := c.queryRows(ctx, "SHOW STREAMS")
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]StreamInfo, 0, len(rows))
+	for _, row := range rows {
+		ret = append(ret, StreamInfo{
+			ID:          rowString(row, "streamId", "stream_id"),
+			Name:        rowString(row, "stream_name", "dest table"),
+			SQL:         rowString(row, "sql"),
+			CreatedTime: rowTime(row, "create_time", "created_time"),
+			Status:      rowString(row, "status"),
+			Target:      rowString(row, "target_table", "dest table"),
+			Watermark:   rowString(row, "watermark"),
+			Trigger:     rowString(row, "trigger"),
+		})
+	}
+	return ret, nil
+}
@@ -0,0 +1,150 @@
+package tdquery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCreateStream(t *testing.T) {
+	c := NewClient(WithDatabase("power"))
+	avg := func() *SelectQueryBuilder {
+		return c.NewSelectQueryBuilder().Select(WindowStart(), Select{ColumnName: "AVG(current)", Alias: "current"}).
+			FromSTable("meters").Where(NewCondition("voltage", ">", 200)).Interval(NewInterval("10s"))
+	}
+	partitioned := func() *SelectQueryBuilder {
+		return avg().PartitionBy("location")
+	}
+	const query = `SELECT _wstart AS "WindowStart", AVG(current) AS "current" FROM power.meters WHERE voltage > ?`
+	cases := []struct {
+		name string
+		b    sqlBuilder
+		sql  string
+	}{
+		{"minimal", c.NewCreateStream("s").Into("avg").AsSelect(avg()),
+			"CREATE STREAM s INTO power.avg AS " + query + " INTERVAL(10S)"},
+		{"options in order", c.NewCreateStream("s").IfNotExists().Watermark(10 * time.Second).Trigger(TriggerWindowClose).
+			FillHistory(true).IgnoreExpired(false).IgnoreUpdate(true).Into("avg").AsSelect(avg()),
+			"CREATE STREAM IF NOT EXISTS s WATERMARK 10s TRIGGER WINDOW_CLOSE FILL_HISTORY 1 IGNORE EXPIRED 0 IGNORE UPDATE 1 INTO power.avg AS " + query + " INTERVAL(10S)"},
+		{"option set again keeps its place", c.NewCreateStream("s").Trigger(TriggerAtOnce).Option("delete_mark", "1d").
+			TriggerMaxDelay(1500 * time.Millisecond).Into("avg").AsSelect(avg()),
+			"CREATE STREAM s TRIGGER MAX_DELAY 1500a DELETE_MARK 1d INTO power.avg AS " + query + " INTERVAL(10S)"},
+		{"at once without window", c.NewCreateStream("s").Trigger(TriggerAtOnce).Into("copy").
+			AsSelect(c.NewSelectQueryBuilder().SelectColumn("ts").SelectColumn("current").FromSTable("meters")),
+			"CREATE STREAM s TRIGGER AT_ONCE INTO power.copy AS SELECT ts, current FROM power.meters"},
+		{"target of database", c.NewCreateStream("s").UseDatabase("stats").Into("avg").IntoColumns("ts", "current").AsSelect(avg()),
+			"CREATE STREAM s INTO stats.avg (ts, current) AS " + query + " INTERVAL(10S)"},
+		{"tags and sub table", c.NewCreateStream("s").Into("avg").Tags(NewSizedColumn("location", ColumnTypeBinary, 64)).
+			SubTable("CONCAT('avg_', location)").AsSelect(partitioned()),
+			"CREATE STREAM s INTO power.avg TAGS (location BINARY(64)) SUBTABLE(CONCAT('avg_', location)) AS " + query + " PARTITION BY location INTERVAL(10S)"},
+		{"sub table without tags", c.NewCreateStream("s").Into("avg").SubTable("CONCAT('avg_', tbname)").AsSelect(avg().PartitionBy("tbname")),
+			"CREATE STREAM s INTO power.avg SUBTABLE(CONCAT('avg_', tbname)) AS " + query + " PARTITION BY tbname INTERVAL(10S)"},
+		{"drop", c.NewDropStream("s").IfExists(), "DROP STREAM IF EXISTS s"},
+	}
+	for _, tc := range cases {
+		sql, err := tc.b.Build()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+	}
+
+	_, params, err := c.NewCreateStream("s").Into("avg").AsSelect(avg()).BuildWithParams()
+	if err != nil || !reflect.DeepEqual(params, []interface{}{200}) {
+		t.Errorf("query params = %v, %v", params, err)
+	}
+
+	noWindow := c.NewSelectQueryBuilder().SelectColumn("ts").FromSTable("meters")
+	invalid := []struct {
+		name string
+		b    sqlBuilder
+		err  error
+	}{
+		{"empty name", c.NewCreateStream("").Into("avg").AsSelect(avg()), ErrInvalidDDL},
+		{"no target", c.NewCreateStream("s").AsSelect(avg()), ErrInvalidDDL},
+		{"no query", c.NewCreateStream("s").Into("avg"), ErrInvalidDDL},
+		{"window close without window", c.NewCreateStream("s").Trigger(TriggerWindowClose).Into("avg").AsSelect(noWindow), ErrInvalidDDL},
+		{"max delay without window", c.NewCreateStream("s").TriggerMaxDelay(time.Second).Into("avg").AsSelect(noWindow), ErrInvalidDDL},
+		{"tags without partition", c.NewCreateStream("s").Into("avg").Tags(NewColumn("g", ColumnTypeInt)).AsSelect(avg()), ErrInvalidDDL},
+		{"tag without length", c.NewCreateStream("s").Into("avg").Tags(NewColumn("location", ColumnTypeBinary)).AsSelect(partitioned()), ErrInvalidColumn},
+		{"invalid query", c.NewCreateStream("s").Into("avg").AsSelect(c.NewSelectQueryBuilder().FromSTable("meters")), ErrEmptySelect},
+	}
+	for _, tc := range invalid {
+		if sql, err := tc.b.Build(); !errors.Is(err, tc.err) {
+			t.Errorf("%s: %s, %v", tc.name, sql, err)
+		}
+	}
+
+	// the last trigger decides whether a window is needed
+	if _, err := c.NewCreateStream("s").Trigger(TriggerWindowClose).Trigger(TriggerAtOnce).Into("avg").AsSelect(noWindow).Build(); err != nil {
+		t.Errorf("trigger replaced: %v", err)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	created := time.Unix(1700000000, 0)
+	s := newWSStandIn(t, map[string]*standInResult{
+		"show dnodes": dnodes3x,
+		"SHOW STREAMS": {
+			Names: []string{"stream_name", "create_time", "sql", "status", "source_db", "target_table", "watermark", "trigger"},
+			Types: []ColumnType{ColumnTypeBinary, ColumnTypeTimestamp, ColumnTypeBinary, ColumnTypeBinary, ColumnTypeBinary, ColumnTypeBinary,
+				ColumnTypeBigInt, ColumnTypeBinary},
+			Blocks: [][][]interface{}{{{"avg_current", created, "CREATE STREAM avg_current INTO avg AS SELECT ...", "ready", "power", "avg", 10000, "window close"}}},
+		},
+		"DROP STREAM IF EXISTS avg_current": {IsUpdate: true},
+	})
+	c := s.client(t, 1)
+	ctx := context.Background()
+	streams, err := c.Streams(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StreamInfo{{
+		Name:        "avg_current",
+		SQL:         "CREATE STREAM avg_current INTO avg AS SELECT ...",
+		CreatedTime: created,
+		Status:      "ready",
+		Target:      "avg",
+		Watermark:   "10000",
+		Trigger:     "window close",
+	}}
+	if len(streams) != 1 || !streams[0].CreatedTime.Equal(created) {
+		t.Fatalf("Streams() = %+v", streams)
+	}
+	streams[0].CreatedTime = created
+	if !reflect.DeepEqual(streams, want) {
+		t.Errorf("Streams() = %+v\nwant %+v", streams, want)
+	}
+	if err := c.NewDropStream("avg_current").IfExists().Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreams2x(t *testing.T) {
+	created := time.Unix(1700000000, 0)
+	// 2.x returns the id used by KillStream and names the stream by its target table
+	s := newWSStandIn(t, map[string]*standInResult{
+		"show dnodes": dnodes3x,
+		"SHOW STREAMS": {
+			Names:  []string{"streamId", "user", "dest table", "ip", "created_time", "exec", "time(ns)", "cycles", "sql"},
+			Types:  []ColumnType{ColumnTypeBinary, ColumnTypeBinary, ColumnTypeBinary, ColumnTypeBinary, ColumnTypeTimestamp, ColumnTypeTimestamp, ColumnTypeBigInt, ColumnTypeBigInt, ColumnTypeBinary},
+			Blocks: [][][]interface{}{{{"3:1", "root", "avg_current", "127.0.0.1:40230", created, created, 0, 2, "select avg(current) from power.meters interval(10s)"}}},
+		},
+	})
+	streams, err := s.client(t, 1).Streams(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("Streams() = %+v", streams)
+	}
+	if got := streams[0]; got.ID != "3:1" || got.Name != "avg_current" || got.Target != "avg_current" ||
+		got.SQL != "select avg(current) from power.meters interval(10s)" || !got.CreatedTime.Equal(created) {
+		t.Errorf("Streams() = %+v", got)
+	}
+}
@@ -0,0 +1,356 @@
+package tdquery
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const tmqURL = "/rest/tmq"
+
+// tmqResponse is the union of responses of tmq actions
+type tmqResponse struct {
+	Code          int          `json:"code"`
+	Message       string       `json:"message"`
+	Action        string       `json:"action"`
+	ReqID         uint64       `json:"req_id"`
+	HaveMessage   bool         `json:"have_message"`
+	Topic         string       `json:"topic"`
+	Database      string       `json:"database"`
+	VGroupID      int          `json:"vgroup_id"`
+	MessageType   int          `json:"message_type"`
+	MessageID     uint64       `json:"message_id"`
+	Offset        int64        `json:"offset"`
+	Completed     bool         `json:"completed"`
+	TableName     string       `json:"table_name"`
+	Rows          int          `json:"rows"`
+	FieldsNames   []string     `json:"fields_names"`
+	FieldsTypes   []ColumnType `json:"fields_types"`
+	FieldsLengths []int        `json:"fields_lengths"`
+	Precision     int          `json:"precision"`
+	Assignment    []Assignment `json:"assignment"`
+}
+
+// Assignment is the offset range of a vgroup of a topic assigned to a consumer
+type Assignment struct {
+	VGroupID int   `json:"vgroup_id"`
+	Offset   int64 `json:"offset"`
+	Begin    int64 `json:"begin"`
+	End      int64 `json:"end"`
+}
+
+// Message is a message of a topic, rows are grouped in blocks by child table.
+type Message struct {
+	Topic    string
+	Database string
+	VGroupID int
+	Offset   int64
+	// Blocks is empty for meta messages
+	Blocks []MessageBlock
+	id     uint64
+}
+
+// MessageBlock is rows of a child table, values are the same as Client.Query
+type MessageBlock struct {
+	Table  string
+	Result *QueryResult
+}
+
+// Data returns rows of all blocks, with child table name as `tbname` if the row has no tbname column.
+func (m *Message) Data() []map[string]interface{} {
+	data := make([]map[string]interface{}, 0)
+	for _, block := range m.Blocks {
+		for _, row := range block.Result.Data {
+			if _, ok := row["tbname"]; !ok && block.Table != "" {
+				row["tbname"] = block.Table
+			}
+			data = append(data, row)
+		}
+	}
+	return data
+}
+
+// GetResult decodes rows of message into v like SelectQueryBuilder.GetResult
+func (m *Message) GetResult(v interface{}) error {
+	precision := PrecisionMillisecond
+	if len(m.Blocks) > 0 {
+		precision = m.Blocks[0].Result.Precision
+	}
+	return decodeResult(m.Data(), precision, v)
+}
+
+// Consumer consumes topics through the WebSocket api `/rest/tmq` of taosAdapter, TDengine 3.x only.
+// It subscribes on the first call, and calls are serialized on one connection.
+//
+//	consumer := client.NewConsumer("group1", "meters_topic").OffsetReset("earliest")
+//	defer consumer.Close()
+//	for {
+//		msg, err := consumer.Poll(ctx, time.Second)
+//		if err != nil {
+//			return err
+//		}
+//		if msg == nil {
+//			continue
+//		}
+//		var rows []Meter
+//		err = msg.GetResult(&rows)
+//		...
+//		err = consumer.Commit(ctx, msg)
+//	}
+type Consumer struct {
+	c                  *Client
+	group              string
+	topics             []string
+	clientID           string
+	offsetReset        string
+	autoCommitInterval time.Duration
+	conn               *websocket.Conn
+	reqID              uint64
+	lock               sync.Mutex
+}
+
+func (c *Client) NewConsumer(group string, topics ...string) *Consumer {
+	return &Consumer{c: c, group: group, topics: topics}
+}
+
+func (cs *Consumer) ClientID(id string) *Consumer {
+	cs.clientID = id
+	return cs
+}
+
+// OffsetReset sets where to start when the group has no committed offset, `earliest`, `latest` or `none`.
+func (cs *Consumer) OffsetReset(reset string) *Consumer {
+	cs.offsetReset = reset
+	return cs
+}
+
+// AutoCommit commits offsets every interval, Commit is not needed then.
+func (cs *Consumer) AutoCommit(interval time.Duration) *Consumer {
+	cs.autoCommitInterval = interval
+	return cs
+}
+
+// roundTrip sends an action and reads until its response, binary messages are returned as block.
+// The connection is dropped on network errors and subscribed again by next call.
+func (cs *Consumer) roundTrip(ctx context.Context, action string, args map[string]interface{}) (*tmqResponse, []byte, error) {
+	conn := cs.conn
+	reqID := atomic.AddUint64(&cs.reqID, 1)
+	args["req_id"] = reqID
+	data, err := json.Marshal(&wsRequest{Action: action, Args: args})
+	if err != nil {
+		return nil, nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+	conn.SetReadDeadline(deadline)
+	// unblock reading when ctx is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	fail := func(err error) (*tmqResponse, []byte, error) {
+		conn.Close()
+		cs.conn = nil
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return fail(err)
+	}
+	for {
+		kind, msg, err := conn.ReadMessage()
+		if err != nil {
+			return fail(err)
+		}
+		if kind == websocket.BinaryMessage {
+			// timing(8) + req_id(8) + message_id(8) + raw block
+			if len(msg) < 24 || binary.LittleEndian.Uint64(msg[8:16]) != reqID {
+				continue
+			}
+			return nil, msg[24:], nil
+		}
+		res := &tmqResponse{}
+		if err := json.Unmarshal(msg, res); err != nil {
+			return fail(err)
+		}
+		if res.ReqID != reqID {
+			continue
+		}
+		if res.Code != 0 {
+			return res, nil, &TDEngineError{Code: res.Code, Message: res.Message}
+		}
+		return res, nil, nil
+	}
+}
+
+func (cs *Consumer) subscribe(ctx context.Context) error {
+	if cs.conn != nil {
+		return nil
+	}
+	if cs.group == "" || len(cs.topics) == 0 {
+		return fmt.Errorf("%w: consumer needs a group and topics", ErrInvalidStatement)
+	}
+	broker, ok := cs.c.pickAliveBroker()
+	if !ok {
+		return ErrorNoAvailableBroker
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, fmt.Sprintf("ws://%s:%d%s", broker, cs.c.port, tmqURL), nil)
+	if err != nil {
+		return err
+	}
+	cs.conn = conn
+	args := map[string]interface{}{
+		"group_id":        cs.group,
+		"topics":          cs.topics,
+		"auto_commit":     strconv.FormatBool(cs.autoCommitInterval > 0),
+		"with_table_name": "true",
+	}
+	if cs.clientID != "" {
+		args["client_id"] = cs.clientID
+	}
+	if cs.offsetReset != "" {
+		// the key is spelled offset_rest by taosAdapter
+		args["offset_rest"] = cs.offsetReset
+	}
+	if cs.autoCommitInterval > 0 {
+		args["auto_commit_interval_ms"] = strconv.FormatInt(int64(cs.autoCommitInterval/time.Millisecond), 10)
+	}
+	if u := cs.c.h.UserInfo; u != nil {
+		args["user"] = u.Username
+		args["password"] = u.Password
+	}
+	if _, _, err := cs.roundTrip(ctx, "subscribe", args); err != nil {
+		if cs.conn != nil {
+			cs.conn.Close()
+			cs.conn = nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Poll waits at most timeout for a message, it returns nil without error when there is no message.
+func (cs *Consumer) Poll(ctx context.Context, timeout time.Duration) (*Message, error) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := cs.subscribe(ctx); err != nil {
+		return nil, err
+	}
+	res, _, err := cs.roundTrip(ctx, "poll", map[string]interface{}{"blocking_time": int64(timeout / time.Millisecond)})
+	if err != nil {
+		return nil, err
+	}
+	if !res.HaveMessage {
+		return nil, nil
+	}
+	m := &Message{Topic: res.Topic, Database: res.Database, VGroupID: res.VGroupID, Offset: res.Offset, id: res.MessageID}
+	for {
+		res, _, err := cs.roundTrip(ctx, "fetch", map[string]interface{}{"message_id": m.id})
+		if err != nil {
+			return nil, err
+		}
+		if res.Completed {
+			return m, nil
+		}
+		_, block, err := cs.roundTrip(ctx, "fetch_block", map[string]interface{}{"message_id": m.id})
+		if err != nil {
+			return nil, err
+		}
+		rows, err := parseRawBlock(block, res.Precision)
+		if err != nil {
+			return nil, err
+		}
+		r := &QueryResult{Data: make([]map[string]interface{}, 0, len(rows)), Precision: wsPrecision(res.Precision)}
+		for i, name := range res.FieldsNames {
+			column := ColumnMeta{Name: name}
+			if i < len(res.FieldsTypes) {
+				column.Type = res.FieldsTypes[i]
+			}
+			if i < len(res.FieldsLengths) {
+				column.Length = res.FieldsLengths[i]
+			}
+			r.Columns = append(r.Columns, column)
+		}
+		for _, row := range rows {
+			value := make(map[string]interface{}, len(row))
+			for i, v := range row {
+				if i < len(r.Columns) {
+					value[r.Columns[i].Name] = restValue(v, r.Precision)
+				}
+			}
+			r.Data = append(r.Data, value)
+		}
+		r.Rows = len(r.Data)
+		m.Blocks = append(m.Blocks, MessageBlock{Table: res.TableName, Result: r})
+	}
+}
+
+// Commit commits offset of message, so the group continues after it.
+func (cs *Consumer) Commit(ctx context.Context, m *Message) error {
+	if m == nil {
+		return fmt.Errorf("%w: commit needs a message", ErrInvalidStatement)
+	}
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := cs.subscribe(ctx); err != nil {
+		return err
+	}
+	_, _, err := cs.roundTrip(ctx, "commit", map[string]interface{}{"message_id": m.id})
+	return err
+}
+
+// Seek moves the consumer to offset of a vgroup, offsets can be found by Assignment.
+func (cs *Consumer) Seek(ctx context.Context, topic string, vgroupID int, offset int64) error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := cs.subscribe(ctx); err != nil {
+		return err
+	}
+	_, _, err := cs.roundTrip(ctx, "seek", map[string]interface{}{"topic": topic, "vgroup_id": vgroupID, "offset": offset})
+	return err
+}
+
+// Assignment returns vgroups of topic assigned to the consumer
+func (cs *Consumer) Assignment(ctx context.Context, topic string) ([]Assignment, error) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if err := cs.subscribe(ctx); err != nil {
+		return nil, err
+	}
+	res, _, err := cs.roundTrip(ctx, "assignment", map[string]interface{}{"topic": topic})
+	if err != nil {
+		return nil, err
+	}
+	return res.Assignment, nil
+}
+
+// Close unsubscribes and closes the connection
+func (cs *Consumer) Close() error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if cs.conn == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cs.roundTrip(ctx, "unsubscribe", map[string]interface{}{})
+	if cs.conn == nil {
+		return nil
+	}
+	err := cs.conn.Close()
+	cs.conn = nil
+	return err
+}
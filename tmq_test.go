@@ -0,0 +1,260 @@
+package tdquery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type tmqBlock struct {
+	table string
+	names []string
+	types []ColumnType
+	rows  [][]interface{}
+}
+
+type tmqMessage struct {
+	topic    string
+	database string
+	vgroupID int
+	offset   int64
+	blocks   []tmqBlock
+}
+
+// tmqStandIn is a stand-in of `/rest/tmq` of taosAdapter, a nil message is a poll without message
+type tmqStandIn struct {
+	lock      sync.Mutex
+	messages  []*tmqMessage
+	subscribe map[string]interface{}
+	actions   []string
+	committed []uint64
+	seeks     []map[string]interface{}
+}
+
+func (s *tmqStandIn) serve(conn *websocket.Conn) {
+	var current *tmqMessage
+	messageID := uint64(0)
+	next := 0
+	for {
+		req := &struct {
+			Action string                 `json:"action"`
+			Args   map[string]interface{} `json:"args"`
+		}{}
+		if err := conn.ReadJSON(req); err != nil {
+			return
+		}
+		reqID, _ := req.Args["req_id"].(float64)
+		id, _ := req.Args["message_id"].(float64)
+		res := map[string]interface{}{"code": 0, "action": req.Action, "req_id": uint64(reqID)}
+		s.lock.Lock()
+		s.actions = append(s.actions, req.Action)
+		switch req.Action {
+		case "subscribe":
+			s.subscribe = req.Args
+		case "poll":
+			// a response of another request is skipped by the consumer
+			conn.WriteJSON(map[string]interface{}{"code": 0, "action": "poll", "req_id": uint64(reqID) + 100})
+			var m *tmqMessage
+			if len(s.messages) > 0 {
+				m, s.messages = s.messages[0], s.messages[1:]
+			}
+			if m == nil {
+				break
+			}
+			messageID++
+			current, next = m, 0
+			res["have_message"] = true
+			res["topic"] = m.topic
+			res["database"] = m.database
+			res["vgroup_id"] = m.vgroupID
+			res["offset"] = m.offset
+			res["message_id"] = messageID
+		case "fetch":
+			if current == nil || uint64(id) != messageID {
+				res["code"] = 0xffff
+				res["message"] = "message not found"
+				break
+			}
+			if next == len(current.blocks) {
+				res["completed"] = true
+				break
+			}
+			block := current.blocks[next]
+			res["table_name"] = block.table
+			res["rows"] = len(block.rows)
+			res["fields_names"] = block.names
+			res["fields_types"] = block.types
+			res["precision"] = 0
+		case "fetch_block":
+			block := current.blocks[next]
+			next++
+			data := appendUint64(make([]byte, 8), uint64(reqID))
+			data = appendUint64(data, messageID)
+			data = append(data, encodeRawBlock(block.types, block.rows, 0)...)
+			s.lock.Unlock()
+			if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+				return
+			}
+			continue
+		case "commit":
+			if id == 0 || uint64(id) > messageID {
+				res["code"] = 0x4000
+				res["message"] = "invalid message"
+				break
+			}
+			s.committed = append(s.committed, uint64(id))
+		case "assignment":
+			res["assignment"] = []Assignment{{VGroupID: 2, Offset: 10, Begin: 0, End: 20}}
+		case "seek":
+			s.seeks = append(s.seeks, req.Args)
+		}
+		s.lock.Unlock()
+		if err := conn.WriteJSON(res); err != nil {
+			return
+		}
+	}
+}
+
+type tmqMeter struct {
+	Ts      time.Time `mapstructure:"ts"`
+	Current *float64  `mapstructure:"current"`
+	Table   string    `mapstructure:"tbname"`
+}
+
+func TestConsumer(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	names := []string{"ts", "current"}
+	types := []ColumnType{ColumnTypeTimestamp, ColumnTypeDouble}
+	tmq := &tmqStandIn{messages: []*tmqMessage{
+		nil,
+		{
+			topic: "meters_topic", database: "power", vgroupID: 2, offset: 11,
+			blocks: []tmqBlock{
+				{table: "d1", names: names, types: types, rows: [][]interface{}{{ts, 10.5}, {ts.Add(time.Second), nil}}},
+				{table: "d2", names: names, types: types, rows: [][]interface{}{{ts, 1.0}}},
+			},
+		},
+	}}
+	s := newWSStandIn(t, map[string]*standInResult{"show dnodes": dnodes3x})
+	s.tmq = tmq.serve
+	c := s.client(t, 1)
+	ctx := context.Background()
+	consumer := c.NewConsumer("group1", "meters_topic").ClientID("c1").OffsetReset("earliest")
+	defer consumer.Close()
+
+	m, err := consumer.Poll(ctx, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != nil {
+		t.Fatalf("unexpected message %+v", m)
+	}
+	tmq.lock.Lock()
+	subscribe := tmq.subscribe
+	tmq.lock.Unlock()
+	want := map[string]interface{}{
+		"group_id": "group1", "topics": []interface{}{"meters_topic"}, "client_id": "c1", "offset_rest": "earliest",
+		"auto_commit": "false", "with_table_name": "true", "req_id": float64(1),
+	}
+	if !reflect.DeepEqual(subscribe, want) {
+		t.Errorf("subscribe args = %v, want %v", subscribe, want)
+	}
+
+	m, err = consumer.Poll(ctx, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.Topic != "meters_topic" || m.Database != "power" || m.VGroupID != 2 || m.Offset != 11 || len(m.Blocks) != 2 {
+		t.Fatalf("unexpected message %+v", m)
+	}
+	if b := m.Blocks[0]; b.Table != "d1" || b.Result.Rows != 2 || len(b.Result.Columns) != 2 || b.Result.Columns[0].Type != ColumnTypeTimestamp {
+		t.Errorf("unexpected first block %+v", b.Result)
+	}
+	var meters []tmqMeter
+	if err := m.GetResult(&meters); err != nil {
+		t.Fatal(err)
+	}
+	if len(meters) != 3 {
+		t.Fatalf("unexpected rows %+v", meters)
+	}
+	if r := meters[0]; !r.Ts.Equal(ts) || r.Current == nil || *r.Current != 10.5 || r.Table != "d1" {
+		t.Errorf("unexpected first row %+v", r)
+	}
+	if r := meters[1]; !r.Ts.Equal(ts.Add(time.Second)) || r.Current != nil || r.Table != "d1" {
+		t.Errorf("unexpected second row %+v", r)
+	}
+	if r := meters[2]; r.Current == nil || *r.Current != 1 || r.Table != "d2" {
+		t.Errorf("unexpected third row %+v", r)
+	}
+
+	if err := consumer.Commit(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	var tdErr *TDEngineError
+	if err := consumer.Commit(ctx, &Message{id: 9}); !errors.As(err, &tdErr) || tdErr.Code != 0x4000 {
+		t.Errorf("commit of unknown message: %v", err)
+	}
+	if err := consumer.Commit(ctx, nil); !errors.Is(err, ErrInvalidStatement) {
+		t.Errorf("commit of nil message: %v", err)
+	}
+	assignment, err := consumer.Assignment(ctx, "meters_topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assignment) != 1 || assignment[0] != (Assignment{VGroupID: 2, Offset: 10, Begin: 0, End: 20}) {
+		t.Errorf("unexpected assignment %+v", assignment)
+	}
+	if err := consumer.Seek(ctx, "meters_topic", 2, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := consumer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tmq.lock.Lock()
+	defer tmq.lock.Unlock()
+	if !reflect.DeepEqual(tmq.committed, []uint64{1}) {
+		t.Errorf("committed %v", tmq.committed)
+	}
+	if len(tmq.seeks) != 1 || tmq.seeks[0]["topic"] != "meters_topic" || tmq.seeks[0]["vgroup_id"] != float64(2) || tmq.seeks[0]["offset"] != float64(5) {
+		t.Errorf("unexpected seek %v", tmq.seeks)
+	}
+	actions := []string{"subscribe", "poll", "poll", "fetch", "fetch_block", "fetch", "fetch_block", "fetch", "commit", "commit", "assignment", "seek", "unsubscribe"}
+	if !reflect.DeepEqual(tmq.actions, actions) {
+		t.Errorf("actions = %v\nwant %v", tmq.actions, actions)
+	}
+}
+
+func TestConsumerResubscribe(t *testing.T) {
+	tmq := &tmqStandIn{}
+	s := newWSStandIn(t, map[string]*standInResult{"show dnodes": dnodes3x})
+	s.tmq = tmq.serve
+	c := s.client(t, 1)
+	consumer := c.NewConsumer("group1", "meters_topic")
+	defer consumer.Close()
+	if _, err := consumer.Poll(context.Background(), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// the connection is dropped on network errors and subscribed again by next call
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := consumer.Poll(ctx, time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Poll() after deadline: %v", err)
+	}
+	if _, err := consumer.Poll(context.Background(), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	tmq.lock.Lock()
+	defer tmq.lock.Unlock()
+	if n := len(tmq.actions); n < 2 || tmq.actions[n-2] != "subscribe" || tmq.actions[n-1] != "poll" {
+		t.Errorf("consumer is not subscribed again: %v", tmq.actions)
+	}
+	if _, err := (c.NewConsumer("", "t")).Poll(context.Background(), time.Millisecond); !errors.Is(err, ErrInvalidStatement) {
+		t.Errorf("consumer without group: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CreateTopicBuilder builds `CREATE TOPIC` for data subscription, TDengine 3.x only.
+// A topic subscribes a query, a super table or a whole database.
+type CreateTopicBuilder struct {
+	c           *Client
+	name        string
+	ifNotExists bool
+	withMeta    bool
+	query       *SelectQueryBuilder
+	database    string
+	stable      string
+	// source is the database subscribed by AsDatabase
+	source string
+}
+
+func (c *Client) NewCreateTopic(name string) *CreateTopicBuilder {
+	return &CreateTopicBuilder{c: c, name: name, database: c.defaultDatabase()}
+}
+
+func (b *CreateTopicBuilder) IfNotExists() *CreateTopicBuilder {
+	b.ifNotExists = true
+	return b
+}
+
+// AsSelect subscribes rows of query, e.g. `CREATE TOPIC t AS SELECT ts, current FROM power.meters WHERE voltage > 200`
+func (b *CreateTopicBuilder) AsSelect(query *SelectQueryBuilder) *CreateTopicBuilder {
+	b.query, b.stable, b.source = query, "", ""
+	return b
+}
+
+// AsSTable subscribes all columns and tags of a super table in database of UseDatabase
+func (b *CreateTopicBuilder) AsSTable(stable string) *CreateTopicBuilder {
+	b.query, b.stable, b.source = nil, stable, ""
+	return b
+}
+
+// AsDatabase subscribes all tables of db, the database of client is not subscribed unless it is passed
+func (b *CreateTopicBuilder) AsDatabase(db string) *CreateTopicBuilder {
+	b.query, b.stable, b.source = nil, "", db
+	return b
+}
+
+// UseDatabase sets database of the super table for AsSTable
+func (b *CreateTopicBuilder) UseDatabase(db string) *CreateTopicBuilder {
+	b.database = db
+	return b
+}
+
+// WithMeta also subscribes meta data like table creation, it works with AsSTable and AsDatabase
+func (b *CreateTopicBuilder) WithMeta() *CreateTopicBuilder {
+	b.withMeta = true
+	return b
+}
+
+// BuildWithParams generates sql with `?` placeholders of the subscribed query
+func (b *CreateTopicBuilder) BuildWithParams() (string, []interface{}, error) {
+	if b.name == "" {
+		return "", nil, fmt.Errorf("%w, empty topic name", ErrInvalidDDL)
+	}
+	builder := &strings.Builder{}
+	builder.WriteString("CREATE TOPIC ")
+	if b.ifNotExists {
+		builder.WriteString("IF NOT EXISTS ")
+	}
+	builder.WriteString(b.name)
+	switch {
+	case b.query != nil:
+		if b.withMeta {
+			return "", nil, fmt.Errorf("%w, topic %s of a query can not subscribe meta", ErrInvalidDDL, b.name)
+		}
+		sql, params, err := b.query.BuildWithParams()
+		if err != nil {
+			return "", nil, err
+		}
+		builder.WriteString(" AS ")
+		builder.WriteString(sql)
+		return builder.String(), params, nil
+	case b.stable != "":
+		if b.withMeta {
+			builder.WriteString(" WITH META")
+		}
+		builder.WriteString(" AS STABLE ")
+		builder.WriteString(qualifiedName(b.database, b.stable))
+	case b.source != "":
+		if b.withMeta {
+			builder.WriteString(" WITH META")
+		}
+		builder.WriteString(" AS DATABASE ")
+		builder.WriteString(b.source)
+	default:
+		return "", nil, fmt.Errorf("%w, topic %s subscribes nothing", ErrInvalidDDL, b.name)
+	}
+	return builder.String(), nil, nil
+}
+
+func (b *CreateTopicBuilder) Build() (string, error) {
+	sql, _, err := b.BuildWithParams()
+	return sql, err
+}
+
+func (b *CreateTopicBuilder) Exec(ctx context.Context) error {
+	sql, params, err := b.BuildWithParams()
+	if err != nil {
+		return err
+	}
+	return b.c.Exec(ctx, sql, params...)
+}
+
+// NewDropTopic drops a topic, it fails when the topic has consumers
+func (c *Client) NewDropTopic(name string) *DropBuilder {
+	return &DropBuilder{c: c, kind: "TOPIC", names: []string{name}}
+}
@@ -0,0 +1,81 @@
+package tdquery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCreateTopic(t *testing.T) {
+	c := NewClient(WithDatabase("power"))
+	query := c.NewSelectQueryBuilder().Select(Select{ColumnName: "ts"}, Select{ColumnName: "current"}).FromSTable("meters").
+		Where(NewCondition("voltage", ">", 200))
+	cases := []struct {
+		name string
+		b    sqlBuilder
+		sql  string
+	}{
+		{"query", c.NewCreateTopic("t").IfNotExists().AsSelect(query),
+			"CREATE TOPIC IF NOT EXISTS t AS SELECT ts, current FROM power.meters WHERE voltage > ?"},
+		{"super table", c.NewCreateTopic("t").AsSTable("meters"), "CREATE TOPIC t AS STABLE power.meters"},
+		{"super table of database", c.NewCreateTopic("t").UseDatabase("test").WithMeta().AsSTable("meters"),
+			"CREATE TOPIC t WITH META AS STABLE test.meters"},
+		{"database", c.NewCreateTopic("t").AsDatabase("test"), "CREATE TOPIC t AS DATABASE test"},
+		{"database of client", c.NewCreateTopic("t").WithMeta().AsDatabase("power"), "CREATE TOPIC t WITH META AS DATABASE power"},
+		{"last source wins", c.NewCreateTopic("t").AsDatabase("test").AsSTable("meters"), "CREATE TOPIC t AS STABLE power.meters"},
+		{"database after super table", c.NewCreateTopic("t").AsSTable("meters").AsDatabase("test"), "CREATE TOPIC t AS DATABASE test"},
+	}
+	for _, tc := range cases {
+		sql, err := tc.b.Build()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+	}
+
+	_, params, err := c.NewCreateTopic("t").AsSelect(query).BuildWithParams()
+	if err != nil || !reflect.DeepEqual(params, []interface{}{200}) {
+		t.Errorf("query params = %v, %v", params, err)
+	}
+
+	invalid := []struct {
+		name string
+		b    sqlBuilder
+	}{
+		{"empty name", c.NewCreateTopic("").AsDatabase("power")},
+		// the database of client is not subscribed by default
+		{"no source", c.NewCreateTopic("t")},
+		{"no source with meta", c.NewCreateTopic("t").WithMeta()},
+		{"empty database", c.NewCreateTopic("t").AsDatabase("")},
+		{"query with meta", c.NewCreateTopic("t").WithMeta().AsSelect(query)},
+	}
+	for _, tc := range invalid {
+		if sql, err := tc.b.Build(); !errors.Is(err, ErrInvalidDDL) {
+			t.Errorf("%s: %s, %v", tc.name, sql, err)
+		}
+	}
+	if _, err := c.NewCreateTopic("t").AsSelect(c.NewSelectQueryBuilder()).Build(); !errors.Is(err, ErrEmptySelect) {
+		t.Errorf("invalid query: %v", err)
+	}
+
+	if sql, err := c.NewDropTopic("t").IfExists().Build(); err != nil || sql != "DROP TOPIC IF EXISTS t" {
+		t.Errorf("drop topic = %s, %v", sql, err)
+	}
+}
+
+func TestCreateTopicExec(t *testing.T) {
+	s := newWSStandIn(t, map[string]*standInResult{
+		"show dnodes": dnodes3x,
+		"CREATE TOPIC t AS SELECT ts, current FROM power.meters WHERE voltage > 200": {IsUpdate: true},
+	})
+	c := s.client(t, 1)
+	query := c.NewSelectQueryBuilder().Select(Select{ColumnName: "ts"}, Select{ColumnName: "current"}).UseDatabase("power").FromSTable("meters").
+		Where(NewCondition("voltage", ">", 200))
+	if err := c.NewCreateTopic("t").AsSelect(query).Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,160 @@
+package tdquery
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnionQueryBuilder composes select queries with UNION ALL
+type UnionQueryBuilder struct {
+	c       *Client
+	selects []*SelectQueryBuilder
+	orderBy []orderBy
+	limit   int
+	offset  int
+}
+
+// Union composes select queries into `SELECT ... UNION ALL SELECT ...`.
+// ORDER BY and LIMIT of the union builder apply to the whole result, a select query with its own
+// ORDER BY, LIMIT or SLIMIT is parenthesized so they apply to that query only.
+func (c *Client) Union(builders ...*SelectQueryBuilder) *UnionQueryBuilder {
+	return &UnionQueryBuilder{
+		c:       c,
+		selects: builders,
+	}
+}
+
+// Clone returns an independent copy of the builder, select queries are cloned too.
+func (b *UnionQueryBuilder) Clone() *UnionQueryBuilder {
+	selects := make([]*SelectQueryBuilder, 0, len(b.selects))
+	for _, s := range b.selects {
+		selects = append(selects, s.Clone())
+	}
+	return &UnionQueryBuilder{
+		c:       b.c,
+		selects: selects,
+		orderBy: append([]orderBy(nil), b.orderBy...),
+		limit:   b.limit,
+		offset:  b.offset,
+	}
+}
+
+// UnionAll appends more select queries
+func (b *UnionQueryBuilder) UnionAll(builders ...*SelectQueryBuilder) *UnionQueryBuilder {
+	b.selects = append(b.selects, builders...)
+	return b
+}
+
+// OrderBy orders the whole result like SelectQueryBuilder.OrderBy, it replaces previous orderings.
+func (b *UnionQueryBuilder) OrderBy(columns []string, order Order) *UnionQueryBuilder {
+	b.orderBy = listedOrderBy(columns, order)
+	return b
+}
+
+func (b *UnionQueryBuilder) AddOrderBy(expr string, order Order) *UnionQueryBuilder {
+	b.orderBy = append(b.orderBy, orderBy{expr: expr, order: order})
+	return b
+}
+
+func (b *UnionQueryBuilder) Limit(limit int) *UnionQueryBuilder {
+	b.limit = limit
+	return b
+}
+
+func (b *UnionQueryBuilder) Offset(offset int) *UnionQueryBuilder {
+	b.offset = offset
+	return b
+}
+
+func (b *UnionQueryBuilder) Build() (string, error) {
+	sql, _, err := b.BuildWithParams()
+	return sql, err
+}
+
+// BuildWithParams generates sql with `?` placeholders and the params of all select queries in order.
+func (b *UnionQueryBuilder) BuildWithParams() (string, []interface{}, error) {
+	builder := &strings.Builder{}
+	params := make([]interface{}, 0)
+	if err := b.buildSQL(builder, &params); err != nil {
+		return "", nil, err
+	}
+	return builder.String(), params, nil
+}
+
+func (b *UnionQueryBuilder) buildSQL(builder *strings.Builder, params *[]interface{}) error {
+	if len(b.selects) < 2 {
+		return ErrEmptyUnion
+	}
+	if err := b.checkColumns(); err != nil {
+		return err
+	}
+	for i, s := range b.selects {
+		sql, selectParams, err := s.BuildWithParams()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			builder.WriteString(" UNION ALL ")
+		}
+		if s.hasOwnOrderOrLimit() {
+			sql = "(" + sql + ")"
+		}
+		builder.WriteString(sql)
+		*params = append(*params, selectParams...)
+	}
+	appendOrderBy(builder, b.orderBy)
+	if b.limit > 0 {
+		builder.WriteString(" LIMIT ")
+		builder.WriteString(strconv.Itoa(b.limit))
+		if b.offset > 0 {
+			builder.WriteString(" OFFSET ")
+			builder.WriteString(strconv.Itoa(b.offset))
+		}
+	}
+	return nil
+}
+
+// hasOwnOrderOrLimit reports whether s ends with ORDER BY, LIMIT or SLIMIT which would apply to the whole union.
+func (s *SelectQueryBuilder) hasOwnOrderOrLimit() bool {
+	return len(s.orderBy) > 0 || s.limit > 0 || s.offset > 0 || s.slimit > 0 || s.soffset > 0
+}
+
+// checkColumns makes sure all select queries have the same column number.
+// Queries selecting `*` can not be checked locally and are skipped.
+func (b *UnionQueryBuilder) checkColumns() error {
+	expected := -1
+	for i, s := range b.selects {
+		if s.hasWildcard() {
+			continue
+		}
+		if expected == -1 {
+			expected = len(s.selects)
+			continue
+		}
+		if len(s.selects) != expected {
+			return fmt.Errorf("%w, query %d selects %d columns, expected %d", ErrUnionColumnMismatch, i, len(s.selects), expected)
+		}
+	}
+	return nil
+}
+
+func (b *UnionQueryBuilder) GetRaw(ctx context.Context) (*QueryResult, error) {
+	sql, params, err := b.BuildWithParams()
+	if err != nil {
+		return nil, err
+	}
+	return b.c.Query(ctx, sql, params...)
+}
+
+func (b *UnionQueryBuilder) GetResult(ctx context.Context, v interface{}) error {
+	raw, err := b.GetRaw(ctx)
+	if err != nil {
+		return err
+	}
+	if raw.Code != 0 {
+		return &TDEngineError{Code: raw.Code, Message: raw.Message}
+	}
+	return decodeResult(raw.Data, raw.Precision, v)
+}
@@ -0,0 +1,87 @@
+package tdquery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnion(t *testing.T) {
+	c := NewClient()
+	cases := []struct {
+		name   string
+		build  func() *UnionQueryBuilder
+		sql    string
+		params []interface{}
+	}{
+		{
+			name: "params in order of queries",
+			build: func() *UnionQueryBuilder {
+				return c.Union(
+					c.NewSelectQueryBuilder().SelectColumn("v").FromTables("a").Where(NewCondition("x", ">", 1), NewCondition("y", "=", "a")),
+					c.NewSelectQueryBuilder().SelectColumn("v").FromTables("b").Where(NewCondition("x", "<", 2)),
+				).UnionAll(c.NewSelectQueryBuilder().SelectColumn("v").FromTables("c").Where(NewCondition("y", "IN", []string{"c"})))
+			},
+			sql:    "SELECT v FROM a WHERE x > ? AND y = ? UNION ALL SELECT v FROM b WHERE x < ? UNION ALL SELECT v FROM c WHERE y IN ?",
+			params: []interface{}{1, "a", 2, []string{"c"}},
+		},
+		{
+			name: "limit of the whole result",
+			build: func() *UnionQueryBuilder {
+				return c.Union(c.NewSelectQueryBuilder().SelectColumn("v").FromTables("a"), c.NewSelectQueryBuilder().SelectColumn("v").FromTables("b")).
+					AddOrderBy("v", DESC).Limit(10).Offset(20)
+			},
+			sql:    "SELECT v FROM a UNION ALL SELECT v FROM b ORDER BY v DESC LIMIT 10 OFFSET 20",
+			params: []interface{}{},
+		},
+		{
+			name: "order and limit of a query",
+			build: func() *UnionQueryBuilder {
+				return c.Union(
+					c.NewSelectQueryBuilder().SelectColumn("v").FromTables("a").Desc().Limit(1),
+					c.NewSelectQueryBuilder().SelectColumn("v").FromTables("b").Where(NewCondition("v", ">", 0)),
+					c.NewSelectQueryBuilder().SelectColumn("v").FromSTable("s").PartitionBy("tbname").SLimit(2),
+				).Limit(5)
+			},
+			sql: "(SELECT v FROM a ORDER BY _c0 DESC LIMIT 1) UNION ALL SELECT v FROM b WHERE v > ?" +
+				" UNION ALL (SELECT v FROM s PARTITION BY tbname SLIMIT 2) LIMIT 5",
+			params: []interface{}{0},
+		},
+	}
+	for _, tc := range cases {
+		sql, params, err := tc.build().BuildWithParams()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if sql != tc.sql {
+			t.Errorf("%s: sql = %s\nwant %s", tc.name, sql, tc.sql)
+		}
+		if !reflect.DeepEqual(params, tc.params) {
+			t.Errorf("%s: params = %v, want %v", tc.name, params, tc.params)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		b    *UnionQueryBuilder
+		err  error
+	}{
+		{"one query", c.Union(c.NewSelectQueryBuilder().SelectColumn("v").FromTables("a")), ErrEmptyUnion},
+		{"column mismatch", c.Union(
+			c.NewSelectQueryBuilder().SelectColumn("v").FromTables("a"),
+			c.NewSelectQueryBuilder().SelectColumn("v").SelectColumn("w").FromTables("b"),
+		), ErrUnionColumnMismatch},
+	}
+	for _, tc := range invalid {
+		if _, err := tc.b.Build(); !errors.Is(err, tc.err) {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+
+	// wildcard queries can not be checked locally
+	if _, err := c.Union(c.NewSelectQueryBuilder().SelectAll().FromTables("a"),
+		c.NewSelectQueryBuilder().SelectColumn("v").FromTables("b")).Build(); err != nil {
+		t.Error(err)
+	}
+}
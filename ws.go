@@ -0,0 +1,603 @@
+package tdquery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const wsQueryURL = "/rest/ws"
+
+var errWSClosed = errors.New("tdquery: websocket connection closed")
+
+type wsRequest struct {
+	Action string                 `json:"action"`
+	Args   map[string]interface{} `json:"args"`
+}
+
+// wsResponse is the union of responses of conn, query and fetch actions
+type wsResponse struct {
+	Code          int          `json:"code"`
+	Message       string       `json:"message"`
+	Action        string       `json:"action"`
+	ReqID         uint64       `json:"req_id"`
+	ID            uint64       `json:"id"`
+	IsUpdate      bool         `json:"is_update"`
+	AffectedRows  int          `json:"affected_rows"`
+	FieldsNames   []string     `json:"fields_names"`
+	FieldsTypes   []ColumnType `json:"fields_types"`
+	FieldsLengths []int        `json:"fields_lengths"`
+	Precision     int          `json:"precision"`
+	Completed     bool         `json:"completed"`
+	Rows          int          `json:"rows"`
+}
+
+type wsMessage struct {
+	res   *wsResponse
+	block []byte
+	err   error
+}
+
+type wsWaiter struct {
+	ch       chan wsMessage
+	reqID    uint64
+	resultID uint64
+	block    bool
+}
+
+// wsConn is a WebSocket connection shared by queries, responses are matched by req_id,
+// blocks of fetch_block are matched by result id.
+type wsConn struct {
+	conn     *websocket.Conn
+	write    sync.Mutex
+	lock     sync.Mutex
+	reqID    uint64
+	inflight int64
+	pending  map[uint64]*wsWaiter
+	blocks   map[uint64]*wsWaiter
+	err      error
+	done     chan struct{}
+}
+
+func dialWS(ctx context.Context, url string, args map[string]interface{}) (*wsConn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	wc := &wsConn{
+		conn:    conn,
+		pending: make(map[uint64]*wsWaiter),
+		blocks:  make(map[uint64]*wsWaiter),
+		done:    make(chan struct{}),
+	}
+	go wc.readLoop()
+	res, err := wc.call(ctx, "conn", args)
+	if err != nil {
+		wc.close()
+		return nil, err
+	}
+	if res.Code != 0 {
+		wc.close()
+		return nil, &TDEngineError{Code: res.Code, Message: res.Message}
+	}
+	return wc, nil
+}
+
+func (wc *wsConn) readLoop() {
+	var err error
+	for {
+		var kind int
+		var data []byte
+		kind, data, err = wc.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if kind == websocket.BinaryMessage {
+			// timing(8) + result id(8) + raw block
+			if len(data) < 16 {
+				continue
+			}
+			wc.deliver(0, binary.LittleEndian.Uint64(data[8:16]), wsMessage{block: data[16:]})
+			continue
+		}
+		res := &wsResponse{}
+		if err := json.Unmarshal(data, res); err != nil {
+			continue
+		}
+		wc.deliver(res.ReqID, 0, wsMessage{res: res})
+	}
+	wc.lock.Lock()
+	wc.err = err
+	for _, w := range wc.pending {
+		w.ch <- wsMessage{err: fmt.Errorf("%w: %v", errWSClosed, err)}
+	}
+	wc.pending = make(map[uint64]*wsWaiter)
+	wc.blocks = make(map[uint64]*wsWaiter)
+	wc.lock.Unlock()
+	close(wc.done)
+}
+
+func (wc *wsConn) deliver(reqID uint64, resultID uint64, msg wsMessage) {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+	var w *wsWaiter
+	if msg.block != nil {
+		w = wc.blocks[resultID]
+	} else {
+		w = wc.pending[reqID]
+	}
+	if w == nil {
+		return
+	}
+	wc.unregister(w)
+	w.ch <- msg
+}
+
+// unregister must be called with lock held
+func (wc *wsConn) unregister(w *wsWaiter) {
+	delete(wc.pending, w.reqID)
+	if w.block {
+		delete(wc.blocks, w.resultID)
+	}
+}
+
+func (wc *wsConn) register(resultID uint64, block bool) (*wsWaiter, error) {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+	if wc.err != nil {
+		return nil, fmt.Errorf("%w: %v", errWSClosed, wc.err)
+	}
+	w := &wsWaiter{
+		ch:       make(chan wsMessage, 1),
+		reqID:    atomic.AddUint64(&wc.reqID, 1),
+		resultID: resultID,
+		block:    block,
+	}
+	wc.pending[w.reqID] = w
+	if block {
+		wc.blocks[resultID] = w
+	}
+	return w, nil
+}
+
+func (wc *wsConn) send(ctx context.Context, action string, args map[string]interface{}) error {
+	data, err := json.Marshal(&wsRequest{Action: action, Args: args})
+	if err != nil {
+		return err
+	}
+	wc.write.Lock()
+	defer wc.write.Unlock()
+	deadline, _ := ctx.Deadline()
+	wc.conn.SetWriteDeadline(deadline)
+	return wc.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (wc *wsConn) wait(ctx context.Context, w *wsWaiter) (wsMessage, error) {
+	select {
+	case msg := <-w.ch:
+		return msg, msg.err
+	case <-ctx.Done():
+		wc.lock.Lock()
+		wc.unregister(w)
+		wc.lock.Unlock()
+		return wsMessage{}, ctx.Err()
+	}
+}
+
+// call sends an action and waits for its response
+func (wc *wsConn) call(ctx context.Context, action string, args map[string]interface{}) (*wsResponse, error) {
+	w, err := wc.register(0, false)
+	if err != nil {
+		return nil, err
+	}
+	args["req_id"] = w.reqID
+	if err := wc.send(ctx, action, args); err != nil {
+		wc.lock.Lock()
+		wc.unregister(w)
+		wc.lock.Unlock()
+		return nil, err
+	}
+	msg, err := wc.wait(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+	return msg.res, nil
+}
+
+// fetchBlock returns the raw block of result, errors are returned as json with req_id
+func (wc *wsConn) fetchBlock(ctx context.Context, id uint64) ([]byte, error) {
+	w, err := wc.register(id, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := wc.send(ctx, "fetch_block", map[string]interface{}{"req_id": w.reqID, "id": id}); err != nil {
+		wc.lock.Lock()
+		wc.unregister(w)
+		wc.lock.Unlock()
+		return nil, err
+	}
+	msg, err := wc.wait(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+	if msg.res != nil {
+		return nil, &TDEngineError{Code: msg.res.Code, Message: msg.res.Message}
+	}
+	return msg.block, nil
+}
+
+func (wc *wsConn) alive() bool {
+	select {
+	case <-wc.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (wc *wsConn) close() {
+	wc.conn.Close()
+}
+
+// wsPool keeps at most size connections for each broker
+type wsPool struct {
+	c     *Client
+	size  int
+	lock  sync.Mutex
+	conns map[string][]*wsConn
+}
+
+func newWSPool(c *Client, size int) *wsPool {
+	return &wsPool{c: c, size: size, conns: make(map[string][]*wsConn)}
+}
+
+// get returns the least busy connection of broker, a new connection is dialed until size is reached
+func (p *wsPool) get(ctx context.Context, broker string) (*wsConn, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	conns := p.conns[broker][:0]
+	for _, wc := range p.conns[broker] {
+		if wc.alive() {
+			conns = append(conns, wc)
+		}
+	}
+	p.conns[broker] = conns
+	var least *wsConn
+	for _, wc := range conns {
+		if least == nil || atomic.LoadInt64(&wc.inflight) < atomic.LoadInt64(&least.inflight) {
+			least = wc
+		}
+	}
+	if least != nil && (atomic.LoadInt64(&least.inflight) == 0 || len(conns) >= p.size) {
+		return least, nil
+	}
+	args := map[string]interface{}{}
+	if u := p.c.h.UserInfo; u != nil {
+		args["user"] = u.Username
+		args["password"] = u.Password
+	}
+	if p.c.useUrlDB {
+		args["db"] = p.c.database
+	}
+	wc, err := dialWS(ctx, fmt.Sprintf("ws://%s:%d%s", broker, p.c.port, wsQueryURL), args)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[broker] = append(conns, wc)
+	return wc, nil
+}
+
+func (p *wsPool) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, conns := range p.conns {
+		for _, wc := range conns {
+			wc.close()
+		}
+	}
+	p.conns = make(map[string][]*wsConn)
+}
+
+// wsCursor pages through blocks of a result
+type wsCursor struct {
+	conn      *wsConn
+	id        uint64
+	columns   []ColumnMeta
+	precision int
+	rows      [][]interface{}
+	completed bool
+	closed    bool
+}
+
+// wsPrecision converts precision of a WebSocket response, 0 is millisecond, 1 microsecond and 2 nanosecond
+func wsPrecision(p int) Precision {
+	switch p {
+	case 1:
+		return PrecisionMicrosecond
+	case 2:
+		return PrecisionNanosecond
+	}
+	return PrecisionMillisecond
+}
+
+// query runs sql, TDengine errors are returned in the response with a nil cursor
+func (p *wsPool) query(ctx context.Context, broker string, sql string) (*wsCursor, *wsResponse, error) {
+	wc, err := p.get(ctx, broker)
+	if err != nil {
+		return nil, nil, err
+	}
+	atomic.AddInt64(&wc.inflight, 1)
+	res, err := wc.call(ctx, "query", map[string]interface{}{"sql": sql})
+	if err != nil || res.Code != 0 {
+		atomic.AddInt64(&wc.inflight, -1)
+		return nil, res, err
+	}
+	cur := &wsCursor{conn: wc, id: res.ID, precision: res.Precision}
+	if res.IsUpdate {
+		cur.columns = []ColumnMeta{{Name: "affected_rows", Type: ColumnTypeInt, Length: 4}}
+		cur.rows = [][]interface{}{{int64(res.AffectedRows)}}
+		cur.completed = true
+		return cur, res, nil
+	}
+	cur.columns = make([]ColumnMeta, len(res.FieldsNames))
+	for i, name := range res.FieldsNames {
+		cur.columns[i] = ColumnMeta{Name: name}
+		if i < len(res.FieldsTypes) {
+			cur.columns[i].Type = res.FieldsTypes[i]
+		}
+		if i < len(res.FieldsLengths) {
+			cur.columns[i].Length = res.FieldsLengths[i]
+		}
+	}
+	return cur, res, nil
+}
+
+// next returns the next row typed like Rows.Values, it returns nil at the end of result
+func (cur *wsCursor) next(ctx context.Context) ([]interface{}, error) {
+	for len(cur.rows) == 0 {
+		if cur.completed || cur.closed {
+			cur.close()
+			return nil, nil
+		}
+		res, err := cur.conn.call(ctx, "fetch", map[string]interface{}{"id": cur.id})
+		if err != nil {
+			return nil, err
+		}
+		if res.Code != 0 {
+			return nil, &TDEngineError{Code: res.Code, Message: res.Message}
+		}
+		if res.Completed {
+			// result is freed by taosAdapter when completed
+			cur.completed = true
+			continue
+		}
+		block, err := cur.conn.fetchBlock(ctx, cur.id)
+		if err != nil {
+			return nil, err
+		}
+		if cur.rows, err = parseRawBlock(block, cur.precision); err != nil {
+			return nil, err
+		}
+	}
+	row := cur.rows[0]
+	cur.rows = cur.rows[1:]
+	return row, nil
+}
+
+// close frees result on server if it is not completed
+func (cur *wsCursor) close() {
+	if cur.closed {
+		return
+	}
+	cur.closed = true
+	atomic.AddInt64(&cur.conn.inflight, -1)
+	if !cur.completed {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		cur.conn.send(ctx, "free_result", map[string]interface{}{"req_id": 0, "id": cur.id})
+	}
+}
+
+func (c *Client) wsRequest(ctx context.Context, broker string, sql string) (*QueryResult, error) {
+	start := time.Now()
+	if timeout := c.h.GetClient().Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cur, res, err := c.ws.query(ctx, broker, sql)
+	if err != nil {
+		return nil, err
+	}
+	r := &QueryResult{SQL: sql, Data: make([]map[string]interface{}, 0), Precision: PrecisionMillisecond}
+	if res.Code != 0 {
+		r.Code = res.Code
+		r.Message = res.Message
+		r.Cost = int(time.Since(start) / time.Millisecond)
+		return r, nil
+	}
+	defer cur.close()
+	r.Columns = cur.columns
+	r.Precision = wsPrecision(cur.precision)
+	for {
+		row, err := cur.next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			break
+		}
+		// values are the same as /rest/sqlt
+		value := make(map[string]interface{}, len(row))
+		for i, v := range row {
+			if i < len(cur.columns) {
+				value[cur.columns[i].Name] = restValue(v, r.Precision)
+			}
+		}
+		r.Data = append(r.Data, value)
+	}
+	r.Rows = len(r.Data)
+	r.Cost = int(time.Since(start) / time.Millisecond)
+	return r, nil
+}
+
+// restValue converts a typed value to the value decoded from /rest/sqlt, numbers are float64
+// except timestamps, which are int64 in the precision of the result so microseconds and nanoseconds are kept.
+func restValue(v interface{}, precision Precision) interface{} {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case time.Time:
+		return x.UnixNano() / int64(precision.Unit())
+	}
+	return v
+}
+
+const rawBlockHeaderSize = 28
+
+// parseRawBlock decodes raw block of TDengine 3.x:
+// header(version, length, rows, cols, flag segment int32, group id uint64), then type(int8) and bytes(int32) of each column,
+// length(int32) of each column, then data of each column. Variable length columns start with offsets(int32) of rows,
+// fixed length columns start with a null bitmap.
+func parseRawBlock(block []byte, precision int) ([][]interface{}, error) {
+	offset := 0
+	take := func(n int) ([]byte, error) {
+		if n < 0 || offset+n > len(block) {
+			return nil, fmt.Errorf("tdquery: invalid raw block of %d bytes", len(block))
+		}
+		b := block[offset : offset+n]
+		offset += n
+		return b, nil
+	}
+	header, err := take(rawBlockHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	rows := int(int32(binary.LittleEndian.Uint32(header[8:])))
+	cols := int(int32(binary.LittleEndian.Uint32(header[12:])))
+	schema, err := take(cols * 5)
+	if err != nil {
+		return nil, err
+	}
+	lengths, err := take(cols * 4)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([][]interface{}, rows)
+	for i := range ret {
+		ret[i] = make([]interface{}, cols)
+	}
+	for c := 0; c < cols; c++ {
+		t := ColumnType(int8(schema[c*5]))
+		size := int(int32(binary.LittleEndian.Uint32(schema[c*5+1:])))
+		length := int(int32(binary.LittleEndian.Uint32(lengths[c*4:])))
+		if t.HasLength() || t == ColumnTypeJSON {
+			offsets, err := take(rows * 4)
+			if err != nil {
+				return nil, err
+			}
+			data, err := take(length)
+			if err != nil {
+				return nil, err
+			}
+			for r := 0; r < rows; r++ {
+				o := int(int32(binary.LittleEndian.Uint32(offsets[r*4:])))
+				if o < 0 {
+					continue
+				}
+				if o+2 > len(data) {
+					return nil, fmt.Errorf("tdquery: invalid raw block offset %d", o)
+				}
+				l := int(binary.LittleEndian.Uint16(data[o:]))
+				if o+2+l > len(data) {
+					return nil, fmt.Errorf("tdquery: invalid raw block offset %d", o)
+				}
+				v := data[o+2 : o+2+l]
+				if t == ColumnTypeNchar {
+					ret[r][c] = decodeUCS4(v)
+				} else {
+					ret[r][c] = string(v)
+				}
+			}
+			continue
+		}
+		bitmap, err := take((rows + 7) / 8)
+		if err != nil {
+			return nil, err
+		}
+		data, err := take(length)
+		if err != nil {
+			return nil, err
+		}
+		if size <= 0 || rows*size > len(data) {
+			return nil, fmt.Errorf("tdquery: invalid raw block column %d", c)
+		}
+		for r := 0; r < rows; r++ {
+			if bitmap[r>>3]&(1<<(7-uint(r&7))) != 0 {
+				continue
+			}
+			ret[r][c] = fixedValue(t, data[r*size:(r+1)*size], precision)
+		}
+	}
+	return ret, nil
+}
+
+func fixedValue(t ColumnType, b []byte, precision int) interface{} {
+	switch t {
+	case ColumnTypeBool:
+		return b[0] != 0
+	case ColumnTypeTinyInt:
+		return int64(int8(b[0]))
+	case ColumnTypeSmallInt:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	case ColumnTypeInt:
+		return int64(int32(binary.LittleEndian.Uint32(b)))
+	case ColumnTypeBigInt:
+		return int64(binary.LittleEndian.Uint64(b))
+	case ColumnTypeUTinyInt:
+		return uint64(b[0])
+	case ColumnTypeUSmallInt:
+		return uint64(binary.LittleEndian.Uint16(b))
+	case ColumnTypeUInt:
+		return uint64(binary.LittleEndian.Uint32(b))
+	case ColumnTypeUBigInt:
+		return binary.LittleEndian.Uint64(b)
+	case ColumnTypeFloat:
+		// keep the shortest decimal of float32, e.g. 0.1 instead of 0.10000000149011612
+		f := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+		return v
+	case ColumnTypeDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case ColumnTypeTimestamp:
+		ts := int64(binary.LittleEndian.Uint64(b))
+		switch precision {
+		case 1:
+			return time.Unix(0, ts*int64(time.Microsecond))
+		case 2:
+			return time.Unix(0, ts)
+		default:
+			return time.Unix(0, ts*int64(time.Millisecond))
+		}
+	}
+	return nil
+}
+
+// decodeUCS4 decodes NCHAR which is stored as UCS-4 in raw block
+func decodeUCS4(b []byte) string {
+	runes := make([]rune, 0, len(b)/4)
+	for i := 0; i+4 <= len(b); i += 4 {
+		runes = append(runes, rune(binary.LittleEndian.Uint32(b[i:])))
+	}
+	return string(runes)
+}
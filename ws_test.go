@@ -0,0 +1,495 @@
+package tdquery
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
+
+func fixedSize(t ColumnType) int {
+	switch t {
+	case ColumnTypeBool, ColumnTypeTinyInt, ColumnTypeUTinyInt:
+		return 1
+	case ColumnTypeSmallInt, ColumnTypeUSmallInt:
+		return 2
+	case ColumnTypeInt, ColumnTypeUInt, ColumnTypeFloat:
+		return 4
+	case ColumnTypeBigInt, ColumnTypeUBigInt, ColumnTypeDouble, ColumnTypeTimestamp:
+		return 8
+	}
+	return 0
+}
+
+// encodeRawBlock encodes rows like a raw block of TDengine 3.x, see parseRawBlock.
+// Values are nil, bool, int, float64, string or time.Time, which is encoded in precision of a WebSocket response.
+func encodeRawBlock(types []ColumnType, rows [][]interface{}, precision int) []byte {
+	var schema, lengths, columns []byte
+	for c, t := range types {
+		size := fixedSize(t)
+		schema = appendUint32(append(schema, byte(t)), uint32(size))
+		var data []byte
+		if size == 0 {
+			for _, row := range rows {
+				if row[c] == nil {
+					columns = appendUint32(columns, math.MaxUint32)
+					continue
+				}
+				columns = appendUint32(columns, uint32(len(data)))
+				var v []byte
+				if t == ColumnTypeNchar {
+					for _, r := range row[c].(string) {
+						v = appendUint32(v, uint32(r))
+					}
+				} else {
+					v = []byte(row[c].(string))
+				}
+				data = append(appendUint16(data, uint16(len(v))), v...)
+			}
+		} else {
+			bitmap := make([]byte, (len(rows)+7)/8)
+			for r, row := range rows {
+				var v uint64
+				switch x := row[c].(type) {
+				case nil:
+					bitmap[r>>3] |= 1 << (7 - uint(r&7))
+				case bool:
+					if x {
+						v = 1
+					}
+				case int:
+					v = uint64(x)
+				case float64:
+					v = math.Float64bits(x)
+				case time.Time:
+					v = uint64(x.UnixNano() / int64(wsPrecision(precision).Unit()))
+				}
+				var b []byte
+				b = appendUint64(b, v)
+				data = append(data, b[:size]...)
+			}
+			columns = append(columns, bitmap...)
+		}
+		lengths = appendUint32(lengths, uint32(len(data)))
+		columns = append(columns, data...)
+	}
+	block := make([]byte, rawBlockHeaderSize)
+	binary.LittleEndian.PutUint32(block[8:], uint32(len(rows)))
+	binary.LittleEndian.PutUint32(block[12:], uint32(len(types)))
+	block = append(append(append(block, schema...), lengths...), columns...)
+	binary.LittleEndian.PutUint32(block[4:], uint32(len(block)))
+	return block
+}
+
+// standInResult is the result of a sql served by stand-in servers, Code is set for errors
+type standInResult struct {
+	Code     int
+	Message  string
+	Names    []string
+	Types    []ColumnType
+	Blocks   [][][]interface{}
+	Affected int
+	IsUpdate bool
+	// Precision of timestamps, 0 is millisecond, 1 microsecond and 2 nanosecond
+	Precision int
+}
+
+// dnodes3x is `show dnodes` of TDengine 3.x, which has no role and names the end point `endpoint`
+var dnodes3x = &standInResult{
+	Names:  []string{"id", "endpoint", "vnodes", "status"},
+	Types:  []ColumnType{ColumnTypeSmallInt, ColumnTypeBinary, ColumnTypeSmallInt, ColumnTypeBinary},
+	Blocks: [][][]interface{}{{{1, "127.0.0.1:6030", 2, "ready"}}},
+}
+
+// wsStandIn is a stand-in of `/rest/ws` of taosAdapter, `/rest/tmq` is served by tmq if it is set
+type wsStandIn struct {
+	*httptest.Server
+	results map[string]*standInResult
+	tmq     func(conn *websocket.Conn)
+	// rest serves other paths when it is set, like the schemaless endpoints of taosAdapter
+	rest  func(w http.ResponseWriter, r *http.Request)
+	lock  sync.Mutex
+	sqls  []string
+	freed []uint64
+	conns int
+}
+
+func newWSStandIn(t *testing.T, results map[string]*standInResult) *wsStandIn {
+	s := &wsStandIn{results: results}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != wsQueryURL && (r.URL.Path != tmqURL || s.tmq == nil) {
+			if s.rest != nil {
+				s.rest(w, r)
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if r.URL.Path == tmqURL {
+			s.tmq(conn)
+			return
+		}
+		s.lock.Lock()
+		s.conns++
+		s.lock.Unlock()
+		s.serve(conn)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *wsStandIn) serve(conn *websocket.Conn) {
+	type cursor struct {
+		result *standInResult
+		next   int
+	}
+	cursors := make(map[uint64]*cursor)
+	id := uint64(0)
+	for {
+		req := &struct {
+			Action string                 `json:"action"`
+			Args   map[string]interface{} `json:"args"`
+		}{}
+		if err := conn.ReadJSON(req); err != nil {
+			return
+		}
+		reqID, _ := req.Args["req_id"].(float64)
+		resultID, _ := req.Args["id"].(float64)
+		res := map[string]interface{}{"code": 0, "action": req.Action, "req_id": uint64(reqID)}
+		switch req.Action {
+		case "query":
+			sql := req.Args["sql"].(string)
+			s.lock.Lock()
+			s.sqls = append(s.sqls, sql)
+			s.lock.Unlock()
+			r, ok := s.results[sql]
+			if !ok {
+				r = &standInResult{Code: 0x2662, Message: "Table does not exist"}
+			}
+			if r.Code != 0 {
+				res["code"] = r.Code
+				res["message"] = r.Message
+				break
+			}
+			id++
+			res["id"] = id
+			if r.IsUpdate {
+				res["is_update"] = true
+				res["affected_rows"] = r.Affected
+				break
+			}
+			lengths := make([]int, len(r.Types))
+			for i, t := range r.Types {
+				if lengths[i] = fixedSize(t); lengths[i] == 0 {
+					lengths[i] = 64
+				}
+			}
+			res["fields_names"] = r.Names
+			res["fields_types"] = r.Types
+			res["fields_lengths"] = lengths
+			res["precision"] = r.Precision
+			cursors[id] = &cursor{result: r}
+		case "fetch":
+			cur := cursors[uint64(resultID)]
+			if cur == nil {
+				res["code"] = 0xffff
+				res["message"] = "result not found"
+				break
+			}
+			if cur.next == len(cur.result.Blocks) {
+				res["completed"] = true
+				delete(cursors, uint64(resultID))
+				break
+			}
+			res["rows"] = len(cur.result.Blocks[cur.next])
+		case "fetch_block":
+			cur := cursors[uint64(resultID)]
+			block := appendUint64(make([]byte, 8), uint64(resultID))
+			block = append(block, encodeRawBlock(cur.result.Types, cur.result.Blocks[cur.next], cur.result.Precision)...)
+			cur.next++
+			if err := conn.WriteMessage(websocket.BinaryMessage, block); err != nil {
+				return
+			}
+			continue
+		case "free_result":
+			s.lock.Lock()
+			s.freed = append(s.freed, uint64(resultID))
+			s.lock.Unlock()
+			delete(cursors, uint64(resultID))
+			continue
+		}
+		if err := conn.WriteJSON(res); err != nil {
+			return
+		}
+	}
+}
+
+// client returns a connected client of the stand-in
+func (s *wsStandIn) client(t *testing.T, conns int) *Client {
+	u, _ := url.Parse(s.URL)
+	port, _ := strconv.Atoi(u.Port())
+	c := NewClient(WithBrokers([]string{u.Hostname()}), WithPort(port), WithWebSocket(conns))
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close(context.Background()) })
+	return c
+}
+
+func TestWSConnect3x(t *testing.T) {
+	s := newWSStandIn(t, map[string]*standInResult{"show dnodes": dnodes3x})
+	c := s.client(t, 1)
+	if len(c.brokerStatus) != 1 || !c.brokerStatus[0].ready {
+		t.Fatalf("unexpected broker status %+v", c.brokerStatus)
+	}
+	broker, ok := c.pickAliveBroker()
+	if !ok || broker != "127.0.0.1" {
+		t.Fatalf("pickAliveBroker() = %q, %v", broker, ok)
+	}
+}
+
+func TestDnodeStatus(t *testing.T) {
+	cases := []struct {
+		node  map[string]interface{}
+		ep    string
+		ready bool
+		ok    bool
+	}{
+		{map[string]interface{}{"end_point": "a:6030", "role": "any", "status": "ready"}, "a:6030", true, true},
+		{map[string]interface{}{"end_point": "a:6030", "role": "arb", "status": "ready"}, "", false, false},
+		{map[string]interface{}{"endpoint": "b:6030", "status": "offline"}, "b:6030", false, true},
+		{map[string]interface{}{"id": float64(1)}, "", false, false},
+	}
+	for _, c := range cases {
+		ep, ready, ok := dnodeStatus(c.node)
+		if ep != c.ep || ready != c.ready || ok != c.ok {
+			t.Errorf("dnodeStatus(%v) = %q, %v, %v", c.node, ep, ready, ok)
+		}
+	}
+}
+
+func TestWSQuery(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	s := newWSStandIn(t, map[string]*standInResult{
+		"show dnodes": dnodes3x,
+		"SELECT * FROM meters": {
+			Names: []string{"ts", "current", "voltage", "on", "location", "name"},
+			Types: []ColumnType{ColumnTypeTimestamp, ColumnTypeDouble, ColumnTypeInt, ColumnTypeBool, ColumnTypeBinary, ColumnTypeNchar},
+			Blocks: [][][]interface{}{
+				{{ts, 10.5, 220, true, "beijing", "电表"}, {ts.Add(time.Second), nil, nil, false, nil, nil}},
+				{{ts.Add(2 * time.Second), 11.0, -1, true, "shanghai", "b"}},
+			},
+		},
+		"INSERT INTO d1 VALUES (NOW, 1)": {IsUpdate: true, Affected: 1},
+	})
+	c := s.client(t, 1)
+	ctx := context.Background()
+	r, err := c.Query(ctx, "SELECT * FROM meters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Code != 0 || r.Rows != 3 || len(r.Columns) != 6 {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	first := r.Data[0]
+	if r.Precision != PrecisionMillisecond {
+		t.Errorf("Precision = %s", r.Precision)
+	}
+	if first["ts"] != ts.UnixNano()/int64(time.Millisecond) || first["current"] != 10.5 || first["voltage"] != float64(220) ||
+		first["on"] != true || first["location"] != "beijing" || first["name"] != "电表" {
+		t.Errorf("unexpected first row %v", first)
+	}
+	if second := r.Data[1]; second["current"] != nil || second["location"] != nil || second["on"] != false {
+		t.Errorf("unexpected second row %v", second)
+	}
+	if r.Data[2]["voltage"] != float64(-1) {
+		t.Errorf("unexpected third row %v", r.Data[2])
+	}
+
+	r, err = c.Query(ctx, "INSERT INTO d1 VALUES (NOW, 1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Rows != 1 || r.Data[0]["affected_rows"] != float64(1) {
+		t.Errorf("unexpected insert result %+v", r)
+	}
+
+	r, err = c.Query(ctx, "SELECT * FROM missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Code != 0x2662 || r.Message != "Table does not exist" {
+		t.Errorf("unexpected error result %+v", r)
+	}
+}
+
+func TestWSQueryPrecision(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	cases := []struct {
+		precision int
+		want      Precision
+		ts        time.Time
+	}{
+		{0, PrecisionMillisecond, ts.Truncate(time.Millisecond)},
+		{1, PrecisionMicrosecond, ts.Truncate(time.Microsecond)},
+		{2, PrecisionNanosecond, ts},
+	}
+	for _, c := range cases {
+		s := newWSStandIn(t, map[string]*standInResult{
+			"show dnodes": dnodes3x,
+			"SELECT ts FROM t": {
+				Names:     []string{"ts"},
+				Types:     []ColumnType{ColumnTypeTimestamp},
+				Blocks:    [][][]interface{}{{{ts}}},
+				Precision: c.precision,
+			},
+		})
+		client := s.client(t, 1)
+		ctx := context.Background()
+		r, err := client.Query(ctx, "SELECT ts FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Precision != c.want {
+			t.Errorf("%s: Precision = %s", c.want, r.Precision)
+		}
+		// timestamps are integers so nanoseconds are not rounded by float64
+		if got, want := r.Data[0]["ts"], c.ts.UnixNano()/int64(c.want.Unit()); got != want {
+			t.Errorf("%s: ts = %v, want %v", c.want, got, want)
+		}
+		var rows []struct{ Ts time.Time }
+		if err := decodeResult(r.Data, r.Precision, &rows); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || !rows[0].Ts.Equal(c.ts) {
+			t.Errorf("%s: decoded %v, want %v", c.want, rows, c.ts)
+		}
+
+		cur, err := client.QueryRows(ctx, "SELECT ts FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cur.Precision() != c.want {
+			t.Errorf("%s: Rows.Precision() = %s", c.want, cur.Precision())
+		}
+		if !cur.Next() || !cur.Values()[0].(time.Time).Equal(c.ts) {
+			t.Errorf("%s: rows %v, %v", c.want, cur.Values(), cur.Err())
+		}
+		cur.Close()
+	}
+}
+
+func TestWSQueryRows(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	blocks := make([][][]interface{}, 3)
+	for i := range blocks {
+		blocks[i] = [][]interface{}{{ts.Add(time.Duration(i) * time.Second), i}}
+	}
+	s := newWSStandIn(t, map[string]*standInResult{
+		"show dnodes": dnodes3x,
+		"SELECT ts, v FROM t": {
+			Names:  []string{"ts", "v"},
+			Types:  []ColumnType{ColumnTypeTimestamp, ColumnTypeBigInt},
+			Blocks: blocks,
+		},
+	})
+	c := s.client(t, 1)
+	ctx := context.Background()
+	rows, err := c.QueryRows(ctx, "SELECT ts, v FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for rows.Next() {
+		values := rows.Values()
+		if !values[0].(time.Time).Equal(ts.Add(time.Duration(n)*time.Second)) || values[1] != int64(n) {
+			t.Errorf("unexpected row %d %v", n, values)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if n != 3 {
+		t.Fatalf("got %d rows", n)
+	}
+
+	// closing before the end frees the result
+	rows, err = c.QueryRows(ctx, "SELECT ts, v FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	rows.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.lock.Lock()
+		freed := len(s.freed)
+		s.lock.Unlock()
+		if freed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("result is not freed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWSSharedConnections(t *testing.T) {
+	s := newWSStandIn(t, map[string]*standInResult{
+		"show dnodes": dnodes3x,
+		"SELECT 1":    {Names: []string{"1"}, Types: []ColumnType{ColumnTypeBigInt}, Blocks: [][][]interface{}{{{1}}}},
+	})
+	c := s.client(t, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := c.Query(context.Background(), "SELECT 1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if r.Rows != 1 || r.Data[0]["1"] != float64(1) {
+				t.Errorf("unexpected result %+v", r)
+			}
+		}()
+	}
+	wg.Wait()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conns > 2 {
+		t.Errorf("opened %d connections", s.conns)
+	}
+}